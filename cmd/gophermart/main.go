@@ -10,9 +10,12 @@ import (
 	"github.com/ivanpodgorny/gophermart/internal/client"
 	"github.com/ivanpodgorny/gophermart/internal/config"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/ivanpodgorny/gophermart/internal/events"
 	"github.com/ivanpodgorny/gophermart/internal/handler"
+	"github.com/ivanpodgorny/gophermart/internal/leader"
 	"github.com/ivanpodgorny/gophermart/internal/middleware"
 	"github.com/ivanpodgorny/gophermart/internal/migrations"
+	"github.com/ivanpodgorny/gophermart/internal/outbox"
 	"github.com/ivanpodgorny/gophermart/internal/repository"
 	"github.com/ivanpodgorny/gophermart/internal/security"
 	"github.com/ivanpodgorny/gophermart/internal/service"
@@ -21,7 +24,38 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"log"
 	"net/http"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// leaderLockKey - ключ рекомендательной блокировки PostgreSQL, которую удерживает
+	// ведущий экземпляр сервиса, выполняющий фоновую обработку заказов.
+	leaderLockKey      = 5_411_239_087
+	leaderPollInterval = 5 * time.Second
+	shutdownTimeout    = 10 * time.Second
+	drainTimeout       = 5 * time.Second
+	deadLetterInterval = 30 * time.Second
+	holdExpireInterval = 30 * time.Second
+	webhookInterval    = 10 * time.Second
+	outboxInterval     = time.Second
+	// outboxRetention - срок хранения опубликованных доменных событий.
+	outboxRetention     = 7 * 24 * time.Hour
+	outboxPurgeInterval = time.Hour
+	// pointsExpireAt - время ежедневного списания истекших баллов (смещение от полуночи).
+	pointsExpireAt    = 3 * time.Hour
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyStaleAfter - время, после которого ключ запроса без сохраненного ответа
+	// считается зависшим и освобождается.
+	idempotencyStaleAfter    = time.Minute
+	idempotencyPurgeInterval = time.Hour
+	// eventsBuffer - количество событий, которые ожидают отправки подписчику потока событий.
+	eventsBuffer        = 64
+	eventsKeepAlive     = 15 * time.Second
+	eventsRetryInterval = 5 * time.Second
+	socketHeartbeat     = 30 * time.Second
 )
 
 func main() {
@@ -54,58 +88,183 @@ func Execute() error {
 		return err
 	}
 
+	sink, err := outbox.Open(cfg.OutboxSink())
+	if err != nil {
+		return err
+	}
+
+	defer func(sink outbox.Sink) {
+		_ = sink.Close()
+	}(sink)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	var (
-		ctx, cancel = context.WithCancel(context.Background())
-		r           = chi.NewRouter()
-		v           = validator.New(validationEngine)
-		a           = security.NewAuthenticator(security.NewHMACSigner(cfg.HMACKey()), repository.NewToken(db))
-		wg          = &sync.WaitGroup{}
-		scj         = make(chan entity.StatusCheckJob, 8)
-		scr         = make(chan entity.StatusCheckResult, 8)
-		or          = repository.NewOrder(db)
-		ac          = client.NewAccrual(cfg.AccrualSystemAddress())
-		scw         = worker.NewStatusChecker(ctx, or, ac, scj, scr, wg, 4)
-		ouw         = worker.NewOrderUpdater(or, scr, wg, 4)
-		ss          = service.NewSignup(
+		wctx, cancel = context.WithCancel(context.Background())
+		r            = chi.NewRouter()
+		v            = validator.New(validationEngine)
+		a            = security.NewAuthenticator(security.NewHMACSigner(cfg.HMACKey()), repository.NewToken(db))
+		pr           = repository.NewPartner(db)
+		pa           = security.NewPartnerAuthenticator(cfg.AdminToken(), pr)
+		scwg         = &sync.WaitGroup{}
+		ouwg         = &sync.WaitGroup{}
+		scj          = make(chan entity.StatusCheckJob, cfg.StatusCheckQueueSize())
+		scr          = make(chan entity.StatusCheckResult, cfg.StatusResultQueueSize())
+		or           = repository.NewOrder(db, cfg.PointsTTL())
+		ac           = client.NewAccrual(cfg.AccrualSystemAddress())
+		dls          = service.NewDeadLetter(repository.NewDeadLetter(db), or)
+		scw          = worker.NewStatusChecker(or, ac, scj, scr, scwg, cfg.StatusCheckerWorkers())
+		ouw          = worker.NewOrderUpdater(or, dls, scr, ouwg, cfg.OrderUpdaterWorkers(), drainTimeout)
+		dlw          = worker.NewDeadLetterRetrier(dls, scwg, deadLetterInterval)
+		ss           = service.NewSignup(
 			repository.NewUser(db),
 			security.NewArgonHasher(security.DefaultHashConfig()),
 			a,
 		)
-		os = service.NewOrder(or, scj)
-		ts = service.NewTransaction(repository.NewTransaction(db))
-		sh = handler.NewSignup(ss, v)
-		oh = handler.NewOrder(os, a, v)
-		th = handler.NewTransaction(ts, a, v)
+		el = leader.NewElector(db, leaderLockKey, leaderPollInterval)
+		ed = make(chan struct{})
+		os = service.NewOrder(or, scw)
+		ts = service.NewTransaction(
+			repository.NewTransaction(db, cfg.PointsTTL()),
+			repository.NewHold(db, cfg.PointsTTL()),
+			repository.NewLot(db),
+			cfg.HoldTTL(),
+		)
+		hew = worker.NewHoldExpirer(ts, scwg, holdExpireInterval)
+		pew = worker.NewPointsExpirer(ts, scwg, pointsExpireAt)
+		sh  = handler.NewSignup(ss, v)
+		oh  = handler.NewOrder(os, a, v)
+		th  = handler.NewTransaction(ts, a, pa, v)
+		trh = handler.NewTransfer(
+			service.NewTransfer(repository.NewTransfer(db, cfg.PointsTTL()), cfg.TransferDailyLimit()),
+			a,
+			v,
+		)
+		wh  = handler.NewWorker(scw, ouw, el, ac, v)
+		dh  = handler.NewDeadLetter(dls)
+		lh  = handler.NewLedger(service.NewLedger(repository.NewLedger(db)))
+		ir  = repository.NewIdempotency(db, idempotencyKeyTTL, idempotencyStaleAfter)
+		ipw = worker.NewIdempotencyPurger(ir, scwg, idempotencyPurgeInterval)
+		eb  = events.NewBroker(eventsBuffer)
+		evl = events.NewListener(db, repository.EventsChannel, eb, eventsRetryInterval)
+		evd = make(chan struct{})
+		eh  = handler.NewEvent(eb, a, eventsKeepAlive)
+		wsh = handler.NewSocket(eb, a, socketHeartbeat)
+		whs = service.NewWebhook(repository.NewWebhook(db), client.NewWebhook())
+		whd = worker.NewWebhookDeliverer(whs, scwg, webhookInterval)
+		whh = handler.NewWebhook(whs, v)
+		ph  = handler.NewPartner(service.NewPartner(pr), v)
+		obx = repository.NewOutbox(db, outboxRetention)
+		obr = worker.NewOutboxRelay(service.NewOutbox(obx, sink), scwg, outboxInterval)
+		obp = worker.NewOutboxPurger(obx, scwg, outboxPurgeInterval)
 	)
 
 	defer func() {
 		cancel()
-		wg.Wait()
-		close(scj)
-		close(scr)
+		<-ed
+		<-evd
 	}()
 
-	scw.Do(ctx)
-	ouw.Do(ctx)
+	go func() {
+		defer close(evd)
+		evl.Run(wctx)
+	}()
+
+	go func() {
+		defer close(ed)
+		el.Run(wctx, func(ctx context.Context) {
+			uctx, ucancel := context.WithCancel(context.Background())
+			scw.Do(ctx)
+			dlw.Do(ctx)
+			hew.Do(ctx)
+			pew.Do(ctx)
+			whd.Do(ctx)
+			ipw.Do(ctx)
+			obr.Do(ctx)
+			obp.Do(ctx)
+			ouw.Do(uctx)
+			<-ctx.Done()
+			scwg.Wait()
+			ucancel()
+			ouwg.Wait()
+		})
+	}()
 
 	r.Use(chimiddleware.Recoverer)
 
 	r.Route("/api/user", func(r chi.Router) {
 		r.Post("/register", sh.Register)
 		r.Post("/login", sh.Login)
+		r.With(middleware.Authenticate(pa)).Post("/withdrawals/{order}/refund", th.Refund)
 
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.Authenticate(a))
 
 			r.Post("/orders", oh.Create)
+			r.Post("/orders/batch", oh.CreateBatch)
 			r.Get("/orders", oh.GetAll)
+			r.Get("/orders/events", eh.Stream)
+			r.Get("/ws", wsh.Serve)
+			r.Get("/orders/{number}", oh.Get)
+			r.Delete("/orders/{number}", oh.Delete)
 			r.Get("/balance", th.GetBalance)
-			r.Post("/balance/withdraw", th.Withdraw)
+			r.With(middleware.Idempotent(ir, a)).Post("/balance/withdraw", th.Withdraw)
 			r.Get("/withdrawals", th.GetWithdrawals)
+			r.Get("/statement", th.Statement)
+			r.Get("/statement.csv", th.StatementCSV)
+			r.Get("/statement.pdf", th.StatementPDF)
+			r.Get("/balance/holds", th.GetHolds)
+			r.With(middleware.Idempotent(ir, a)).Post("/balance/holds", th.Hold)
+			r.Post("/balance/holds/{id}/capture", th.Capture)
+			r.Post("/balance/holds/{id}/void", th.Void)
+			r.With(middleware.Idempotent(ir, a)).Post("/balance/transfer", trh.Transfer)
+			r.Get("/transfers", trh.GetAll)
 		})
 	})
 
-	err = http.ListenAndServe(cfg.ServerAddress(), r)
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.AdminAuthenticate(cfg.AdminToken()))
+
+		r.Get("/workers", wh.Get)
+		r.Put("/workers", wh.Resize)
+		r.Get("/dead-letters", dh.GetAll)
+		r.Post("/dead-letters/{id}/retry", dh.Retry)
+		r.Delete("/dead-letters/{id}", dh.Discard)
+		r.Get("/ledger/reconciliation", lh.Reconcile)
+		r.Post("/webhooks", whh.Create)
+		r.Get("/webhooks", whh.GetAll)
+		r.Delete("/webhooks/{id}", whh.Delete)
+		r.Get("/webhooks/{id}/deliveries", whh.Deliveries)
+		r.Post("/partners", ph.Create)
+		r.Get("/partners", ph.GetAll)
+		r.Delete("/partners/{id}", ph.Delete)
+	})
+
+	srv := &http.Server{Addr: cfg.ServerAddress(), Handler: r}
+	srv.RegisterOnShutdown(eb.Close)
+
+	return serve(ctx, srv)
+}
+
+// serve запускает HTTP-сервер и при отмене ctx прекращает прием новых запросов, дожидаясь
+// завершения обработки текущих в течение shutdownTimeout.
+func serve(ctx context.Context, srv *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Print("получен сигнал завершения, остановка сервера")
+
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	return err
+	return srv.Shutdown(sctx)
 }
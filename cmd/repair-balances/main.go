@@ -0,0 +1,49 @@
+// Команда repair-balances пересчитывает балансы пользователей по журналу операций с баллами.
+// Параметры подключения к базе данных задаются так же, как для сервиса: флагом -d
+// или переменной окружения DATABASE_URI.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivanpodgorny/gophermart/internal/config"
+	"github.com/ivanpodgorny/gophermart/internal/migrations"
+	"github.com/ivanpodgorny/gophermart/internal/repository"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"log"
+)
+
+func main() {
+	if err := Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Execute() error {
+	cfg, err := config.NewBuilder().LoadFlags().LoadEnv().Build()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("pgx", cfg.DatabaseURI())
+	if err != nil {
+		return err
+	}
+
+	defer func(db *sql.DB) {
+		err = db.Close()
+	}(db)
+
+	if err := migrations.Up(db); err != nil {
+		return err
+	}
+
+	n, err := repository.NewLedger(db).RebuildBalances(context.Background())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("исправлено балансов: %d", n)
+
+	return nil
+}
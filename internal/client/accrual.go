@@ -2,17 +2,49 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/imroc/req/v3"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type Accrual struct {
-	req *req.Client
+	req        *req.Client
+	cache      responseCache
+	violations atomic.Int64
 }
 
+// ProtocolError описывает ответ сервиса расчёта начислений, не соответствующий протоколу.
+// Соответствует ошибке errors.ErrProtocolViolation.
+type ProtocolError struct {
+	Order string
+	Err   error
+}
+
+// accrualResponse - ответ сервиса расчёта начислений. Поля читаются как необработанные значения
+// JSON и разбираются по отдельности, чтобы ответ неверного формата считался нарушением протокола,
+// а не ошибкой запроса.
+type accrualResponse struct {
+	Order   json.RawMessage `json:"order"`
+	Status  json.RawMessage `json:"status"`
+	Accrual json.RawMessage `json:"accrual"`
+}
+
+var (
+	ErrMalformedResponse = errors.New("malformed accrual response")
+	ErrUnknownStatus     = errors.New("unknown accrual status")
+	ErrOrderMismatch     = errors.New("order number mismatch")
+	ErrNegativeAccrual   = errors.New("negative accrual")
+	ErrUnexpectedAccrual = errors.New("accrual is present for unprocessed order")
+	ErrAccrualPrecision  = errors.New("accrual has more than two decimal places")
+)
+
 var statusMap = map[string]entity.OrderStatus{
 	"REGISTERED": entity.OrderStatusNew,
 	"INVALID":    entity.OrderStatusInvalid,
@@ -30,22 +62,36 @@ func NewAccrual(addr string) *Accrual {
 
 // GetAccrual отправляет запрос к сервису расчёта начислений баллов лояльности для получения
 // информации о статусе расчёта начисления по заказу. При ответе сервиса с кодом 429 пытается
-// выполнить повторный запрос через минуту.
-func (c *Accrual) GetAccrual(ctx context.Context, order string) (entity.OrderStatus, float64, error) {
-	respBody := struct {
-		Status  string  `json:"status"`
-		Accrual float64 `json:"accrual"`
-	}{}
-	resp, err := c.req.R().
+// выполнить повторный запрос через минуту. Если ответ не соответствует протоколу, возвращает
+// ошибку *ProtocolError.
+//
+// Ответы сервиса кэшируются с учетом заголовков ETag и Cache-Control, параллельные
+// запросы по одному заказу объединяются в один.
+func (c *Accrual) GetAccrual(ctx context.Context, order string) (entity.OrderStatus, entity.Amount, error) {
+	if e, ok := c.cache.fresh(order, time.Now()); ok {
+		return e.status, e.accrual, nil
+	}
+
+	return c.cache.do(ctx, order, func() (entity.OrderStatus, entity.Amount, error) {
+		return c.fetch(ctx, order)
+	})
+}
+
+func (c *Accrual) fetch(ctx context.Context, order string) (entity.OrderStatus, entity.Amount, error) {
+	r := c.req.R().
 		SetContext(ctx).
 		SetRetryCount(2).
 		SetRetryFixedInterval(60*time.Second).
 		SetRetryCondition(func(resp *req.Response, err error) bool {
 			return err == nil && resp.StatusCode == http.StatusTooManyRequests
 		}).
-		SetSuccessResult(&respBody).
-		SetPathParam("number", order).
-		Get("/api/orders/{number}")
+		SetPathParam("number", order)
+	cached, hasCached := c.cache.get(order)
+	if hasCached && cached.etag != "" {
+		r.SetHeader("If-None-Match", cached.etag)
+	}
+
+	resp, err := r.Get("/api/orders/{number}")
 	if err != nil {
 		return "", 0, err
 	}
@@ -54,9 +100,138 @@ func (c *Accrual) GetAccrual(ctx context.Context, order string) (entity.OrderSta
 		return "", 0, fmt.Errorf("server responded with status code %d", resp.StatusCode)
 	}
 
-	if resp.StatusCode == http.StatusNoContent {
-		return entity.OrderStatusInvalid, 0, nil
+	entry := cacheEntry{etag: resp.GetHeader("ETag")}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		entry.status, entry.accrual = cached.status, cached.accrual
+		if entry.etag == "" {
+			entry.etag = cached.etag
+		}
+	case resp.StatusCode == http.StatusNotModified:
+		return "", 0, fmt.Errorf("server responded with status code %d to unconditional request", resp.StatusCode)
+	case resp.StatusCode == http.StatusNoContent:
+		entry.status = entity.OrderStatusInvalid
+	default:
+		b, err := resp.ToBytes()
+		if err != nil {
+			return "", 0, err
+		}
+
+		status, accrual, err := c.parse(order, b)
+		if err != nil {
+			c.cache.delete(order)
+
+			return "", 0, err
+		}
+
+		entry.status, entry.accrual = status, accrual
+	}
+
+	if expires, ok := cacheExpiration(resp.Header, time.Now()); ok {
+		entry.expires = expires
+		c.cache.set(order, entry)
+	} else {
+		c.cache.delete(order)
+	}
+
+	return entry.status, entry.accrual, nil
+}
+
+// ProtocolViolations возвращает количество ответов сервиса, не соответствующих протоколу.
+func (c *Accrual) ProtocolViolations() int64 {
+	return c.violations.Load()
+}
+
+// parse разбирает и проверяет тело ответа сервиса b по заказу order. Если тело не является
+// объектом JSON, поля имеют неверный тип или не проходят проверку validate, возвращает ошибку
+// *ProtocolError. Начисление с более чем двумя знаками после запятой не округляется, так как
+// округление в любую сторону расходилось бы с суммой в системе расчёта, а считается нарушением
+// протокола с причиной ErrAccrualPrecision.
+func (c *Accrual) parse(order string, b []byte) (entity.OrderStatus, entity.Amount, error) {
+	var (
+		body      = accrualResponse{}
+		respOrder = ""
+		status    = ""
+		accrual   *entity.Amount
+	)
+	if err := json.Unmarshal(b, &body); err != nil {
+		return "", 0, c.violation(order, fmt.Errorf("%w: %w", ErrMalformedResponse, err))
+	}
+
+	fields := []struct {
+		name  string
+		value json.RawMessage
+		dst   any
+	}{
+		{"order", body.Order, &respOrder},
+		{"status", body.Status, &status},
+		{"accrual", body.Accrual, &accrual},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+
+		err := json.Unmarshal(f.value, f.dst)
+		if errors.Is(err, entity.ErrAmountPrecision) {
+			return "", 0, c.violation(order, fmt.Errorf("%w: %w", ErrAccrualPrecision, err))
+		} else if err != nil {
+			return "", 0, c.violation(order, fmt.Errorf("%w: field %s: %w", ErrMalformedResponse, f.name, err))
+		}
+	}
+
+	s, ok := statusMap[status]
+	if err := c.validate(order, respOrder, s, ok, accrual); err != nil {
+		return "", 0, err
+	}
+
+	if accrual == nil {
+		return s, 0, nil
 	}
 
-	return statusMap[respBody.Status], respBody.Accrual, err
+	return s, *accrual, nil
+}
+
+// validate проверяет, что номер заказа в ответе совпадает с запрошенным, статус известен,
+// а начисление неотрицательно и присутствует только у заказа в статусе PROCESSED.
+func (c *Accrual) validate(order, respOrder string, status entity.OrderStatus, known bool, accrual *entity.Amount) error {
+	var err error
+	switch {
+	case !known:
+		err = ErrUnknownStatus
+	case respOrder != order:
+		err = ErrOrderMismatch
+	case accrual != nil && *accrual < 0:
+		err = ErrNegativeAccrual
+	case accrual != nil && status != entity.OrderStatusProcessed:
+		err = ErrUnexpectedAccrual
+	default:
+		return nil
+	}
+
+	return c.violation(order, err)
+}
+
+// violation учитывает ответ сервиса по заказу order, не соответствующий протоколу, и возвращает
+// ошибку *ProtocolError с причиной err.
+func (c *Accrual) violation(order string, err error) error {
+	n := c.violations.Add(1)
+	log.Printf("нарушение протокола сервиса расчёта начислений (всего %d): заказ %s: %v", n, order, err)
+
+	return &ProtocolError{
+		Order: order,
+		Err:   err,
+	}
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("invalid accrual response for order %s: %v", e.Order, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ProtocolError) Is(target error) bool {
+	return target == inerr.ErrProtocolViolation
 }
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"github.com/imroc/req/v3"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestAccrual_GetAccrual(t *testing.T) {
@@ -18,7 +21,7 @@ func TestAccrual_GetAccrual(t *testing.T) {
 		errOrder   = "655770442208670"
 		wrongOrder = "711388585544181"
 		status     = "PROCESSED"
-		accrual    = 500.0
+		accrual    = entity.Amount(729_98)
 		addr       = "https://accrual.loc"
 		getURL     = func(n string) string {
 			return addr + "/api/orders/" + n
@@ -30,9 +33,9 @@ func TestAccrual_GetAccrual(t *testing.T) {
 	defer httpmock.DeactivateAndReset()
 
 	b, _ := json.Marshal(&struct {
-		Order   string  `json:"order"`
-		Status  string  `json:"status"`
-		Accrual float64 `json:"accrual"`
+		Order   string        `json:"order"`
+		Status  string        `json:"status"`
+		Accrual entity.Amount `json:"accrual"`
 	}{
 		Order:   order,
 		Status:  status,
@@ -69,3 +72,187 @@ func TestAccrual_GetAccrual(t *testing.T) {
 	assert.NoError(t, err, "незарегистрированный номер заказа")
 	assert.Equal(t, entity.OrderStatusInvalid, s, "незарегистрированный номер заказа")
 }
+
+func TestAccrual_GetAccrualProtocolViolations(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		order = "116322550058324"
+		addr  = "https://accrual.loc"
+		r     = req.C().SetBaseURL(addr)
+	)
+
+	httpmock.ActivateNonDefault(r.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	client := Accrual{
+		req: r,
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{
+			name:    "неизвестный статус",
+			body:    `{"order": "116322550058324", "status": "UNKNOWN"}`,
+			wantErr: ErrUnknownStatus,
+		},
+		{
+			name:    "номер заказа не совпадает с запрошенным",
+			body:    `{"order": "655770442208670", "status": "PROCESSED", "accrual": 500}`,
+			wantErr: ErrOrderMismatch,
+		},
+		{
+			name:    "отрицательное начисление",
+			body:    `{"order": "116322550058324", "status": "PROCESSED", "accrual": -1}`,
+			wantErr: ErrNegativeAccrual,
+		},
+		{
+			name:    "начисление у необработанного заказа",
+			body:    `{"order": "116322550058324", "status": "PROCESSING", "accrual": 500}`,
+			wantErr: ErrUnexpectedAccrual,
+		},
+		{
+			name:    "начисление с более чем двумя знаками после запятой",
+			body:    `{"order": "116322550058324", "status": "PROCESSED", "accrual": 729.985}`,
+			wantErr: ErrAccrualPrecision,
+		},
+		{
+			name:    "некорректный JSON",
+			body:    `{"order": "116322550058324", "status":`,
+			wantErr: ErrMalformedResponse,
+		},
+		{
+			name:    "тело не является объектом",
+			body:    `"PROCESSED"`,
+			wantErr: ErrMalformedResponse,
+		},
+		{
+			name:    "начисление передано строкой",
+			body:    `{"order": "116322550058324", "status": "PROCESSED", "accrual": "500"}`,
+			wantErr: ErrMalformedResponse,
+		},
+		{
+			name:    "номер заказа передан числом",
+			body:    `{"order": 116322550058324, "status": "PROCESSED", "accrual": 500}`,
+			wantErr: ErrMalformedResponse,
+		},
+		{
+			name:    "статус неверного типа",
+			body:    `{"order": "116322550058324", "status": ["PROCESSED"]}`,
+			wantErr: ErrMalformedResponse,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpmock.RegisterResponder(
+				"GET",
+				addr+"/api/orders/"+order,
+				httpmock.NewStringResponder(http.StatusOK, tt.body),
+			)
+
+			_, _, err := client.GetAccrual(ctx, order)
+			assert.ErrorIs(t, err, tt.wantErr)
+			var protocolErr *ProtocolError
+			assert.ErrorAs(t, err, &protocolErr)
+			assert.ErrorIs(t, err, inerr.ErrProtocolViolation)
+			assert.Equal(t, int64(i+1), client.ProtocolViolations())
+		})
+	}
+
+	httpmock.RegisterResponder(
+		"GET",
+		addr+"/api/orders/"+order,
+		httpmock.NewStringResponder(http.StatusOK, `{"order": "116322550058324", "status": "PROCESSED"}`),
+	)
+	s, a, err := client.GetAccrual(ctx, order)
+	assert.NoError(t, err, "обработанный заказ без начисления")
+	assert.Equal(t, entity.OrderStatusProcessed, s, "обработанный заказ без начисления")
+	assert.Equal(t, entity.Amount(0), a, "обработанный заказ без начисления")
+	assert.Equal(t, int64(len(tests)), client.ProtocolViolations())
+}
+
+func TestAccrual_GetAccrualCache(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		cachedOrder  = "116322550058324"
+		etagOrder    = "655770442208670"
+		pendingOrder = "711388585544181"
+		etag         = `"v1"`
+		addr         = "https://accrual.loc"
+		getURL       = func(n string) string {
+			return addr + "/api/orders/" + n
+		}
+		r = req.C().SetBaseURL(addr)
+	)
+
+	httpmock.ActivateNonDefault(r.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder(
+		"GET",
+		getURL(cachedOrder),
+		httpmock.NewStringResponder(
+			http.StatusOK,
+			`{"order": "116322550058324", "status": "PROCESSED", "accrual": 500}`,
+		).HeaderSet(http.Header{"Cache-Control": {"max-age=60"}}),
+	)
+	httpmock.RegisterResponder(
+		"GET",
+		getURL(etagOrder),
+		func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("If-None-Match") == etag {
+				return httpmock.NewStringResponse(http.StatusNotModified, ""), nil
+			}
+
+			resp := httpmock.NewStringResponse(http.StatusOK, `{"order": "655770442208670", "status": "PROCESSING"}`)
+			resp.Header.Set("ETag", etag)
+
+			return resp, nil
+		},
+	)
+	release := make(chan struct{})
+	httpmock.RegisterResponder(
+		"GET",
+		getURL(pendingOrder),
+		func(req *http.Request) (*http.Response, error) {
+			<-release
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"order": "711388585544181", "status": "REGISTERED"}`), nil
+		},
+	)
+	client := Accrual{
+		req: r,
+	}
+
+	for i := 0; i < 2; i++ {
+		s, a, err := client.GetAccrual(ctx, cachedOrder)
+		assert.NoError(t, err, "ответ с Cache-Control: max-age")
+		assert.Equal(t, entity.OrderStatusProcessed, s, "ответ с Cache-Control: max-age")
+		assert.Equal(t, entity.Amount(500_00), a, "ответ с Cache-Control: max-age")
+	}
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+getURL(cachedOrder)], "ответ берется из кэша")
+
+	for i := 0; i < 2; i++ {
+		s, _, err := client.GetAccrual(ctx, etagOrder)
+		assert.NoError(t, err, "ответ с ETag")
+		assert.Equal(t, entity.OrderStatusProcessing, s, "ответ с ETag")
+	}
+	assert.Equal(t, 2, httpmock.GetCallCountInfo()["GET "+getURL(etagOrder)], "условный запрос с If-None-Match")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, _, err := client.GetAccrual(ctx, pendingOrder)
+			assert.NoError(t, err, "параллельные запросы по одному заказу")
+			assert.Equal(t, entity.OrderStatusNew, s, "параллельные запросы по одному заказу")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+getURL(pendingOrder)], "параллельные запросы объединяются")
+}
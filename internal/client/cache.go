@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCache хранит последние ответы сервиса расчёта начислений по номерам заказов
+// и объединяет параллельные запросы по одному заказу в один. Нулевое значение готово
+// к использованию.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*call
+}
+
+type cacheEntry struct {
+	etag    string
+	expires time.Time
+	status  entity.OrderStatus
+	accrual entity.Amount
+}
+
+// call описывает выполняющийся запрос, результат которого получат все ожидающие его вызовы.
+type call struct {
+	done    chan struct{}
+	status  entity.OrderStatus
+	accrual entity.Amount
+	err     error
+}
+
+const maxCacheEntries = 10000
+
+// fresh возвращает сохраненный ответ по заказу, если срок его хранения не истек.
+func (c *responseCache) fresh(order string, now time.Time) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[order]
+	if !ok || !now.Before(e.expires) {
+		return cacheEntry{}, false
+	}
+
+	return e, true
+}
+
+// get возвращает сохраненный ответ по заказу независимо от срока его хранения.
+func (c *responseCache) get(order string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[order]
+
+	return e, ok
+}
+
+func (c *responseCache) set(order string, e cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+
+	if len(c.entries) >= maxCacheEntries {
+		c.prune(time.Now())
+	}
+
+	c.entries[order] = e
+}
+
+func (c *responseCache) delete(order string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, order)
+}
+
+// prune удаляет записи с истекшим сроком хранения, а если их недостаточно,
+// то произвольные записи, пока кэш не уменьшится вдвое.
+func (c *responseCache) prune(now time.Time) {
+	for order, e := range c.entries {
+		if !now.Before(e.expires) && e.etag == "" {
+			delete(c.entries, order)
+		}
+	}
+
+	for order := range c.entries {
+		if len(c.entries) < maxCacheEntries/2 {
+			break
+		}
+
+		delete(c.entries, order)
+	}
+}
+
+// do выполняет fn, если по заказу нет выполняющегося запроса, иначе дожидается
+// результата уже выполняющегося запроса.
+func (c *responseCache) do(
+	ctx context.Context,
+	order string,
+	fn func() (entity.OrderStatus, entity.Amount, error),
+) (entity.OrderStatus, entity.Amount, error) {
+	c.mu.Lock()
+	if cl, ok := c.calls[order]; ok {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+			return cl.status, cl.accrual, cl.err
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		}
+	}
+
+	if c.calls == nil {
+		c.calls = make(map[string]*call)
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[order] = cl
+	c.mu.Unlock()
+
+	cl.status, cl.accrual, cl.err = fn()
+
+	c.mu.Lock()
+	delete(c.calls, order)
+	c.mu.Unlock()
+	close(cl.done)
+
+	return cl.status, cl.accrual, cl.err
+}
+
+// cacheExpiration возвращает время, до которого ответ можно использовать без повторного
+// запроса, согласно заголовку Cache-Control. Если ответ нельзя сохранять, возвращает false.
+func cacheExpiration(h http.Header, now time.Time) (time.Time, bool) {
+	expires := now
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return time.Time{}, false
+		case "no-cache":
+			return now, true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				expires = now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+
+	return expires, true
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheExpiration(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		cacheControl string
+		wantExpires  time.Time
+		wantStore    bool
+	}{
+		{
+			name:         "заголовок отсутствует",
+			cacheControl: "",
+			wantExpires:  now,
+			wantStore:    true,
+		},
+		{
+			name:         "max-age",
+			cacheControl: "public, max-age=30",
+			wantExpires:  now.Add(30 * time.Second),
+			wantStore:    true,
+		},
+		{
+			name:         "no-cache",
+			cacheControl: "max-age=30, no-cache",
+			wantExpires:  now,
+			wantStore:    true,
+		},
+		{
+			name:         "no-store",
+			cacheControl: "no-store",
+			wantStore:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expires, store := cacheExpiration(http.Header{"Cache-Control": {tt.cacheControl}}, now)
+			assert.Equal(t, tt.wantStore, store)
+			if tt.wantStore {
+				assert.Equal(t, tt.wantExpires, expires)
+			}
+		})
+	}
+}
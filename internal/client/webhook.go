@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/imroc/req/v3"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"strconv"
+	"time"
+)
+
+type Webhook struct {
+	req *req.Client
+}
+
+func NewWebhook() *Webhook {
+	return &Webhook{
+		req: req.C().SetTimeout(10 * time.Second),
+	}
+}
+
+// Send отправляет уведомление d POST-запросом на адрес подписки и возвращает код ответа.
+// Запрос содержит заголовки X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp
+// и X-Webhook-Signature с подписью, вычисленной функцией Sign. Уведомление считается
+// доставленным только при ответе с кодом 2xx, для остальных кодов возвращается ошибка.
+func (c *Webhook) Send(ctx context.Context, d entity.WebhookDelivery) (int, error) {
+	ts := time.Now().Unix()
+	resp, err := c.req.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-Webhook-Event", string(d.Event)).
+		SetHeader("X-Webhook-Delivery", strconv.Itoa(d.ID)).
+		SetHeader("X-Webhook-Timestamp", strconv.FormatInt(ts, 10)).
+		SetHeader("X-Webhook-Signature", "sha256="+Sign(d.Secret, ts, d.Payload)).
+		SetBodyBytes(d.Payload).
+		Post(d.URL)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign возвращает подпись уведомления: HMAC-SHA256 строки "<timestamp>.<payload>"
+// с ключом secret в шестнадцатеричном виде. Партнер проверяет подпись, вычисляя ее
+// по заголовку X-Webhook-Timestamp и телу запроса.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	m.Write(payload)
+
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imroc/req/v3"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestWebhook_Send(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		okURL   = "https://partner.loc/hooks"
+		failURL = "https://partner.loc/broken"
+		payload = json.RawMessage(`{"event":"order.processed","data":{"order":"2377225624"}}`)
+		d       = entity.WebhookDelivery{
+			ID:      7,
+			Event:   entity.WebhookEventOrderProcessed,
+			Payload: payload,
+			URL:     okURL,
+			Secret:  "secret",
+		}
+		r = req.C()
+	)
+
+	httpmock.ActivateNonDefault(r.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", okURL, func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		ts, err := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte(payload), body, "тело запроса")
+		assert.Equal(t, "order.processed", req.Header.Get("X-Webhook-Event"))
+		assert.Equal(t, "7", req.Header.Get("X-Webhook-Delivery"))
+		assert.Equal(t, "sha256="+Sign(d.Secret, ts, body), req.Header.Get("X-Webhook-Signature"), "подпись")
+
+		return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+	})
+	httpmock.RegisterResponder("POST", failURL, httpmock.NewStringResponder(http.StatusBadGateway, ""))
+
+	client := Webhook{req: r}
+
+	code, err := client.Send(ctx, d)
+	assert.NoError(t, err, "уведомление доставлено")
+	assert.Equal(t, http.StatusNoContent, code, "уведомление доставлено")
+
+	d.URL = failURL
+	code, err = client.Send(ctx, d)
+	assert.Error(t, err, "ошибка на стороне партнера")
+	assert.Equal(t, http.StatusBadGateway, code, "ошибка на стороне партнера")
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(
+		t,
+		"e47516ce522d6c4b56802d6c6b8a08116ce21cdb5779e7d17e041c1c6a551b77",
+		Sign("secret", 1673352000, []byte(`{}`)),
+	)
+}
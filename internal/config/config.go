@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"github.com/caarlos0/env/v8"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -17,21 +20,46 @@ type Builder struct {
 }
 
 type parameters struct {
-	ServerAddress        string `env:"RUN_ADDRESS"`
-	HMACKey              string `env:"HMAC_KEY"`
-	DatabaseURI          string `env:"DATABASE_URI"`
-	AccrualSystemAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	ServerAddress         string        `env:"RUN_ADDRESS"`
+	HMACKey               string        `env:"HMAC_KEY"`
+	AdminToken            string        `env:"ADMIN_TOKEN"`
+	DatabaseURI           string        `env:"DATABASE_URI"`
+	AccrualSystemAddress  string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	StatusCheckerWorkers  int           `env:"STATUS_CHECKER_WORKERS"`
+	OrderUpdaterWorkers   int           `env:"ORDER_UPDATER_WORKERS"`
+	StatusCheckQueueSize  int           `env:"STATUS_CHECK_QUEUE_SIZE"`
+	StatusResultQueueSize int           `env:"STATUS_RESULT_QUEUE_SIZE"`
+	HoldTTL               time.Duration `env:"HOLD_TTL"`
+	PointsTTL             time.Duration `env:"POINTS_TTL"`
+	TransferDailyLimit    entity.Amount `env:"TRANSFER_DAILY_LIMIT"`
+	OutboxSink            string        `env:"OUTBOX_SINK"`
 }
 
 const (
 	defaultServerAddress = "localhost:8080"
+	defaultWorkers       = 4
+	defaultQueueSize     = 8
+	defaultHoldTTL       = 30 * time.Minute
+)
+
+var (
+	ErrInvalidWorkersCount  = errors.New("workers count must be positive")
+	ErrInvalidQueueSize     = errors.New("queue size must not be negative")
+	ErrInvalidHoldTTL       = errors.New("hold ttl must be positive")
+	ErrInvalidPointsTTL     = errors.New("points ttl must not be negative")
+	ErrInvalidTransferLimit = errors.New("transfer daily limit must not be negative")
 )
 
 func NewBuilder() *Builder {
 	return &Builder{
 		arguments: os.Args[1:],
 		parameters: &parameters{
-			ServerAddress: defaultServerAddress,
+			ServerAddress:         defaultServerAddress,
+			StatusCheckerWorkers:  defaultWorkers,
+			OrderUpdaterWorkers:   defaultWorkers,
+			StatusCheckQueueSize:  defaultQueueSize,
+			StatusResultQueueSize: defaultQueueSize,
+			HoldTTL:               defaultHoldTTL,
 		},
 	}
 }
@@ -54,6 +82,14 @@ func (b *Builder) LoadFlags() *Builder {
 	flag.StringVar(&b.parameters.ServerAddress, "a", b.parameters.ServerAddress, "адрес и порт запуска сервиса HTTP-сервера")
 	flag.StringVar(&b.parameters.DatabaseURI, "d", "", "адрес подключения к PostgreSQL")
 	flag.StringVar(&b.parameters.AccrualSystemAddress, "r", "", "адрес системы расчёта начислений")
+	flag.IntVar(&b.parameters.StatusCheckerWorkers, "cw", b.parameters.StatusCheckerWorkers, "количество воркеров проверки статусов заказов")
+	flag.IntVar(&b.parameters.OrderUpdaterWorkers, "uw", b.parameters.OrderUpdaterWorkers, "количество воркеров обновления статусов заказов")
+	flag.IntVar(&b.parameters.StatusCheckQueueSize, "cq", b.parameters.StatusCheckQueueSize, "размер очереди задач на проверку статусов заказов")
+	flag.IntVar(&b.parameters.StatusResultQueueSize, "uq", b.parameters.StatusResultQueueSize, "размер очереди задач на обновление статусов заказов")
+	flag.DurationVar(&b.parameters.HoldTTL, "ht", b.parameters.HoldTTL, "срок действия резерва баллов")
+	flag.DurationVar(&b.parameters.PointsTTL, "pt", b.parameters.PointsTTL, "срок действия начисленных баллов, 0 - бессрочно")
+	flag.TextVar(&b.parameters.TransferDailyLimit, "tl", b.parameters.TransferDailyLimit, "суточный лимит переводов баллов одного пользователя, 0 - без ограничений")
+	flag.StringVar(&b.parameters.OutboxSink, "os", b.parameters.OutboxSink, "получатель доменных событий: stdout, file:<путь> или URL")
 
 	err := flag.CommandLine.Parse(b.arguments)
 	if err != nil {
@@ -64,7 +100,31 @@ func (b *Builder) LoadFlags() *Builder {
 }
 
 func (b *Builder) Build() (*Config, error) {
-	return &Config{b.parameters}, b.err
+	if b.err != nil {
+		return &Config{b.parameters}, b.err
+	}
+
+	if b.parameters.StatusCheckerWorkers < 1 || b.parameters.OrderUpdaterWorkers < 1 {
+		return &Config{b.parameters}, ErrInvalidWorkersCount
+	}
+
+	if b.parameters.StatusCheckQueueSize < 0 || b.parameters.StatusResultQueueSize < 0 {
+		return &Config{b.parameters}, ErrInvalidQueueSize
+	}
+
+	if b.parameters.HoldTTL <= 0 {
+		return &Config{b.parameters}, ErrInvalidHoldTTL
+	}
+
+	if b.parameters.PointsTTL < 0 {
+		return &Config{b.parameters}, ErrInvalidPointsTTL
+	}
+
+	if b.parameters.TransferDailyLimit < 0 {
+		return &Config{b.parameters}, ErrInvalidTransferLimit
+	}
+
+	return &Config{b.parameters}, nil
 }
 
 func (c *Config) ServerAddress() string {
@@ -82,3 +142,45 @@ func (c *Config) DatabaseURI() string {
 func (c *Config) AccrualSystemAddress() string {
 	return c.parameters.AccrualSystemAddress
 }
+
+func (c *Config) AdminToken() string {
+	return c.parameters.AdminToken
+}
+
+func (c *Config) StatusCheckerWorkers() int {
+	return c.parameters.StatusCheckerWorkers
+}
+
+func (c *Config) OrderUpdaterWorkers() int {
+	return c.parameters.OrderUpdaterWorkers
+}
+
+func (c *Config) StatusCheckQueueSize() int {
+	return c.parameters.StatusCheckQueueSize
+}
+
+func (c *Config) StatusResultQueueSize() int {
+	return c.parameters.StatusResultQueueSize
+}
+
+func (c *Config) HoldTTL() time.Duration {
+	return c.parameters.HoldTTL
+}
+
+// PointsTTL возвращает срок действия начисленных баллов. Нулевое значение означает,
+// что баллы действуют бессрочно.
+func (c *Config) PointsTTL() time.Duration {
+	return c.parameters.PointsTTL
+}
+
+// TransferDailyLimit возвращает максимальную сумму переводов баллов одного пользователя
+// за сутки. Нулевое значение означает, что сумма переводов не ограничена.
+func (c *Config) TransferDailyLimit() entity.Amount {
+	return c.parameters.TransferDailyLimit
+}
+
+// OutboxSink возвращает адрес получателя доменных событий. Пустое значение означает,
+// что события не публикуются и удаляются по истечении срока хранения.
+func (c *Config) OutboxSink() string {
+	return c.parameters.OutboxSink
+}
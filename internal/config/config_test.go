@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
+	"github.com/ivanpodgorny/gophermart/internal/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +15,7 @@ func TestBuilder_LoadEnv(t *testing.T) {
 		serverAddress        = "localhost:8080"
 		accrualSystemAddress = "localhost:8000"
 		hmacKey              = "key"
+		adminToken           = "admin"
 		databaseURI          = "dsn"
 		builder              = &Builder{
 			parameters: &parameters{},
@@ -23,13 +26,31 @@ func TestBuilder_LoadEnv(t *testing.T) {
 	require.NoError(t, os.Setenv("ACCRUAL_SYSTEM_ADDRESS", accrualSystemAddress))
 	require.NoError(t, os.Setenv("HMAC_KEY", hmacKey))
 	require.NoError(t, os.Setenv("DATABASE_URI", databaseURI))
+	require.NoError(t, os.Setenv("ADMIN_TOKEN", adminToken))
+	require.NoError(t, os.Setenv("STATUS_CHECKER_WORKERS", "2"))
+	require.NoError(t, os.Setenv("ORDER_UPDATER_WORKERS", "3"))
+	require.NoError(t, os.Setenv("STATUS_CHECK_QUEUE_SIZE", "16"))
+	require.NoError(t, os.Setenv("STATUS_RESULT_QUEUE_SIZE", "32"))
+	require.NoError(t, os.Setenv("HOLD_TTL", "15m"))
+	require.NoError(t, os.Setenv("POINTS_TTL", "8760h"))
+	require.NoError(t, os.Setenv("TRANSFER_DAILY_LIMIT", "5000.50"))
+	require.NoError(t, os.Setenv("OUTBOX_SINK", "stdout"))
 
 	cfg, err := builder.LoadEnv().Build()
 	require.NoError(t, err)
 	assert.Equal(t, serverAddress, cfg.ServerAddress())
 	assert.Equal(t, accrualSystemAddress, cfg.AccrualSystemAddress())
 	assert.Equal(t, hmacKey, cfg.HMACKey())
+	assert.Equal(t, adminToken, cfg.AdminToken())
 	assert.Equal(t, databaseURI, cfg.DatabaseURI())
+	assert.Equal(t, 2, cfg.StatusCheckerWorkers())
+	assert.Equal(t, 3, cfg.OrderUpdaterWorkers())
+	assert.Equal(t, 16, cfg.StatusCheckQueueSize())
+	assert.Equal(t, 32, cfg.StatusResultQueueSize())
+	assert.Equal(t, 15*time.Minute, cfg.HoldTTL())
+	assert.Equal(t, 365*24*time.Hour, cfg.PointsTTL())
+	assert.Equal(t, entity.Amount(5000_50), cfg.TransferDailyLimit())
+	assert.Equal(t, "stdout", cfg.OutboxSink())
 }
 
 func TestBuilder_LoadFlags(t *testing.T) {
@@ -43,6 +64,14 @@ func TestBuilder_LoadFlags(t *testing.T) {
 				"-a", serverAddress,
 				"-r", accrualSystemAddress,
 				"-d", databaseURI,
+				"-cw", "2",
+				"-uw", "3",
+				"-cq", "16",
+				"-uq", "32",
+				"-ht", "1h",
+				"-pt", "720h",
+				"-tl", "1000",
+				"-os", "file:/var/log/gophermart/events.jsonl",
 			},
 		}
 	)
@@ -52,4 +81,77 @@ func TestBuilder_LoadFlags(t *testing.T) {
 	assert.Equal(t, serverAddress, cfg.ServerAddress())
 	assert.Equal(t, accrualSystemAddress, cfg.AccrualSystemAddress())
 	assert.Equal(t, databaseURI, cfg.DatabaseURI())
+	assert.Equal(t, 2, cfg.StatusCheckerWorkers())
+	assert.Equal(t, 3, cfg.OrderUpdaterWorkers())
+	assert.Equal(t, 16, cfg.StatusCheckQueueSize())
+	assert.Equal(t, 32, cfg.StatusResultQueueSize())
+	assert.Equal(t, time.Hour, cfg.HoldTTL())
+	assert.Equal(t, 30*24*time.Hour, cfg.PointsTTL())
+	assert.Equal(t, entity.Amount(1000_00), cfg.TransferDailyLimit())
+	assert.Equal(t, "file:/var/log/gophermart/events.jsonl", cfg.OutboxSink())
+}
+
+func TestBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters parameters
+		wantErr    error
+	}{
+		{
+			name:       "параметры по умолчанию",
+			parameters: *NewBuilder().parameters,
+		},
+		{
+			name: "некорректное количество воркеров",
+			parameters: parameters{
+				StatusCheckerWorkers: 0,
+				OrderUpdaterWorkers:  4,
+			},
+			wantErr: ErrInvalidWorkersCount,
+		},
+		{
+			name: "некорректный размер очереди",
+			parameters: parameters{
+				StatusCheckerWorkers:  4,
+				OrderUpdaterWorkers:   4,
+				StatusResultQueueSize: -1,
+			},
+			wantErr: ErrInvalidQueueSize,
+		},
+		{
+			name: "некорректный срок действия резерва",
+			parameters: parameters{
+				StatusCheckerWorkers: 4,
+				OrderUpdaterWorkers:  4,
+			},
+			wantErr: ErrInvalidHoldTTL,
+		},
+		{
+			name: "отрицательный срок действия баллов",
+			parameters: parameters{
+				StatusCheckerWorkers: 4,
+				OrderUpdaterWorkers:  4,
+				HoldTTL:              time.Minute,
+				PointsTTL:            -time.Hour,
+			},
+			wantErr: ErrInvalidPointsTTL,
+		},
+		{
+			name: "отрицательный лимит переводов",
+			parameters: parameters{
+				StatusCheckerWorkers: 4,
+				OrderUpdaterWorkers:  4,
+				HoldTTL:              time.Minute,
+				TransferDailyLimit:   -1,
+			},
+			wantErr: ErrInvalidTransferLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.parameters
+			_, err := (&Builder{parameters: &p}).Build()
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
 }
@@ -0,0 +1,161 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Amount - сумма баллов в сотых долях (копейках). Хранится как целое число, чтобы
+// при сложении и вычитании не накапливалась ошибка округления. В JSON передается
+// числом с двумя знаками после запятой, в базе данных хранится в столбцах numeric(14,2).
+type Amount int64
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrAmountOverflow  = errors.New("amount overflow")
+	ErrAmountPrecision = errors.New("amount has more than two decimal places")
+)
+
+// ParseAmount преобразует десятичную запись числа в Amount. Суммы, которые не выражаются
+// целым числом копеек (например, "100.005"), не округляются, а отклоняются с ошибкой
+// ErrAmountPrecision.
+func ParseAmount(s string) (Amount, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	r.Mul(r, big.NewRat(100, 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w: %q", ErrAmountPrecision, s)
+	}
+
+	return amountFromInt(r.Num(), s)
+}
+
+// roundAmount преобразует десятичную запись числа в Amount, округляя дробную часть до сотых,
+// половина округляется от нуля. Используется только для значений с плавающей точкой,
+// прочитанных из базы данных, точная десятичная запись которых длиннее двух знаков.
+func roundAmount(s string) (Amount, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	r.Mul(r, big.NewRat(100, 1))
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() != 0 && new(big.Int).Abs(new(big.Int).Lsh(m, 1)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+
+	return amountFromInt(q, s)
+}
+
+func amountFromInt(q *big.Int, s string) (Amount, error) {
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrAmountOverflow, s)
+	}
+
+	return Amount(q.Int64()), nil
+}
+
+// String возвращает сумму с двумя знаками после запятой, например "500.50".
+func (a Amount) String() string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = uint64(-(a + 1)) + 1
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, u/100, u%100)
+}
+
+// Float64 возвращает приближенное значение суммы для вывода в журнал и сравнения.
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	// Сумма передается только числом: строки и другие значения JSON не принимаются.
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, s)
+	}
+
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+
+	*a = v
+
+	return nil
+}
+
+// MarshalText реализует encoding.TextMarshaler.
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText реализует encoding.TextUnmarshaler, что позволяет задавать суммы
+// в параметрах конфигурации.
+func (a *Amount) UnmarshalText(b []byte) error {
+	v, err := ParseAmount(string(b))
+	if err != nil {
+		return err
+	}
+
+	*a = v
+
+	return nil
+}
+
+// Scan реализует sql.Scanner. Значения numeric драйвер возвращает строкой.
+func (a *Amount) Scan(src any) error {
+	var (
+		v   Amount
+		err error
+	)
+	switch s := src.(type) {
+	case string:
+		v, err = ParseAmount(s)
+	case []byte:
+		v, err = ParseAmount(string(s))
+	case int64:
+		if s > math.MaxInt64/100 || s < math.MinInt64/100 {
+			return ErrAmountOverflow
+		}
+		v = Amount(s * 100)
+	case float64:
+		v, err = roundAmount(strconv.FormatFloat(s, 'f', -1, 64))
+	case nil:
+		v = 0
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidAmount, src)
+	}
+	if err != nil {
+		return err
+	}
+
+	*a = v
+
+	return nil
+}
+
+// Value реализует driver.Valuer.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
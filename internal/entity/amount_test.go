@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Amount
+	}{
+		{"0", 0},
+		{"500", 500_00},
+		{"500.5", 500_50},
+		{"729.98", 729_98},
+		{"0.1", 10},
+		{"100.010", 100_01},
+		{"-0.05", -5},
+		{"1e2", 100_00},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.s)
+		assert.NoError(t, err, tt.s)
+		assert.Equal(t, tt.want, got, tt.s)
+	}
+
+	_, err := ParseAmount("abc")
+	assert.ErrorIs(t, err, ErrInvalidAmount, "некорректная запись числа")
+	_, err = ParseAmount("1e30")
+	assert.ErrorIs(t, err, ErrAmountOverflow, "переполнение")
+	for _, s := range []string{"100.005", "0.001", "-0.005"} {
+		_, err = ParseAmount(s)
+		assert.ErrorIs(t, err, ErrAmountPrecision, "сумма не выражается целым числом копеек: "+s)
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Amount
+	}{
+		{"0.005", 1},
+		{"0.0049", 0},
+		{"-0.005", -1},
+		{"729.97998046875", 729_98},
+	}
+
+	for _, tt := range tests {
+		got, err := roundAmount(tt.s)
+		assert.NoError(t, err, tt.s)
+		assert.Equal(t, tt.want, got, tt.s)
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	b, err := json.Marshal(struct {
+		Sum   Amount `json:"sum"`
+		Debt  Amount `json:"debt"`
+		Round Amount `json:"round"`
+	}{Sum: 500_50, Debt: -5, Round: 42_00})
+	require.NoError(t, err)
+	assert.Equal(t, `{"sum":500.50,"debt":-0.05,"round":42.00}`, string(b), "суммы с двумя знаками после запятой")
+
+	var v struct {
+		Sum Amount `json:"sum"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"sum": 0.1}`), &v))
+	assert.Equal(t, Amount(10), v.Sum, "точное чтение десятичной дроби")
+
+	assert.Error(t, json.Unmarshal([]byte(`{"sum": "10"}`), &v), "сумма строкой")
+}
+
+func TestAmount_Text(t *testing.T) {
+	b, err := Amount(1000_50).MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "1000.50", string(b))
+
+	var a Amount
+	require.NoError(t, a.UnmarshalText([]byte("250.5")))
+	assert.Equal(t, Amount(250_50), a)
+
+	assert.ErrorIs(t, a.UnmarshalText([]byte("abc")), ErrInvalidAmount, "некорректная сумма")
+}
+
+func TestAmount_Scan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Amount
+	}{
+		{"100.10", 100_10},
+		{[]byte("0.30"), 30},
+		{int64(7), 7_00},
+		{0.3, 30},
+		{nil, 0},
+	}
+
+	for _, tt := range tests {
+		var a Amount
+		assert.NoError(t, a.Scan(tt.src), "%v", tt.src)
+		assert.Equal(t, tt.want, a, "%v", tt.src)
+	}
+
+	var a Amount
+	assert.ErrorIs(t, a.Scan(true), ErrInvalidAmount, "неподдерживаемый тип")
+
+	v, err := Amount(100_10).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "100.10", v)
+}
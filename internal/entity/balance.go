@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// Balance - баланс пользователя: доступные, зарезервированные и списанные баллы,
+// а также доступные баллы, срок действия которых скоро истекает.
+type Balance struct {
+	Current     Amount       `json:"current"`
+	Held        Amount       `json:"held"`
+	Withdrawn   Amount       `json:"withdrawn"`
+	Expirations []Expiration `json:"expirations,omitempty"`
+}
+
+// Expiration - доступные баллы, срок действия которых истекает в момент ExpiresAt.
+type Expiration struct {
+	Sum       Amount    `json:"sum"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// DeadLetter - результат проверки статуса заказа, который не удалось сохранить.
+type DeadLetter struct {
+	ID            int         `json:"id"`
+	Order         string      `json:"order"`
+	Status        OrderStatus `json:"status"`
+	Accrual       Amount      `json:"accrual"`
+	Error         string      `json:"error"`
+	Attempts      int         `json:"attempts"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Result возвращает результат проверки статуса заказа для повторного сохранения.
+func (d DeadLetter) Result() StatusCheckResult {
+	return StatusCheckResult{
+		Num:     d.Order,
+		Status:  d.Status,
+		Accrual: d.Accrual,
+	}
+}
+
+// RetryDelay возвращает задержку перед повторной попыткой с номером attempt: задержка
+// удваивается с каждой попыткой, но не превышает часа.
+func RetryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,12 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, retryBaseDelay, RetryDelay(1))
+	assert.Equal(t, 4*retryBaseDelay, RetryDelay(3))
+	assert.Equal(t, retryMaxDelay, RetryDelay(100))
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "encoding/json"
+
+// Event - событие пользователя UserID, которое передается клиентам в реальном времени.
+// Data содержит данные события в формате JSON.
+type Event struct {
+	UserID int             `json:"user_id"`
+	Type   EventType       `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type EventType string
+
+const (
+	// EventTypeOrder - изменился статус заказа, Data - заказ.
+	EventTypeOrder EventType = "order"
+	// EventTypeBalance - изменился баланс, Data - баланс без сроков действия баллов.
+	EventTypeBalance EventType = "balance"
+)
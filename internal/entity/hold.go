@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// Hold - резерв баллов пользователя в счёт оплаты заказа. Зарезервированные баллы недоступны
+// для других операций, пока резерв не будет списан, отменен или не истечет.
+type Hold struct {
+	ID        int        `json:"id"`
+	Order     string     `json:"order"`
+	Sum       Amount     `json:"sum"`
+	Status    HoldStatus `json:"status"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "ACTIVE"
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	HoldStatusVoided   HoldStatus = "VOIDED"
+	HoldStatusExpired  HoldStatus = "EXPIRED"
+)
@@ -0,0 +1,12 @@
+package entity
+
+// IdempotencyRecord - сохраненный запрос с ключом идемпотентности и ответ на него.
+type IdempotencyRecord struct {
+	// Fingerprint - хеш метода, пути и тела запроса.
+	Fingerprint string
+	// Completed - обработка запроса завершена и ответ сохранен.
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
@@ -0,0 +1,36 @@
+package entity
+
+// AccountType - тип счёта в журнале операций с баллами.
+type AccountType string
+
+const (
+	// AccountTypeUser - счёт пользователя, остаток которого равен доступным баллам.
+	AccountTypeUser AccountType = "USER"
+	// AccountTypeHold - счёт пользователя, остаток которого равен зарезервированным баллам.
+	AccountTypeHold AccountType = "HOLD"
+	// AccountTypeAccrualSource - системный счёт, с которого начисляются баллы за заказы.
+	AccountTypeAccrualSource AccountType = "ACCRUAL_SOURCE"
+	// AccountTypeRedemptionSink - системный счёт, на который поступают списанные баллы.
+	AccountTypeRedemptionSink AccountType = "REDEMPTION_SINK"
+	// AccountTypeAdjustments - системный счёт для ручных корректировок.
+	AccountTypeAdjustments AccountType = "ADJUSTMENTS"
+	// AccountTypeExpirationSink - системный счёт, на который поступают баллы с истекшим сроком действия.
+	AccountTypeExpirationSink AccountType = "EXPIRATION_SINK"
+)
+
+// Reconciliation - результат сверки журнала операций.
+type Reconciliation struct {
+	// Balanced - журнал сбалансирован: все записи сбалансированы, нет отрицательных
+	// остатков на счетах пользователей и сохраненные балансы совпадают с остатками.
+	Balanced bool `json:"balanced"`
+	// UnbalancedEntries - идентификаторы записей журнала, сумма проводок которых не равна нулю
+	// или которые содержат меньше двух проводок.
+	UnbalancedEntries []int `json:"unbalanced_entries"`
+	// NegativeAccounts - идентификаторы пользователей с отрицательным остатком на счёте.
+	NegativeAccounts []int `json:"negative_accounts"`
+	// MismatchedBalances - идентификаторы пользователей, сохраненный баланс которых
+	// не совпадает с остатком на счёте.
+	MismatchedBalances []int `json:"mismatched_balances"`
+	// Total - сумма всех проводок журнала, у сбалансированного журнала равна нулю.
+	Total Amount `json:"total"`
+}
@@ -3,21 +3,67 @@ package entity
 import "time"
 
 type Order struct {
+	ID         int         `json:"-"`
 	Number     string      `json:"number"`
 	Status     OrderStatus `json:"status"`
-	Accrual    float64     `json:"accrual"`
+	Accrual    Amount      `json:"accrual"`
 	UploadedAt time.Time   `json:"uploaded_at"`
 }
 
+// OrderDetail - заказ с историей изменения статуса и записями журнала операций по заказу.
+type OrderDetail struct {
+	Order
+	History []OrderStatusChange `json:"history"`
+	Entries []OrderEntry        `json:"entries"`
+}
+
+// OrderStatusChange - переход заказа в статус Status в момент ChangedAt.
+type OrderStatusChange struct {
+	Status    OrderStatus `json:"status"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
+// OrderEntry - запись журнала операций по заказу. Sum - изменение баланса пользователя
+// (отрицательное для списаний).
+type OrderEntry struct {
+	ID          int             `json:"id"`
+	Type        TransactionType `json:"type"`
+	Sum         Amount          `json:"sum"`
+	ProcessedAt time.Time       `json:"processed_at"`
+}
+
+// OrderUploadStatus - результат загрузки номера заказа в пакете.
+type OrderUploadStatus string
+
+const (
+	// OrderUploadAccepted - заказ принят в обработку.
+	OrderUploadAccepted OrderUploadStatus = "ACCEPTED"
+	// OrderUploadAlreadyUploaded - заказ уже был загружен пользователем.
+	OrderUploadAlreadyUploaded OrderUploadStatus = "ALREADY_UPLOADED"
+	// OrderUploadConflict - заказ уже был загружен другим пользователем.
+	OrderUploadConflict OrderUploadStatus = "CONFLICT"
+	// OrderUploadInvalid - неверный формат номера заказа.
+	OrderUploadInvalid OrderUploadStatus = "INVALID"
+)
+
+// OrderUploadResult - результат загрузки номера заказа Number в пакете.
+type OrderUploadResult struct {
+	Number string            `json:"number"`
+	Status OrderUploadStatus `json:"status"`
+}
+
+// StatusCheckJob - задача на проверку статуса заказа Num. Violations - количество ответов
+// системы расчёта начислений по заказу подряд, не соответствующих протоколу.
 type StatusCheckJob struct {
-	Num    string
-	Status OrderStatus
+	Num        string
+	Status     OrderStatus
+	Violations int
 }
 
 type StatusCheckResult struct {
 	Num     string
 	Status  OrderStatus
-	Accrual float64
+	Accrual Amount
 }
 
 type OrderStatus string
@@ -29,6 +75,39 @@ const (
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
 )
 
+// orderStatusTransitions - допустимые переходы между статусами заказа. Статусы
+// OrderStatusInvalid и OrderStatusProcessed являются окончательными.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusNew:        {OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed},
+	OrderStatusProcessing: {OrderStatusInvalid, OrderStatusProcessed},
+}
+
+// CanTransitionTo сообщает, может ли заказ перейти из статуса s в статус next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Valid сообщает, является ли s известным статусом заказа.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusNew, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed:
+		return true
+	}
+
+	return false
+}
+
+// IsFinal сообщает, является ли статус окончательным.
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusInvalid || s == OrderStatusProcessed
+}
+
 func NewStatusCheckJob(num string) StatusCheckJob {
 	return StatusCheckJob{
 		Num:    num,
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrderStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{OrderStatusNew, OrderStatusProcessing, true},
+		{OrderStatusNew, OrderStatusProcessed, true},
+		{OrderStatusNew, OrderStatusInvalid, true},
+		{OrderStatusProcessing, OrderStatusProcessed, true},
+		{OrderStatusProcessing, OrderStatusInvalid, true},
+		{OrderStatusProcessing, OrderStatusNew, false},
+		{OrderStatusProcessed, OrderStatusProcessing, false},
+		{OrderStatusProcessed, OrderStatusInvalid, false},
+		{OrderStatusInvalid, OrderStatusProcessed, false},
+		{OrderStatusNew, OrderStatusNew, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.from.CanTransitionTo(tt.to), "переход %s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestOrderStatus_Valid(t *testing.T) {
+	for _, s := range []OrderStatus{OrderStatusNew, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed} {
+		assert.True(t, s.Valid(), "статус %s", s)
+	}
+	assert.False(t, OrderStatus("DONE").Valid(), "неизвестный статус")
+	assert.False(t, OrderStatus("").Valid(), "пустой статус")
+}
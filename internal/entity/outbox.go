@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEventType - тип доменного события, которое публикуется во внешние системы
+// через таблицу outbox.
+type OutboxEventType string
+
+const (
+	// OutboxEventOrderCreated - пользователь загрузил номер заказа.
+	OutboxEventOrderCreated OutboxEventType = "order.created"
+	// OutboxEventOrderStatusUpdated - изменился статус заказа.
+	OutboxEventOrderStatusUpdated OutboxEventType = "order.status_updated"
+	// OutboxEventOrderCancelled - пользователь отменил заказ до окончания обработки.
+	OutboxEventOrderCancelled OutboxEventType = "order.cancelled"
+	// OutboxEventPointsWithdrawn - баллы списаны в счёт оплаты заказа.
+	OutboxEventPointsWithdrawn OutboxEventType = "points.withdrawn"
+	// OutboxEventPointsRefunded - баллы, списанные в счёт оплаты заказа, возвращены.
+	OutboxEventPointsRefunded OutboxEventType = "points.refunded"
+	// OutboxEventPointsHeld - баллы зарезервированы в счёт оплаты заказа.
+	OutboxEventPointsHeld OutboxEventType = "points.held"
+	// OutboxEventPointsReleased - резерв отменен или истек, баллы возвращены.
+	OutboxEventPointsReleased OutboxEventType = "points.released"
+	// OutboxEventPointsTransferred - баллы переведены другому пользователю.
+	OutboxEventPointsTransferred OutboxEventType = "points.transferred"
+	// OutboxEventPointsExpired - истек срок действия баллов.
+	OutboxEventPointsExpired OutboxEventType = "points.expired"
+)
+
+// OutboxEvent - доменное событие, записанное в одной транзакции с изменением состояния.
+// ID возрастает в порядке записи событий и позволяет получателю отбрасывать повторы.
+type OutboxEvent struct {
+	ID        int             `json:"id"`
+	Type      OutboxEventType `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
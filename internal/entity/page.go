@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor - позиция элемента в списке, отсортированном по времени и идентификатору. Идентификатор
+// различает элементы с одинаковым временем, поэтому порядок списка устойчив.
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// String возвращает курсор в виде непрозрачной строки для передачи в параметрах запроса.
+func (c Cursor) String() string {
+	s := strconv.FormatInt(c.Time.UnixNano(), 10) + ":" + strconv.Itoa(c.ID)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// ParseCursor преобразует строку, полученную методом Cursor.String, в курсор.
+func ParseCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nsec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	c := Cursor{Time: time.Unix(0, nsec)}
+	if c.ID, err = strconv.Atoi(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// ListFilter - условия выборки страницы списка. Пустой Statuses не ограничивает статусы,
+// нулевые From и To - период (To не включается в период). After - курсор последнего элемента
+// предыдущей страницы или nil для первой страницы, нулевой Limit означает выборку всех элементов.
+type ListFilter struct {
+	Statuses   []string
+	From       time.Time
+	To         time.Time
+	Descending bool
+	After      *Cursor
+	Limit      int
+}
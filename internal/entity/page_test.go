@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Time: time.Date(2023, 1, 10, 12, 30, 0, 123456789, time.UTC), ID: 42}
+
+	parsed, err := ParseCursor(c.String())
+	require.NoError(t, err)
+	assert.True(t, c.Time.Equal(parsed.Time), "время курсора")
+	assert.Equal(t, c.ID, parsed.ID, "идентификатор курсора")
+
+	for _, s := range []string{"", "!!!", "MTIz", "YWJjOjE", "MTIzOmFiYw"} {
+		_, err = ParseCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// Partner - партнер, который выполняет операции от имени своих клиентов - пользователей UserIDs,
+// аутентифицируясь ключом API Key. Ключ возвращается только при создании партнера, хранится
+// только его хэш KeyHash.
+type Partner struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	UserIDs   []int     `json:"user_ids"`
+	Key       string    `json:"key,omitempty"`
+	KeyHash   string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PartnerScope - пользователи, от имени которых партнер может выполнять операции: клиенты
+// партнера UserIDs или все пользователи, если AllUsers (для администратора).
+type PartnerScope struct {
+	UserIDs  []int
+	AllUsers bool
+}
+
+// Contains сообщает, входит ли пользователь userID в s.
+func (s PartnerScope) Contains(userID int) bool {
+	if s.AllUsers {
+		return true
+	}
+
+	for _, id := range s.UserIDs {
+		if id == userID {
+			return true
+		}
+	}
+
+	return false
+}
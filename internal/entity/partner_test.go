@@ -0,0 +1,13 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPartnerScope_Contains(t *testing.T) {
+	assert.True(t, PartnerScope{UserIDs: []int{1, 2}}.Contains(2), "клиент партнера")
+	assert.False(t, PartnerScope{UserIDs: []int{1, 2}}.Contains(3), "пользователь не является клиентом партнера")
+	assert.False(t, PartnerScope{}.Contains(1), "партнер без клиентов")
+	assert.True(t, PartnerScope{AllUsers: true}.Contains(3), "все пользователи")
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// StatementEntry - операция в выписке по счёту пользователя. Sum - изменение доступного
+// баланса (отрицательное для списаний), Balance - доступный баланс после операции.
+type StatementEntry struct {
+	ID          int             `json:"id"`
+	Type        TransactionType `json:"type"`
+	Order       string          `json:"order,omitempty"`
+	Sum         Amount          `json:"sum"`
+	Balance     Amount          `json:"balance"`
+	ProcessedAt time.Time       `json:"processed_at"`
+}
+
+// Statement - страница выписки. NextCursor передается для получения следующей страницы
+// и пуст, если страница последняя.
+type Statement struct {
+	Entries    []StatementEntry `json:"entries"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// StatementFilter - условия выборки операций выписки. Нулевые From и To не ограничивают
+// период, To не включается в период. After - идентификатор последней операции предыдущей
+// страницы, Limit - максимальное количество операций на странице.
+type StatementFilter struct {
+	From  time.Time
+	To    time.Time
+	After int
+	Limit int
+}
+
+// MonthlyStatement - выписка по счёту за месяц, начинающийся в момент Month, с доступным
+// балансом на начало и конец месяца.
+type MonthlyStatement struct {
+	Month   time.Time
+	Opening Amount
+	Closing Amount
+	Entries []StatementEntry
+}
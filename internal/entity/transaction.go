@@ -3,11 +3,61 @@ package entity
 import "time"
 
 type Transaction struct {
-	Order       string    `json:"order"`
-	Sum         float64   `json:"sum"`
-	ProcessedAt time.Time `json:"processed_at"`
+	ID           int          `json:"-"`
+	Order        string       `json:"order"`
+	Sum          Amount       `json:"sum"`
+	ProcessedAt  time.Time    `json:"processed_at"`
+	Refunded     Amount       `json:"refunded,omitempty"`
+	RefundStatus RefundStatus `json:"refund_status,omitempty"`
 }
 
 type TransactionType string
 
-const TransactionTypeOut TransactionType = "OUT"
+const (
+	TransactionTypeIn     TransactionType = "IN"
+	TransactionTypeOut    TransactionType = "OUT"
+	TransactionTypeRefund TransactionType = "REFUND"
+	// TransactionTypeHold - резервирование баллов, TransactionTypeRelease - возврат
+	// зарезервированных баллов при отмене или истечении резерва. Списание резерва
+	// записывается как TransactionTypeOut.
+	TransactionTypeHold    TransactionType = "HOLD"
+	TransactionTypeRelease TransactionType = "RELEASE"
+	// TransactionTypeExpire - списание баллов с истекшим сроком действия.
+	TransactionTypeExpire TransactionType = "EXPIRE"
+	// TransactionTypeTransfer - перевод баллов со счёта одного пользователя на счёт другого.
+	TransactionTypeTransfer TransactionType = "TRANSFER"
+)
+
+// RefundStatus - состояние возврата баллов, списанных в счёт оплаты заказа.
+type RefundStatus string
+
+const (
+	RefundStatusPartial RefundStatus = "PARTIALLY_REFUNDED"
+	RefundStatusFull    RefundStatus = "REFUNDED"
+	// RefundStatusNone - по списанию не было возвратов. Используется только для выборки
+	// списаний по состоянию возврата и не передается в данных списания.
+	RefundStatusNone RefundStatus = "NOT_REFUNDED"
+)
+
+// Valid сообщает, является ли s известным состоянием возврата, включая RefundStatusNone.
+func (s RefundStatus) Valid() bool {
+	switch s {
+	case RefundStatusNone, RefundStatusPartial, RefundStatusFull:
+		return true
+	}
+
+	return false
+}
+
+// NewRefundStatus возвращает состояние возврата списания суммы sum, если из нее
+// возвращено refunded. Если возвратов не было, возвращает пустую строку.
+func NewRefundStatus(sum, refunded Amount) RefundStatus {
+	switch {
+	case refunded <= 0:
+		return ""
+	case refunded < sum:
+		return RefundStatusPartial
+	default:
+		return RefundStatusFull
+	}
+}
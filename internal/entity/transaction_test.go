@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewRefundStatus(t *testing.T) {
+	assert.Equal(t, RefundStatus(""), NewRefundStatus(100, 0), "без возвратов")
+	assert.Equal(t, RefundStatusPartial, NewRefundStatus(100, 40), "частичный возврат")
+	assert.Equal(t, RefundStatusFull, NewRefundStatus(100, 100), "полный возврат")
+}
+
+func TestRefundStatus_Valid(t *testing.T) {
+	for _, s := range []RefundStatus{RefundStatusNone, RefundStatusPartial, RefundStatusFull} {
+		assert.True(t, s.Valid(), "состояние %s", s)
+	}
+	assert.False(t, RefundStatus("REFUNDING").Valid(), "неизвестное состояние")
+	assert.False(t, RefundStatus("").Valid(), "пустое состояние")
+}
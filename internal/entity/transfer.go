@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Transfer - перевод баллов между пользователями с точки зрения одного из участников.
+// Counterparty - логин второго участника перевода.
+type Transfer struct {
+	ID           int               `json:"id"`
+	Direction    TransferDirection `json:"direction"`
+	Counterparty string            `json:"counterparty"`
+	Sum          Amount            `json:"sum"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type TransferDirection string
+
+const (
+	TransferDirectionIn  TransferDirection = "IN"
+	TransferDirectionOut TransferDirection = "OUT"
+)
@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEvent - событие, о котором партнеры получают уведомления.
+type WebhookEvent string
+
+const (
+	// WebhookEventOrderProcessed - заказ обработан, баллы начислены.
+	WebhookEventOrderProcessed WebhookEvent = "order.processed"
+	// WebhookEventOrderInvalid - система расчёта отказала в начислении баллов за заказ.
+	WebhookEventOrderInvalid WebhookEvent = "order.invalid"
+	// WebhookEventPointsWithdrawn - баллы списаны в счёт оплаты заказа.
+	WebhookEventPointsWithdrawn WebhookEvent = "points.withdrawn"
+)
+
+// Webhook - подписка партнера на уведомления о событиях Events его клиентов - пользователей
+// UserIDs. Подписка с AllUsers получает уведомления о событиях всех пользователей. Уведомления
+// отправляются POST-запросом на URL и подписываются ключом Secret, который возвращается только
+// при создании подписки.
+type Webhook struct {
+	ID        int            `json:"id"`
+	URL       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	UserIDs   []int          `json:"user_ids,omitempty"`
+	AllUsers  bool           `json:"all_users"`
+	Secret    string         `json:"secret,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// DeliveryStatus - состояние доставки уведомления. Недоставленное уведомление остается
+// в статусе DeliveryStatusPending до исчерпания попыток, после чего переходит в DeliveryStatusFailed.
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "PENDING"
+	DeliveryStatusDelivered DeliveryStatus = "DELIVERED"
+	DeliveryStatusFailed    DeliveryStatus = "FAILED"
+)
+
+// WebhookDelivery - уведомление о событии по подписке WebhookID и результат последней попытки
+// его доставки. URL и Secret - адрес и ключ подписи подписки на момент доставки.
+type WebhookDelivery struct {
+	ID            int             `json:"id"`
+	WebhookID     int             `json:"webhook_id"`
+	Event         WebhookEvent    `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	URL           string          `json:"-"`
+	Secret        string          `json:"-"`
+}
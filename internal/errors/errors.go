@@ -3,9 +3,23 @@ package errors
 import "errors"
 
 var (
-	ErrUserExists           = errors.New("user exists")
-	ErrUserNotFound         = errors.New("user not found")
-	ErrOrderExists          = errors.New("order exists")
-	ErrOrderNotBelongToUser = errors.New("order does not belong to user")
-	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrUserExists            = errors.New("user exists")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrOrderExists           = errors.New("order exists")
+	ErrOrderNotBelongToUser  = errors.New("order does not belong to user")
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrDeadLetterNotFound    = errors.New("dead letter not found")
+	ErrOrderNotFound         = errors.New("order not found")
+	ErrInvalidTransition     = errors.New("invalid order status transition")
+	ErrWithdrawalNotFound    = errors.New("withdrawal not found")
+	ErrRefundExceedsSum      = errors.New("refund exceeds withdrawn sum")
+	ErrHoldNotFound          = errors.New("hold not found")
+	ErrHoldNotActive         = errors.New("hold is not active")
+	ErrRecipientNotFound     = errors.New("transfer recipient not found")
+	ErrTransferToSelf        = errors.New("transfer to self")
+	ErrTransferLimitExceeded = errors.New("daily transfer limit exceeded")
+	ErrWebhookNotFound       = errors.New("webhook not found")
+	ErrPartnerNotFound       = errors.New("partner not found")
+	ErrOrderNotCancellable   = errors.New("order cannot be cancelled")
+	ErrProtocolViolation     = errors.New("accrual protocol violation")
 )
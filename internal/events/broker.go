@@ -0,0 +1,92 @@
+package events
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"sync"
+)
+
+// Broker рассылает события подписчикам внутри экземпляра сервиса. Подписчик получает только
+// события своего пользователя.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan entity.Event]struct{}
+	buffer      int
+	closed      bool
+}
+
+// NewBroker возвращает брокер событий. Каждому подписчику выделяется буфер на buffer событий.
+func NewBroker(buffer int) *Broker {
+	return &Broker{
+		subscribers: make(map[int]map[chan entity.Event]struct{}),
+		buffer:      buffer,
+	}
+}
+
+// Subscribe подписывает на события пользователя userID. Возвращает канал событий и функцию
+// отмены подписки. Канал закрывается при отмене подписки, закрытии брокера или если подписчик
+// не успевает читать события и его буфер переполнен: в этом случае клиенту нужно подключиться
+// заново и получить актуальное состояние.
+func (b *Broker) Subscribe(userID int) (<-chan entity.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan entity.Event, b.buffer)
+	if b.closed {
+		close(ch)
+
+		return ch, func() {}
+	}
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan entity.Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.remove(userID, ch)
+	}
+}
+
+// Publish отправляет событие подписчикам его пользователя. Publish не блокируется.
+func (b *Broker) Publish(e entity.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+			b.remove(e.UserID, ch)
+		}
+	}
+}
+
+// Close закрывает каналы всех подписчиков. Новые подписки после закрытия получают
+// закрытый канал.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for userID, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			b.remove(userID, ch)
+		}
+	}
+}
+
+// remove отменяет подписку и закрывает канал, если подписка еще действует.
+func (b *Broker) remove(userID int, ch chan entity.Event) {
+	if _, ok := b.subscribers[userID][ch]; !ok {
+		return
+	}
+
+	delete(b.subscribers[userID], ch)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	close(ch)
+}
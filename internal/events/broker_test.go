@@ -0,0 +1,48 @@
+package events
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBroker_Publish(t *testing.T) {
+	var (
+		b     = NewBroker(1)
+		order = entity.Event{UserID: 1, Type: entity.EventTypeOrder, Data: []byte(`{}`)}
+		other = entity.Event{UserID: 2, Type: entity.EventTypeOrder, Data: []byte(`{}`)}
+	)
+	first, unsubscribeFirst := b.Subscribe(1)
+	second, unsubscribeSecond := b.Subscribe(1)
+	defer unsubscribeSecond()
+
+	b.Publish(order)
+	b.Publish(other)
+	assert.Equal(t, order, <-first, "событие получено первым подписчиком")
+	assert.Equal(t, order, <-second, "событие получено вторым подписчиком")
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	_, ok := <-first
+	assert.False(t, ok, "канал закрыт после отмены подписки")
+
+	b.Publish(order)
+	b.Publish(order)
+	assert.Equal(t, order, <-second, "событие до переполнения буфера")
+	_, ok = <-second
+	assert.False(t, ok, "канал закрыт при переполнении буфера")
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(1)
+	events, unsubscribe := b.Subscribe(1)
+
+	b.Close()
+	_, ok := <-events
+	assert.False(t, ok, "канал закрыт при закрытии брокера")
+	unsubscribe()
+
+	events, _ = b.Subscribe(1)
+	_, ok = <-events
+	assert.False(t, ok, "подписка после закрытия брокера")
+}
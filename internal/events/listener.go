@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"log"
+	"time"
+)
+
+var errUnsupportedDriver = errors.New("listening requires pgx driver connection")
+
+// Listener получает события из канала уведомлений PostgreSQL и передает их брокеру. События
+// публикуются в канал в транзакциях, которые их вызвали, поэтому подписчики любого экземпляра
+// сервиса получают только события зафиксированных изменений.
+type Listener struct {
+	db        *sql.DB
+	channel   string
+	publisher Publisher
+	interval  time.Duration
+}
+
+type Publisher interface {
+	Publish(e entity.Event)
+}
+
+// NewListener возвращает слушателя канала channel. При потере соединения слушатель
+// подключается заново через interval.
+func NewListener(db *sql.DB, channel string, p Publisher, interval time.Duration) *Listener {
+	return &Listener{
+		db:        db,
+		channel:   channel,
+		publisher: p,
+		interval:  interval,
+	}
+}
+
+// Run слушает канал уведомлений до отмены ctx.
+func (l *Listener) Run(ctx context.Context) {
+	for {
+		if err := l.listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("ошибка получения событий: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.interval):
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errUnsupportedDriver
+		}
+
+		pc := c.Conn()
+		if _, err := pc.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+			return err
+		}
+
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			l.dispatch(n.Payload)
+		}
+	})
+}
+
+// dispatch передает брокеру событие из уведомления. Некорректные уведомления пропускаются.
+func (l *Listener) dispatch(payload string) {
+	e := entity.Event{}
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		log.Printf("некорректное событие %q: %v", payload, err)
+
+		return
+	}
+
+	l.publisher.Publish(e)
+}
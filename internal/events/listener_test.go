@@ -0,0 +1,27 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestListener_dispatch(t *testing.T) {
+	var (
+		b           = NewBroker(1)
+		l           = NewListener(nil, "events", b, time.Second)
+		events, _   = b.Subscribe(1)
+		payload     = `{"user_id": 1, "type": "balance", "data": {"current": 500.00, "held": 0, "withdrawn": 0}}`
+		wantBalance = json.RawMessage(`{"current": 500.00, "held": 0, "withdrawn": 0}`)
+	)
+
+	l.dispatch("not json")
+	l.dispatch(payload)
+	e := <-events
+	assert.Equal(t, 1, e.UserID)
+	assert.Equal(t, entity.EventTypeBalance, e.Type)
+	assert.JSONEq(t, string(wantBalance), string(e.Data))
+	assert.Len(t, events, 0, "некорректное уведомление пропущено")
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"io"
+)
+
+// bom - метка порядка байтов UTF-8, по которой табличные редакторы определяют кодировку файла.
+const bom = "\ufeff"
+
+// WriteCSV записывает операции выписки в w в формате CSV с заголовком. Суммы, даты, названия
+// операций и разделитель полей соответствуют локали l.
+func WriteCSV(w io.Writer, entries []entity.StatementEntry, l Locale) error {
+	if _, err := io.WriteString(w, bom); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = l.Comma
+	err := cw.Write([]string{l.labels.date, l.labels.operation, l.labels.order, l.labels.sum, l.labels.balance})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		err = cw.Write([]string{
+			l.DateTime(e.ProcessedAt),
+			l.TransactionType(e.Type),
+			e.Order,
+			l.Number(e.Sum),
+			l.Number(e.Balance),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"bytes"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	var (
+		tm      = time.Date(2023, 1, 31, 9, 5, 0, 0, time.UTC)
+		entries = []entity.StatementEntry{
+			{ID: 1, Type: entity.TransactionTypeIn, Order: "2377225624", Sum: 1500_00, Balance: 1500_00, ProcessedAt: tm},
+			{ID: 2, Type: entity.TransactionTypeTransfer, Sum: -250_50, Balance: 1249_50, ProcessedAt: tm},
+		}
+	)
+
+	b := &bytes.Buffer{}
+	require.NoError(t, WriteCSV(b, entries, RU))
+	assert.Equal(
+		t,
+		bom+"Дата;Операция;Заказ;Сумма;Баланс\n"+
+			"31.01.2023 09:05;Начисление;2377225624;1500,00;1500,00\n"+
+			"31.01.2023 09:05;Перевод;;-250,50;1249,50\n",
+		b.String(),
+	)
+
+	b.Reset()
+	require.NoError(t, WriteCSV(b, entries, EN))
+	assert.Equal(
+		t,
+		bom+"Date,Operation,Order,Amount,Balance\n"+
+			"01/31/2023 09:05,Accrual,2377225624,1500.00,1500.00\n"+
+			"01/31/2023 09:05,Transfer,,-250.50,1249.50\n",
+		b.String(),
+	)
+}
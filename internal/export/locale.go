@@ -0,0 +1,172 @@
+package export
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"strings"
+	"time"
+)
+
+// Locale - правила форматирования чисел и дат и названия, используемые в выписках.
+type Locale struct {
+	// Tag - код языка, например "ru".
+	Tag string
+	// Comma - разделитель полей CSV. Если десятичный разделитель - запятая, поля разделяются
+	// точкой с запятой, как ожидают табличные редакторы с такими региональными настройками.
+	Comma          rune
+	decimal        string
+	group          string
+	dateLayout     string
+	dateTimeLayout string
+	months         [12]string
+	types          map[entity.TransactionType]string
+	labels         labels
+}
+
+// labels - подписи полей выписки.
+type labels struct {
+	date, operation, order, sum, balance   string
+	title, opening, closing, credit, debit string
+	page, empty                            string
+}
+
+var (
+	RU = Locale{
+		Tag:            "ru",
+		Comma:          ';',
+		decimal:        ",",
+		group:          " ",
+		dateLayout:     "02.01.2006",
+		dateTimeLayout: "02.01.2006 15:04",
+		months: [12]string{
+			"январь", "февраль", "март", "апрель", "май", "июнь",
+			"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь",
+		},
+		types: map[entity.TransactionType]string{
+			entity.TransactionTypeIn:       "Начисление",
+			entity.TransactionTypeOut:      "Списание",
+			entity.TransactionTypeRefund:   "Возврат",
+			entity.TransactionTypeHold:     "Резервирование",
+			entity.TransactionTypeRelease:  "Отмена резерва",
+			entity.TransactionTypeExpire:   "Истечение срока",
+			entity.TransactionTypeTransfer: "Перевод",
+		},
+		labels: labels{
+			date:      "Дата",
+			operation: "Операция",
+			order:     "Заказ",
+			sum:       "Сумма",
+			balance:   "Баланс",
+			title:     "Выписка по счёту баллов",
+			opening:   "Баланс на начало периода",
+			closing:   "Баланс на конец периода",
+			credit:    "Зачислено",
+			debit:     "Списано",
+			page:      "Страница",
+			empty:     "Операций за период не было",
+		},
+	}
+	EN = Locale{
+		Tag:            "en",
+		Comma:          ',',
+		decimal:        ".",
+		group:          ",",
+		dateLayout:     "01/02/2006",
+		dateTimeLayout: "01/02/2006 15:04",
+		months: [12]string{
+			"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December",
+		},
+		types: map[entity.TransactionType]string{
+			entity.TransactionTypeIn:       "Accrual",
+			entity.TransactionTypeOut:      "Withdrawal",
+			entity.TransactionTypeRefund:   "Refund",
+			entity.TransactionTypeHold:     "Hold",
+			entity.TransactionTypeRelease:  "Hold release",
+			entity.TransactionTypeExpire:   "Expiration",
+			entity.TransactionTypeTransfer: "Transfer",
+		},
+		labels: labels{
+			date:      "Date",
+			operation: "Operation",
+			order:     "Order",
+			sum:       "Amount",
+			balance:   "Balance",
+			title:     "Points account statement",
+			opening:   "Opening balance",
+			closing:   "Closing balance",
+			credit:    "Credited",
+			debit:     "Debited",
+			page:      "Page",
+			empty:     "No operations in this period",
+		},
+	}
+)
+
+// ParseLocale выбирает локаль по коду языка или значению заголовка Accept-Language, например
+// "en-US,en;q=0.9". Используется первый поддерживаемый язык, по умолчанию - RU.
+func ParseLocale(s string) Locale {
+	for _, part := range strings.Split(s, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		switch lang {
+		case RU.Tag:
+			return RU
+		case EN.Tag:
+			return EN
+		}
+	}
+
+	return RU
+}
+
+// Number возвращает сумму с двумя знаками после десятичного разделителя локали без разделителей
+// групп разрядов, например "-1234,50". Такую запись табличные редакторы распознают как число.
+func (l Locale) Number(a entity.Amount) string {
+	s := a.String()
+
+	return strings.Replace(s, ".", l.decimal, 1)
+}
+
+// Money возвращает сумму с разделителями групп разрядов, например "1 234,50".
+func (l Locale) Money(a entity.Amount) string {
+	s := a.String()
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+	b := strings.Builder{}
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(r)
+	}
+
+	return sign + b.String() + l.decimal + fraction
+}
+
+// Date возвращает дату в формате локали.
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.dateLayout)
+}
+
+// DateTime возвращает дату и время в формате локали.
+func (l Locale) DateTime(t time.Time) string {
+	return t.Format(l.dateTimeLayout)
+}
+
+// Month возвращает название месяца и год, например "январь 2023".
+func (l Locale) Month(t time.Time) string {
+	return l.months[t.Month()-1] + " " + t.Format("2006")
+}
+
+// TransactionType возвращает название типа операции. Для неизвестного типа возвращается сам тип.
+func (l Locale) TransactionType(t entity.TransactionType) string {
+	if name, ok := l.types[t]; ok {
+		return name
+	}
+
+	return string(t)
+}
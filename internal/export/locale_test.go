@@ -0,0 +1,43 @@
+package export
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "", want: "ru"},
+		{s: "en", want: "en"},
+		{s: "en-US,en;q=0.9,ru;q=0.8", want: "en"},
+		{s: "de-DE, ru-RU;q=0.8", want: "ru"},
+		{s: "fr", want: "ru"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseLocale(tt.s).Tag, tt.s)
+	}
+}
+
+func TestLocale_Format(t *testing.T) {
+	var (
+		a  = entity.Amount(-1234567_50)
+		tm = time.Date(2023, 1, 31, 9, 5, 0, 0, time.UTC)
+	)
+
+	assert.Equal(t, "-1234567,50", RU.Number(a))
+	assert.Equal(t, "-1234567.50", EN.Number(a))
+	assert.Equal(t, "-1 234 567,50", RU.Money(a))
+	assert.Equal(t, "-1,234,567.50", EN.Money(a))
+	assert.Equal(t, "100,00", RU.Money(100_00), "сумма без разделителей разрядов")
+	assert.Equal(t, "31.01.2023", RU.Date(tm))
+	assert.Equal(t, "01/31/2023 09:05", EN.DateTime(tm))
+	assert.Equal(t, "январь 2023", RU.Month(tm))
+	assert.Equal(t, "January 2023", EN.Month(tm))
+	assert.Equal(t, "Начисление", RU.TransactionType(entity.TransactionTypeIn))
+	assert.Equal(t, "UNKNOWN", EN.TransactionType("UNKNOWN"), "неизвестный тип операции")
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"io"
+	"strings"
+)
+
+// Размеры страницы A4 и параметры разметки выписки в пунктах.
+const (
+	pageWidth   = 595
+	pageHeight  = 842
+	pageMargin  = 50
+	fontSize    = 10
+	titleSize   = 14
+	lineHeight  = 14
+	footerY     = 30
+	firstObject = 5
+)
+
+// columns - горизонтальные позиции столбцов таблицы операций.
+var columns = [5]int{pageMargin, 150, 265, 385, 475}
+
+// WritePDF записывает в w выписку за месяц в формате PDF: балансы на начало и конец месяца,
+// суммы зачислений и списаний и таблицу операций. Документ формируется без внешних сервисов
+// и шрифтов: текст набирается стандартным шрифтом Helvetica, кириллица кодируется
+// по таблице cp1251, поэтому символы вне ASCII и кириллицы заменяются на "?".
+func WritePDF(w io.Writer, s entity.MonthlyStatement, l Locale) error {
+	pages := layout(s, l)
+
+	doc := &pdfWriter{}
+	doc.header()
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstObject+2*i)
+	}
+	doc.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	doc.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	doc.object(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding 4 0 R >>")
+	doc.object(4, "<< /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences ["+cyrillicDifferences()+"] >>")
+	for i, content := range pages {
+		page, stream := firstObject+2*i, firstObject+2*i+1
+		doc.object(page, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth,
+			pageHeight,
+			stream,
+		))
+		doc.object(stream, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	doc.trailer()
+
+	_, err := w.Write(doc.buf.Bytes())
+
+	return err
+}
+
+// layout разбивает выписку на страницы и возвращает содержимое каждой страницы.
+func layout(s entity.MonthlyStatement, l Locale) []string {
+	var (
+		pages  []string
+		page   = &bytes.Buffer{}
+		y      = pageHeight - pageMargin
+		credit entity.Amount
+		debit  entity.Amount
+	)
+	for _, e := range s.Entries {
+		if e.Sum > 0 {
+			credit += e.Sum
+		} else {
+			debit -= e.Sum
+		}
+	}
+
+	text(page, pageMargin, y, titleSize, l.labels.title+": "+l.Month(s.Month))
+	y -= 2 * lineHeight
+	for _, row := range [][2]string{
+		{l.labels.opening, l.Money(s.Opening)},
+		{l.labels.credit, l.Money(credit)},
+		{l.labels.debit, l.Money(debit)},
+		{l.labels.closing, l.Money(s.Closing)},
+	} {
+		text(page, columns[0], y, fontSize, row[0])
+		text(page, columns[3], y, fontSize, row[1])
+		y -= lineHeight
+	}
+	y -= lineHeight
+
+	if len(s.Entries) == 0 {
+		text(page, pageMargin, y, fontSize, l.labels.empty)
+	}
+
+	tableHeader := []string{l.labels.date, l.labels.operation, l.labels.order, l.labels.sum, l.labels.balance}
+	for i, e := range s.Entries {
+		if i == 0 || y < pageMargin+lineHeight {
+			if i > 0 {
+				pages = append(pages, finishPage(page, len(pages)+1, l))
+				page, y = &bytes.Buffer{}, pageHeight-pageMargin
+			}
+			row(page, y, tableHeader)
+			y -= lineHeight
+		}
+
+		row(page, y, []string{l.DateTime(e.ProcessedAt), l.TransactionType(e.Type), e.Order, l.Money(e.Sum), l.Money(e.Balance)})
+		y -= lineHeight
+	}
+
+	return append(pages, finishPage(page, len(pages)+1, l))
+}
+
+func finishPage(page *bytes.Buffer, n int, l Locale) string {
+	text(page, pageMargin, footerY, fontSize, fmt.Sprintf("%s %d", l.labels.page, n))
+
+	return strings.TrimSuffix(page.String(), "\n")
+}
+
+func row(page *bytes.Buffer, y int, cells []string) {
+	for i, c := range cells {
+		text(page, columns[i], y, fontSize, c)
+	}
+}
+
+func text(page *bytes.Buffer, x, y, size int, s string) {
+	_, _ = fmt.Fprintf(page, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n", size, x, y, encodeText(s))
+}
+
+// encodeText кодирует строку по таблице cp1251 и экранирует служебные символы строк PDF.
+func encodeText(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 'А' && r <= 'я':
+			b.WriteByte(byte(r - 'А' + 0xc0))
+		case r == 'Ё':
+			b.WriteByte(0xa8)
+		case r == 'ё':
+			b.WriteByte(0xb8)
+		case r == '\u00a0':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
+
+// cyrillicDifferences возвращает таблицу замены кодов cp1251 на имена глифов кириллицы.
+// В стандартных именах глифов буква Ё следует за Е, поэтому номера остальных букв сдвинуты.
+func cyrillicDifferences() string {
+	names := []string{"168 /afii10023", "184 /afii10071", "192"}
+	for i := 0; i < 32; i++ {
+		names = append(names, fmt.Sprintf("/afii%d", cyrillicGlyph(10017, i)))
+	}
+	for i := 0; i < 32; i++ {
+		names = append(names, fmt.Sprintf("/afii%d", cyrillicGlyph(10065, i)))
+	}
+
+	return strings.Join(names, " ")
+}
+
+func cyrillicGlyph(first, i int) int {
+	if i < 6 {
+		return first + i
+	}
+
+	return first + i + 1
+}
+
+// pdfWriter формирует документ PDF и таблицу смещений его объектов.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (p *pdfWriter) header() {
+	p.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+}
+
+func (p *pdfWriter) object(n int, body string) {
+	for len(p.offsets) < n {
+		p.offsets = append(p.offsets, 0)
+	}
+	p.offsets[n-1] = p.buf.Len()
+	_, _ = fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+func (p *pdfWriter) trailer() {
+	xref := p.buf.Len()
+	_, _ = fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, o := range p.offsets {
+		_, _ = fmt.Fprintf(&p.buf, "%010d 00000 n \n", o)
+	}
+	_, _ = fmt.Fprintf(&p.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+}
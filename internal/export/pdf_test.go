@@ -0,0 +1,69 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWritePDF(t *testing.T) {
+	var (
+		month   = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		entries = make([]entity.StatementEntry, 60)
+		balance = entity.Amount(100_00)
+	)
+	for i := range entries {
+		balance += 10_00
+		entries[i] = entity.StatementEntry{
+			ID:          i + 1,
+			Type:        entity.TransactionTypeIn,
+			Order:       "2377225624",
+			Sum:         10_00,
+			Balance:     balance,
+			ProcessedAt: month.Add(time.Duration(i) * time.Hour),
+		}
+	}
+
+	b := &bytes.Buffer{}
+	require.NoError(t, WritePDF(b, entity.MonthlyStatement{Month: month, Opening: 100_00, Closing: balance, Entries: entries}, RU))
+	doc := b.Bytes()
+
+	assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+	assert.Equal(t, 2, bytes.Count(doc, []byte("/Type /Page ")), "операции не помещаются на одну страницу")
+	assert.Contains(t, string(doc), "(\xc2\xfb\xef\xe8\xf1\xea\xe0 \xef\xee \xf1\xf7\xb8\xf2\xf3 \xe1\xe0\xeb\xeb\xee\xe2: \xff\xed\xe2\xe0\xf0\xfc 2023)", "заголовок в кодировке cp1251")
+	assert.Contains(t, string(doc), "(700,00)", "баланс на конец периода")
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	require.NotNil(t, m)
+	xref, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(doc[xref:], []byte("xref\n")), "смещение таблицы объектов")
+
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	assert.Len(t, offsets, 8)
+	for i, o := range offsets {
+		n, err := strconv.Atoi(string(o[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(doc[n:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "смещение объекта %d", i+1)
+	}
+}
+
+func TestWritePDF_Empty(t *testing.T) {
+	b := &bytes.Buffer{}
+	require.NoError(t, WritePDF(b, entity.MonthlyStatement{Month: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)}, EN))
+
+	assert.Equal(t, 1, bytes.Count(b.Bytes(), []byte("/Type /Page ")))
+	assert.Contains(t, b.String(), "(Points account statement: February 2023)")
+	assert.Contains(t, b.String(), "(No operations in this period)")
+}
+
+func TestEncodeText(t *testing.T) {
+	assert.Equal(t, "\xc0\xff\xa8\xb8 \\(1\\) ?", encodeText("АяЁё (1) €"))
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"net/http"
+)
+
+type DeadLetter struct {
+	processor DeadLetterProcessor
+}
+
+type DeadLetterProcessor interface {
+	GetAll(ctx context.Context) ([]entity.DeadLetter, error)
+	Retry(ctx context.Context, id int) error
+	Discard(ctx context.Context, id int) error
+}
+
+func NewDeadLetter(p DeadLetterProcessor) *DeadLetter {
+	return &DeadLetter{processor: p}
+}
+
+// GetAll возвращает список результатов проверки статусов заказов, которые не удалось
+// сохранить. Если таких результатов нет, возвращает ответ с кодом 204.
+func (h *DeadLetter) GetAll(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.processor.GetAll(r.Context())
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(letters) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, letters, http.StatusOK)
+}
+
+// Retry повторно применяет сохраненный результат. Возвращает ответ с кодом 200 в случае
+// успеха, 404 - если результат не найден, 500 - если применить результат не удалось.
+func (h *DeadLetter) Retry(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.processor.Retry(r.Context(), id)
+	if errors.Is(err, inerr.ErrDeadLetterNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	} else if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Discard удаляет сохраненный результат без применения. Возвращает ответ с кодом 204
+// в случае успеха, 404 - если результат не найден.
+func (h *DeadLetter) Discard(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.processor.Discard(r.Context(), id)
+	if errors.Is(err, inerr.ErrDeadLetterNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	} else if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
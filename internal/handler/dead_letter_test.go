@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type DeadLetterProcessorMock struct {
+	mock.Mock
+}
+
+func (m *DeadLetterProcessorMock) GetAll(_ context.Context) ([]entity.DeadLetter, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.DeadLetter), args.Error(1)
+}
+
+func (m *DeadLetterProcessorMock) Retry(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func (m *DeadLetterProcessorMock) Discard(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func TestDeadLetter_GetAll(t *testing.T) {
+	var (
+		processor = &DeadLetterProcessorMock{}
+		empty     = &DeadLetterProcessorMock{}
+		letters   = []entity.DeadLetter{
+			{
+				ID:            1,
+				Order:         "148561163482734",
+				Status:        entity.OrderStatusProcessed,
+				Accrual:       100,
+				Error:         "connection refused",
+				Attempts:      1,
+				NextAttemptAt: time.Now(),
+				CreatedAt:     time.Now(),
+			},
+		}
+	)
+
+	processor.On("GetAll").Return(letters, nil).Once()
+	empty.On("GetAll").Return([]entity.DeadLetter{}, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, nil, (&DeadLetter{processor: processor}).GetAll)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	lettersJSON, err := json.Marshal(letters)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(lettersJSON), string(b))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, (&DeadLetter{processor: empty}).GetAll)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+	empty.AssertExpectations(t)
+}
+
+func TestDeadLetter_RetryAndDiscard(t *testing.T) {
+	processor := &DeadLetterProcessorMock{}
+	processor.On("Retry", 1).Return(nil).Once()
+	processor.On("Retry", 2).Return(inerr.ErrDeadLetterNotFound).Once()
+	processor.On("Retry", 3).Return(errors.New("")).Once()
+	processor.On("Discard", 1).Return(nil).Once()
+	processor.On("Discard", 2).Return(inerr.ErrDeadLetterNotFound).Once()
+	handler := DeadLetter{processor: processor}
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		id             string
+		wantStatusCode int
+	}{
+		{
+			name:           "успешное повторное применение",
+			handler:        handler.Retry,
+			id:             "1",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "повторное применение: результат не найден",
+			handler:        handler.Retry,
+			id:             "2",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "ошибка при повторном применении",
+			handler:        handler.Retry,
+			id:             "3",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "некорректный идентификатор",
+			handler:        handler.Retry,
+			id:             "id",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "успешное удаление",
+			handler:        handler.Discard,
+			id:             "1",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "удаление: результат не найден",
+			handler:        handler.Discard,
+			id:             "2",
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequestWithParams(http.MethodPost, nil, map[string]string{"id": tt.id}, tt.handler)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"net/http"
+	"time"
+)
+
+type Event struct {
+	subscriber    EventSubscriber
+	authenticator IdentityProvider
+	keepAlive     time.Duration
+}
+
+type EventSubscriber interface {
+	Subscribe(userID int) (<-chan entity.Event, func())
+}
+
+// NewEvent возвращает обработчик потока событий. Если событий нет, каждые keepAlive
+// в поток отправляется комментарий, чтобы прокси-серверы не закрывали соединение.
+func NewEvent(s EventSubscriber, a IdentityProvider, keepAlive time.Duration) *Event {
+	return &Event{
+		subscriber:    s,
+		authenticator: a,
+		keepAlive:     keepAlive,
+	}
+}
+
+// Stream передает пользователю изменения статусов его заказов и баланса в формате Server-Sent
+// Events: имя события - тип события, данные - заказ или баланс в формате JSON. Поток завершается
+// при отключении клиента, остановке сервера или если клиент не успевает получать события.
+func (h *Event) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		serverError(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+	events, unsubscribe := h.subscriber.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, e.Data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
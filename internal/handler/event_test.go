@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type EventSubscriberMock struct {
+	mock.Mock
+}
+
+func (m *EventSubscriberMock) Subscribe(userID int) (<-chan entity.Event, func()) {
+	args := m.Called(userID)
+
+	return args.Get(0).(<-chan entity.Event), args.Get(1).(func())
+}
+
+func TestEvent_Stream(t *testing.T) {
+	var (
+		userID        = 1
+		subscriber    = &EventSubscriberMock{}
+		authenticator = &AuthenticatorMock{}
+		events        = make(chan entity.Event, 2)
+		unsubscribed  = false
+		handler       = NewEvent(subscriber, authenticator, time.Hour)
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	subscriber.
+		On("Subscribe", userID).
+		Return((<-chan entity.Event)(events), func() { unsubscribed = true }).
+		Once()
+	events <- entity.Event{
+		UserID: userID,
+		Type:   entity.EventTypeOrder,
+		Data:   []byte(`{"number":"2377225624","status":"PROCESSED","accrual":500.00}`),
+	}
+	events <- entity.Event{
+		UserID: userID,
+		Type:   entity.EventTypeBalance,
+		Data:   []byte(`{"current":500.00,"held":0,"withdrawn":0}`),
+	}
+	close(events)
+
+	w := httptest.NewRecorder()
+	handler.Stream(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	result := w.Result()
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "text/event-stream", result.Header.Get("Content-Type"))
+	assert.Equal(
+		t,
+		"event: order\ndata: {\"number\":\"2377225624\",\"status\":\"PROCESSED\",\"accrual\":500.00}\n\n"+
+			"event: balance\ndata: {\"current\":500.00,\"held\":0,\"withdrawn\":0}\n\n",
+		w.Body.String(),
+	)
+	assert.True(t, unsubscribed, "подписка отменена после завершения потока")
+	authenticator.AssertExpectations(t)
+	subscriber.AssertExpectations(t)
+}
+
+func TestEvent_StreamClientDisconnected(t *testing.T) {
+	var (
+		userID        = 1
+		subscriber    = &EventSubscriberMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = NewEvent(subscriber, authenticator, time.Millisecond)
+		ctx, cancel   = context.WithCancel(context.Background())
+		done          = make(chan struct{})
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	subscriber.
+		On("Subscribe", userID).
+		Return((<-chan entity.Event)(make(chan entity.Event)), func() {}).
+		Once()
+
+	w := httptest.NewRecorder()
+	go func() {
+		defer close(done)
+		handler.Stream(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "поток не завершен после отключения клиента")
+	}
+	assert.Contains(t, w.Body.String(), ": keep-alive\n\n", "комментарий для поддержания соединения")
+}
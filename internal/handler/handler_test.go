@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
 	"github.com/stretchr/testify/mock"
 	"io"
 	"net/http"
@@ -34,6 +36,16 @@ func (m *AuthenticatorMock) UserIdentifier(_ *http.Request) (int, error) {
 	return args.Int(0), args.Error(1)
 }
 
+type PartnerScopeProviderMock struct {
+	mock.Mock
+}
+
+func (m *PartnerScopeProviderMock) PartnerScope(_ *http.Request) (entity.PartnerScope, error) {
+	args := m.Called()
+
+	return args.Get(0).(entity.PartnerScope), args.Error(1)
+}
+
 func sendTestRequest(method string, body io.Reader, handler http.HandlerFunc) *http.Response {
 	request := httptest.NewRequest(method, "/", body)
 	w := httptest.NewRecorder()
@@ -41,3 +53,25 @@ func sendTestRequest(method string, body io.Reader, handler http.HandlerFunc) *h
 
 	return w.Result()
 }
+
+func sendTestRequestWithQuery(method string, query string, handler http.HandlerFunc) *http.Response {
+	request := httptest.NewRequest(method, "/?"+query, nil)
+	w := httptest.NewRecorder()
+	handler(w, request)
+
+	return w.Result()
+}
+
+func sendTestRequestWithParams(method string, body io.Reader, params map[string]string, handler http.HandlerFunc) *http.Response {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+
+	request := httptest.NewRequest(method, "/", body)
+	request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	handler(w, request)
+
+	return w.Result()
+}
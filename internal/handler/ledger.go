@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"net/http"
+)
+
+type Ledger struct {
+	reconciler Reconciler
+}
+
+type Reconciler interface {
+	Reconcile(ctx context.Context) (entity.Reconciliation, error)
+}
+
+func NewLedger(r Reconciler) *Ledger {
+	return &Ledger{reconciler: r}
+}
+
+// Reconcile выполняет сверку журнала операций с баллами и возвращает ее результат в формате
+// {"balanced": false, "unbalanced_entries": [3], "negative_accounts": [], "mismatched_balances": [], "total": 0.00}.
+func (h *Ledger) Reconcile(w http.ResponseWriter, r *http.Request) {
+	res, err := h.reconciler.Reconcile(r.Context())
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, res, http.StatusOK)
+}
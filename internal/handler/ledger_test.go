@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type ReconcilerMock struct {
+	mock.Mock
+}
+
+func (m *ReconcilerMock) Reconcile(_ context.Context) (entity.Reconciliation, error) {
+	args := m.Called()
+
+	return args.Get(0).(entity.Reconciliation), args.Error(1)
+}
+
+func TestLedger_Reconcile(t *testing.T) {
+	var (
+		reconciler = &ReconcilerMock{}
+		res        = entity.Reconciliation{
+			UnbalancedEntries:  []int{3},
+			NegativeAccounts:   []int{},
+			MismatchedBalances: []int{2},
+			Total:              100,
+		}
+	)
+
+	reconciler.On("Reconcile").Return(res, nil).Once()
+	reconciler.On("Reconcile").Return(entity.Reconciliation{}, errors.New("")).Once()
+	handler := NewLedger(reconciler)
+
+	result := sendTestRequest(http.MethodGet, nil, handler.Reconcile)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "успешная сверка журнала")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"balanced": false, "unbalanced_entries": [3], "negative_accounts": [], "mismatched_balances": [2], "total": 1.00}`,
+		string(b),
+		"успешная сверка журнала",
+	)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, handler.Reconcile)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка при сверке журнала")
+	require.NoError(t, result.Body.Close())
+	reconciler.AssertExpectations(t)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"io"
@@ -17,7 +18,10 @@ type Order struct {
 
 type OrderProcessor interface {
 	Create(ctx context.Context, userID int, num string) error
-	GetAll(ctx context.Context, userID int) ([]entity.Order, error)
+	CreateBatch(ctx context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error)
+	GetAll(ctx context.Context, userID int, f entity.ListFilter) ([]entity.Order, string, error)
+	Get(ctx context.Context, userID int, num string) (entity.OrderDetail, error)
+	Delete(ctx context.Context, userID int, num string) error
 }
 
 func NewOrder(p OrderProcessor, a IdentityProvider, v Validator) *Order {
@@ -62,12 +66,74 @@ func (h *Order) Create(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(status)
 }
 
-// GetAll возвращает список загруженных заказов пользователя. Если заказов нет,
-// возвращает ответ с кодом 204.
+// maxOrderBatch - максимальное количество номеров заказов в одном пакете.
+const maxOrderBatch = 1000
+
+// CreateBatch обрабатывает запрос на добавление пакета заказов. Номера передаются JSON-массивом
+// строк (Content-Type: application/json) или текстом, по одному номеру в строке. Номера с неверным
+// форматом не добавляются, остальные добавляются в одной транзакции. Возвращает ответ с кодом 200
+// и результатом загрузки каждого номера в порядке запроса. Если номеров нет или их больше
+// maxOrderBatch, возвращает ответ с кодом 400.
+func (h *Order) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	nums, err := readOrderNumbers(r)
+	if err != nil || len(nums) == 0 || len(nums) > maxOrderBatch {
+		badRequest(w)
+
+		return
+	}
+
+	var (
+		results = make([]entity.OrderUploadResult, len(nums))
+		valid   = make([]string, 0, len(nums))
+	)
+	for i, num := range nums {
+		results[i].Number = num
+		if err := h.validator.Var(r.Context(), num, "luhn"); err != nil {
+			results[i].Status = entity.OrderUploadInvalid
+
+			continue
+		}
+
+		valid = append(valid, num)
+	}
+
+	if len(valid) > 0 {
+		userID, _ := h.authenticator.UserIdentifier(r)
+
+		created, err := h.processor.CreateBatch(r.Context(), userID, valid)
+		if err != nil || len(created) != len(valid) {
+			serverError(w)
+
+			return
+		}
+
+		for i := range results {
+			if results[i].Status == "" {
+				results[i].Status, created = created[0].Status, created[1:]
+			}
+		}
+	}
+
+	responseAsJSON(w, results, http.StatusOK)
+}
+
+// GetAll возвращает список загруженных заказов пользователя. Параметры запроса status, from, to,
+// sort, limit и cursor задают фильтры и страницу списка, ссылка на следующую страницу передается
+// в заголовке Link. Без параметров возвращаются все заказы. Если заказов нет, возвращает ответ
+// с кодом 204, при некорректных параметрах - 400.
 func (h *Order) GetAll(w http.ResponseWriter, r *http.Request) {
+	f, err := listFilter(r, func(s string) bool {
+		return entity.OrderStatus(s).Valid()
+	})
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
 	userID, _ := h.authenticator.UserIdentifier(r)
 
-	orders, err := h.processor.GetAll(r.Context(), userID)
+	orders, next, err := h.processor.GetAll(r.Context(), userID, f)
 	if err != nil {
 		serverError(w)
 
@@ -80,5 +146,42 @@ func (h *Order) GetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setNextLink(w, r, next)
 	responseAsJSON(w, orders, http.StatusOK)
 }
+
+// Get возвращает заказ пользователя с номером из параметра пути number, историю изменения
+// его статуса и записи журнала операций по заказу. Если заказ не найден или загружен другим
+// пользователем, возвращает ответ с кодом 404.
+func (h *Order) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	order, err := h.processor.Get(r.Context(), userID, chi.URLParam(r, "number"))
+	switch {
+	case errors.Is(err, inerr.ErrOrderNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case err != nil:
+		serverError(w)
+	default:
+		responseAsJSON(w, order, http.StatusOK)
+	}
+}
+
+// Delete отменяет необработанный заказ пользователя (статус NEW, PROCESSING или INVALID), после
+// чего номер заказа может быть загружен повторно. Возвращает ответ с кодом 204 в случае успеха,
+// 404 - если заказ не найден, 409 - если заказ уже обработан (статус PROCESSED).
+func (h *Order) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	err := h.processor.Delete(r.Context(), userID, chi.URLParam(r, "number"))
+	switch {
+	case errors.Is(err, inerr.ErrOrderNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, inerr.ErrOrderNotCancellable):
+		w.WriteHeader(http.StatusConflict)
+	case err != nil:
+		serverError(w)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,10 +30,28 @@ func (m *OrderProcessorMock) Create(_ context.Context, userID int, num string) e
 	return args.Error(0)
 }
 
-func (m *OrderProcessorMock) GetAll(_ context.Context, userID int) ([]entity.Order, error) {
-	args := m.Called(userID)
+func (m *OrderProcessorMock) GetAll(_ context.Context, userID int, f entity.ListFilter) ([]entity.Order, string, error) {
+	args := m.Called(userID, f)
 
-	return args.Get(0).([]entity.Order), args.Error(1)
+	return args.Get(0).([]entity.Order), args.String(1), args.Error(2)
+}
+
+func (m *OrderProcessorMock) CreateBatch(_ context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error) {
+	args := m.Called(userID, nums)
+
+	return args.Get(0).([]entity.OrderUploadResult), args.Error(1)
+}
+
+func (m *OrderProcessorMock) Get(_ context.Context, userID int, num string) (entity.OrderDetail, error) {
+	args := m.Called(userID, num)
+
+	return args.Get(0).(entity.OrderDetail), args.Error(1)
+}
+
+func (m *OrderProcessorMock) Delete(_ context.Context, userID int, num string) error {
+	args := m.Called(userID, num)
+
+	return args.Error(0)
 }
 
 func TestOrder_CreateSuccess(t *testing.T) {
@@ -179,7 +199,7 @@ func TestOrder_GetAllSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	processor.On("GetAll", userID).Return(orders, nil).Once()
+	processor.On("GetAll", userID, entity.ListFilter{}).Return(orders, "", nil).Once()
 	handler := Order{
 		processor:     processor,
 		authenticator: authenticator,
@@ -210,12 +230,12 @@ func TestOrder_GetAllProcessorErrors(t *testing.T) {
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
 	processorError.
-		On("GetAll", userID).
-		Return([]entity.Order{}, errors.New("")).
+		On("GetAll", userID, entity.ListFilter{}).
+		Return([]entity.Order{}, "", errors.New("")).
 		Once()
 	processorNoContent.
-		On("GetAll", userID).
-		Return([]entity.Order{}, nil).
+		On("GetAll", userID, entity.ListFilter{}).
+		Return([]entity.Order{}, "", nil).
 		Once()
 
 	tests := []struct {
@@ -252,3 +272,293 @@ func TestOrder_GetAllProcessorErrors(t *testing.T) {
 	authenticator.AssertExpectations(t)
 	processorError.AssertExpectations(t)
 }
+
+func TestOrder_GetAllFilter(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &OrderProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Order{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		after  = entity.Cursor{Time: time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC), ID: 7}.String()
+		cursor = entity.Cursor{Time: time.Date(2023, 1, 5, 12, 0, 0, 0, time.UTC), ID: 3}.String()
+		orders = []entity.Order{
+			{Number: "148561163482734", Status: entity.OrderStatusNew, UploadedAt: time.Date(2023, 1, 5, 12, 0, 0, 0, time.UTC)},
+		}
+	)
+	c, err := entity.ParseCursor(after)
+	require.NoError(t, err)
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	processor.On("GetAll", userID, entity.ListFilter{
+		Statuses:   []string{"NEW", "PROCESSING", "INVALID"},
+		From:       time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:         time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		Descending: true,
+		After:      &c,
+		Limit:      1,
+	}).Return(orders, cursor, nil).Once()
+
+	result := sendTestRequestWithQuery(
+		http.MethodGet,
+		"status=NEW,PROCESSING&status=INVALID&from=2023-01-01&to=2023-01-31&sort=desc&limit=1&cursor="+after,
+		handler.GetAll,
+	)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(
+		t,
+		"</?cursor="+cursor+"&from=2023-01-01&limit=1&sort=desc&status=NEW%2CPROCESSING&status=INVALID&to=2023-01-31>; rel=\"next\"",
+		result.Header.Get("Link"),
+		"ссылка на следующую страницу",
+	)
+	require.NoError(t, result.Body.Close())
+
+	for _, query := range []string{
+		"status=DONE",
+		"status=NEW,",
+		"from=01.01.2023",
+		"sort=up",
+		"limit=0",
+		"cursor=invalid",
+	} {
+		result = sendTestRequestWithQuery(http.MethodGet, query, handler.GetAll)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректные параметры %s", query)
+		require.NoError(t, result.Body.Close())
+	}
+	authenticator.AssertExpectations(t)
+	processor.AssertExpectations(t)
+}
+
+func TestOrder_Get(t *testing.T) {
+	var (
+		userID        = 1
+		num           = "2377225624"
+		processor     = &OrderProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Order{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		uploadedAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		order      = entity.OrderDetail{
+			Order: entity.Order{
+				ID:         5,
+				Number:     num,
+				Status:     entity.OrderStatusProcessed,
+				Accrual:    500_00,
+				UploadedAt: uploadedAt,
+			},
+			History: []entity.OrderStatusChange{
+				{Status: entity.OrderStatusNew, ChangedAt: uploadedAt},
+				{Status: entity.OrderStatusProcessed, ChangedAt: uploadedAt.Add(time.Minute)},
+			},
+			Entries: []entity.OrderEntry{
+				{ID: 11, Type: entity.TransactionTypeIn, Sum: 500_00, ProcessedAt: uploadedAt.Add(time.Minute)},
+			},
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("Get", userID, num).Return(order, nil).Once()
+	processor.On("Get", userID, "12345678903").Return(entity.OrderDetail{}, inerr.ErrOrderNotFound).Once()
+	processor.On("Get", userID, "346436439").Return(entity.OrderDetail{}, errors.New("")).Once()
+
+	result := sendTestRequestWithParams(http.MethodGet, nil, map[string]string{"number": num}, handler.Get)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "заказ найден")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{
+			"number": "2377225624",
+			"status": "PROCESSED",
+			"accrual": 500.00,
+			"uploaded_at": "2023-01-10T12:00:00Z",
+			"history": [
+				{"status": "NEW", "changed_at": "2023-01-10T12:00:00Z"},
+				{"status": "PROCESSED", "changed_at": "2023-01-10T12:01:00Z"}
+			],
+			"entries": [
+				{"id": 11, "type": "IN", "sum": 500.00, "processed_at": "2023-01-10T12:01:00Z"}
+			]
+		}`,
+		string(b),
+		"заказ найден",
+	)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, nil, map[string]string{"number": "12345678903"}, handler.Get)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode, "заказ не найден")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodGet, nil, map[string]string{"number": "346436439"}, handler.Get)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка сервиса")
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+}
+
+func TestOrder_CreateBatch(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &OrderProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		v10           = v10validator.New()
+		send          = func(contentType, body string) *http.Response {
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			handler := Order{
+				processor:     processor,
+				authenticator: authenticator,
+				validator:     validator.New(v10),
+			}
+			handler.CreateBatch(w, request)
+
+			return w.Result()
+		}
+	)
+	require.NoError(t, v10.RegisterValidation("luhn", validator.Luhn))
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.
+		On("CreateBatch", userID, []string{"148561163482734", "267624438264306", "2377225624"}).
+		Return([]entity.OrderUploadResult{
+			{Number: "148561163482734", Status: entity.OrderUploadAccepted},
+			{Number: "267624438264306", Status: entity.OrderUploadAlreadyUploaded},
+			{Number: "2377225624", Status: entity.OrderUploadConflict},
+		}, nil).
+		Twice()
+	processor.
+		On("CreateBatch", userID, []string{"166221614883769"}).
+		Return([]entity.OrderUploadResult{}, errors.New("")).
+		Once()
+	processor.
+		On("CreateBatch", userID, []string{"79927398713"}).
+		Return([]entity.OrderUploadResult{}, nil).
+		Once()
+	want := `[
+		{"number": "148561163482734", "status": "ACCEPTED"},
+		{"number": "166221614883768", "status": "INVALID"},
+		{"number": "267624438264306", "status": "ALREADY_UPLOADED"},
+		{"number": "2377225624", "status": "CONFLICT"}
+	]`
+
+	result := send("application/json", `["148561163482734", "166221614883768", "267624438264306", "2377225624"]`)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "пакет в формате JSON")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, want, string(b), "пакет в формате JSON")
+	require.NoError(t, result.Body.Close())
+
+	result = send("text/plain", "148561163482734\r\n166221614883768\n\n267624438264306\n2377225624\n")
+	assert.Equal(t, http.StatusOK, result.StatusCode, "пакет в текстовом формате")
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, want, string(b), "пакет в текстовом формате")
+	require.NoError(t, result.Body.Close())
+
+	result = send("text/plain", "166221614883768")
+	assert.Equal(t, http.StatusOK, result.StatusCode, "все номера неверные")
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"number": "166221614883768", "status": "INVALID"}]`, string(b), "все номера неверные")
+	require.NoError(t, result.Body.Close())
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		wantStatusCode int
+	}{
+		{
+			name:           "ошибка при добавлении заказов",
+			contentType:    "text/plain",
+			body:           "166221614883769",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "неполный результат добавления заказов",
+			contentType:    "text/plain",
+			body:           "79927398713",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "пустой пакет",
+			contentType:    "text/plain",
+			body:           "\n\n",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "неверный JSON",
+			contentType:    "application/json; charset=utf-8",
+			body:           `["148561163482734"`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "слишком много номеров",
+			contentType:    "text/plain",
+			body:           strings.Repeat("2377225624\n", maxOrderBatch+1),
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := send(tt.contentType, tt.body)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
+
+func TestOrder_Delete(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &OrderProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Order{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("Delete", userID, "2377225624").Return(nil).Once()
+	processor.On("Delete", userID, "12345678903").Return(inerr.ErrOrderNotFound).Once()
+	processor.On("Delete", userID, "79927398713").Return(inerr.ErrOrderNotCancellable).Once()
+	processor.On("Delete", userID, "346436439").Return(errors.New("")).Once()
+
+	tests := []struct {
+		name           string
+		number         string
+		wantStatusCode int
+	}{
+		{
+			name:           "заказ отменен",
+			number:         "2377225624",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "заказ не найден",
+			number:         "12345678903",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "заказ уже обработан",
+			number:         "79927398713",
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "ошибка сервиса",
+			number:         "346436439",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequestWithParams(http.MethodDelete, nil, map[string]string{"number": tt.number}, handler.Delete)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"net/http"
+)
+
+type Partner struct {
+	processor PartnerProcessor
+	validator Validator
+}
+
+type PartnerProcessor interface {
+	Create(ctx context.Context, p entity.Partner) (entity.Partner, error)
+	GetAll(ctx context.Context) ([]entity.Partner, error)
+	Delete(ctx context.Context, id int) error
+}
+
+func NewPartner(p PartnerProcessor, v Validator) *Partner {
+	return &Partner{
+		processor: p,
+		validator: v,
+	}
+}
+
+// Create создает партнера, который может выполнять операции от имени своих клиентов, в формате
+// {"name": "...", "user_ids": [1, 2]}. Возвращает ответ с кодом 201 и партнером, включая ключ
+// API, который больше не выводится. При некорректном запросе возвращает ответ с кодом 400.
+func (h *Partner) Create(w http.ResponseWriter, r *http.Request) {
+	req := PartnerRequest{}
+	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	partner, err := h.processor.Create(r.Context(), entity.Partner{
+		Name:    req.Name,
+		UserIDs: req.UserIDs,
+	})
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, partner, http.StatusCreated)
+}
+
+// GetAll возвращает список партнеров без ключей API. Если партнеров нет, возвращает
+// ответ с кодом 204.
+func (h *Partner) GetAll(w http.ResponseWriter, r *http.Request) {
+	partners, err := h.processor.GetAll(r.Context())
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(partners) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, partners, http.StatusOK)
+}
+
+// Delete удаляет партнера. Возвращает ответ с кодом 204 в случае успеха, 404 - если
+// партнер не найден.
+func (h *Partner) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.processor.Delete(r.Context(), id)
+	if errors.Is(err, inerr.ErrPartnerNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	} else if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	v10validator "github.com/go-playground/validator/v10"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/ivanpodgorny/gophermart/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type PartnerProcessorMock struct {
+	mock.Mock
+}
+
+func (m *PartnerProcessorMock) Create(_ context.Context, p entity.Partner) (entity.Partner, error) {
+	args := m.Called(p)
+
+	return args.Get(0).(entity.Partner), args.Error(1)
+}
+
+func (m *PartnerProcessorMock) GetAll(_ context.Context) ([]entity.Partner, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.Partner), args.Error(1)
+}
+
+func (m *PartnerProcessorMock) Delete(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func TestPartner_Create(t *testing.T) {
+	var (
+		processor = &PartnerProcessorMock{}
+		partner   = entity.Partner{
+			ID:        1,
+			Name:      "shop",
+			UserIDs:   []int{1, 2},
+			Key:       "key",
+			CreatedAt: time.Now(),
+		}
+		handler = Partner{
+			processor: processor,
+			validator: validator.New(v10validator.New()),
+		}
+	)
+
+	processor.On("Create", entity.Partner{Name: "shop", UserIDs: []int{1, 2}}).Return(partner, nil).Once()
+
+	result := sendTestRequest(http.MethodPost, bytes.NewBufferString(`{"name": "shop", "user_ids": [1, 2]}`), handler.Create)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "успешное создание партнера")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	partnerJSON, err := json.Marshal(partner)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(partnerJSON), string(b), "ответ содержит ключ API")
+	require.NoError(t, result.Body.Close())
+
+	for name, body := range map[string]string{
+		"некорректный JSON":           `{"name":`,
+		"отсутствует название":        `{"user_ids": [1]}`,
+		"не указаны пользователи":     `{"name": "shop"}`,
+		"пустой список пользователей": `{"name": "shop", "user_ids": []}`,
+		"некорректный пользователь":   `{"name": "shop", "user_ids": [0]}`,
+	} {
+		result = sendTestRequest(http.MethodPost, bytes.NewBufferString(body), handler.Create)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	processor.AssertExpectations(t)
+}
+
+func TestPartner_GetAll(t *testing.T) {
+	var (
+		processor = &PartnerProcessorMock{}
+		empty     = &PartnerProcessorMock{}
+		partners  = []entity.Partner{{ID: 1, Name: "shop", UserIDs: []int{1}, CreatedAt: time.Now()}}
+	)
+
+	processor.On("GetAll").Return(partners, nil).Once()
+	empty.On("GetAll").Return([]entity.Partner{}, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, nil, (&Partner{processor: processor}).GetAll)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	partnersJSON, err := json.Marshal(partners)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(partnersJSON), string(b))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, (&Partner{processor: empty}).GetAll)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+	empty.AssertExpectations(t)
+}
+
+func TestPartner_Delete(t *testing.T) {
+	processor := &PartnerProcessorMock{}
+	processor.On("Delete", 1).Return(nil).Once()
+	processor.On("Delete", 2).Return(inerr.ErrPartnerNotFound).Once()
+	processor.On("Delete", 3).Return(errors.New("")).Once()
+	handler := Partner{processor: processor}
+
+	for id, want := range map[string]int{
+		"1":  http.StatusNoContent,
+		"2":  http.StatusNotFound,
+		"3":  http.StatusInternalServerError,
+		"id": http.StatusBadRequest,
+	} {
+		result := sendTestRequestWithParams(http.MethodDelete, nil, map[string]string{"id": id}, handler.Delete)
+		assert.Equal(t, want, result.StatusCode, id)
+		require.NoError(t, result.Body.Close())
+	}
+	processor.AssertExpectations(t)
+}
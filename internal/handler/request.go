@@ -3,8 +3,16 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/ivanpodgorny/gophermart/internal/export"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type SignupRequest struct {
@@ -13,14 +21,32 @@ type SignupRequest struct {
 }
 
 type WithdrawRequest struct {
-	Order string  `json:"order" validate:"required"`
-	Sum   float64 `json:"sum" validate:"required,min=1"`
+	Order string        `json:"order" validate:"required"`
+	Sum   entity.Amount `json:"sum" validate:"required,gt=0"`
+}
+
+type HoldRequest struct {
+	Order string        `json:"order" validate:"required"`
+	Sum   entity.Amount `json:"sum" validate:"required,gt=0"`
+}
+
+type TransferRequest struct {
+	Recipient string        `json:"recipient" validate:"required"`
+	Sum       entity.Amount `json:"sum" validate:"required,gt=0"`
+}
+
+type RefundRequest struct {
+	Sum *entity.Amount `json:"sum" validate:"omitempty,gt=0"`
 }
 
 type IdentityProvider interface {
 	UserIdentifier(*http.Request) (int, error)
 }
 
+type PartnerScopeProvider interface {
+	PartnerScope(*http.Request) (entity.PartnerScope, error)
+}
+
 type Validator interface {
 	Struct(ctx context.Context, s any) error
 	Var(ctx context.Context, field any, tag string) error
@@ -42,3 +68,166 @@ func readJSONBodyAndValidate(ctx context.Context, v any, r *http.Request, valida
 
 	return validator.Struct(ctx, v)
 }
+
+// readOrderNumbers возвращает номера заказов из тела запроса: JSON-массив строк, если тип
+// содержимого - application/json, иначе - текст с номером в каждой строке. Пустые строки
+// пропускаются.
+func readOrderNumbers(r *http.Request) ([]string, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var nums []string
+		err := readJSONBody(&nums, r)
+
+		return nums, err
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var nums []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if num := strings.TrimSpace(line); num != "" {
+			nums = append(nums, num)
+		}
+	}
+
+	return nums, nil
+}
+
+// SocketRequest - сообщение клиента WebSocket: подписка (type "subscribe") или отмена подписки
+// (type "unsubscribe") на события заказов с номерами Orders.
+type SocketRequest struct {
+	Type   string   `json:"type"`
+	Orders []string `json:"orders"`
+}
+
+type ResizeWorkersRequest struct {
+	StatusChecker *int `json:"status_checker" validate:"omitempty,min=1,max=256"`
+	OrderUpdater  *int `json:"order_updater" validate:"omitempty,min=1,max=256"`
+}
+
+type WebhookRequest struct {
+	URL      string                `json:"url" validate:"required,http_url"`
+	Events   []entity.WebhookEvent `json:"events" validate:"required,min=1,dive,oneof=order.processed order.invalid points.withdrawn"`
+	UserIDs  []int                 `json:"user_ids" validate:"dive,gt=0"`
+	AllUsers bool                  `json:"all_users"`
+}
+
+type PartnerRequest struct {
+	Name    string `json:"name" validate:"required"`
+	UserIDs []int  `json:"user_ids" validate:"required,min=1,dive,gt=0"`
+}
+
+func urlParamInt(r *http.Request, key string) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, key))
+}
+
+var errInvalidQueryParam = errors.New("invalid query parameter")
+
+// dateLayout и monthLayout - форматы даты без времени и месяца в параметрах запроса.
+const (
+	dateLayout  = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// queryParamTime возвращает значение параметра запроса key в формате RFC 3339 или дату
+// в формате 2006-01-02. Если endOfDay равен true, для даты без времени возвращается начало
+// следующего дня, чтобы день входил в период целиком. Для отсутствующего параметра
+// возвращает нулевое время.
+func queryParamTime(r *http.Request, key string, endOfDay bool) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, errInvalidQueryParam
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// queryParamInt возвращает положительное целое значение параметра запроса key.
+// Для отсутствующего параметра возвращает 0.
+func queryParamInt(r *http.Request, key string) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errInvalidQueryParam
+	}
+
+	return n, nil
+}
+
+// requestLocale возвращает локаль, заданную параметром запроса lang или заголовком Accept-Language.
+func requestLocale(r *http.Request) export.Locale {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return export.ParseLocale(lang)
+	}
+
+	return export.ParseLocale(r.Header.Get("Accept-Language"))
+}
+
+// listFilter возвращает условия выборки страницы списка из параметров запроса: status - статусы
+// через запятую или повторяющимся параметром, from и to - границы периода, sort - направление
+// сортировки (asc или desc), cursor - курсор следующей страницы, limit - количество элементов.
+// Допустимость статусов проверяет функция validStatus.
+func listFilter(r *http.Request, validStatus func(string) bool) (entity.ListFilter, error) {
+	var (
+		f   = entity.ListFilter{}
+		q   = r.URL.Query()
+		err error
+	)
+	for _, v := range q["status"] {
+		for _, status := range strings.Split(v, ",") {
+			if !validStatus(status) {
+				return f, errInvalidQueryParam
+			}
+
+			f.Statuses = append(f.Statuses, status)
+		}
+	}
+
+	if f.From, err = queryParamTime(r, "from", false); err != nil {
+		return f, err
+	}
+
+	if f.To, err = queryParamTime(r, "to", true); err != nil {
+		return f, err
+	}
+
+	switch q.Get("sort") {
+	case "", "asc":
+	case "desc":
+		f.Descending = true
+	default:
+		return f, errInvalidQueryParam
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := entity.ParseCursor(v)
+		if err != nil {
+			return f, err
+		}
+
+		f.After = &c
+	}
+
+	f.Limit, err = queryParamInt(r, "limit")
+
+	return f, err
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 )
 
 func badRequest(w http.ResponseWriter) {
@@ -27,3 +28,26 @@ func responseAsJSON(w http.ResponseWriter, v any, code int) {
 		serverError(w)
 	}
 }
+
+// responseAsFile отправляет b как файл filename для сохранения на диск.
+func responseAsFile(w http.ResponseWriter, b []byte, contentType string, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		serverError(w)
+	}
+}
+
+// setNextLink добавляет заголовок Link со ссылкой на следующую страницу списка, которая отличается
+// от запрошенной параметром cursor. Если next пуст, заголовок не добавляется.
+func setNextLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("cursor", next)
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Set("Link", "<"+u.String()+`>; rel="next"`)
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Типы сообщений WebSocket. Кроме них сервер отправляет события с типами entity.EventType.
+const (
+	socketSubscribe    = "subscribe"
+	socketUnsubscribe  = "unsubscribe"
+	socketSubscribed   = "subscribed"
+	socketUnsubscribed = "unsubscribed"
+	socketError        = "error"
+)
+
+// SocketMessage - сообщение сервера WebSocket: подтверждение подписки с текущим списком
+// номеров заказов, событие с данными или ошибка обработки сообщения клиента.
+type SocketMessage struct {
+	Type   string          `json:"type"`
+	Orders []string        `json:"orders,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type Socket struct {
+	subscriber    EventSubscriber
+	authenticator IdentityProvider
+	heartbeat     time.Duration
+}
+
+// NewSocket возвращает обработчик соединений WebSocket. Каждые heartbeat клиенту отправляется
+// ping, запись которого завершает соединение с недоступным клиентом.
+func NewSocket(s EventSubscriber, a IdentityProvider, heartbeat time.Duration) *Socket {
+	return &Socket{
+		subscriber:    s,
+		authenticator: a,
+		heartbeat:     heartbeat,
+	}
+}
+
+// Serve устанавливает соединение WebSocket с аутентифицированным пользователем. Пользователь
+// получает события изменения баланса и события заказов, на которые подписался. Заголовок Origin
+// не проверяется: пользователь аутентифицируется заголовком Authorization, который браузер
+// не отправляет с чужих страниц.
+func (h *Socket) Serve(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			h.session(ws, userID)
+		},
+	}.ServeHTTP(w, r)
+}
+
+func (h *Socket) session(ws *websocket.Conn, userID int) {
+	defer func(ws *websocket.Conn) {
+		_ = ws.Close()
+	}(ws)
+
+	events, unsubscribe := h.subscriber.Subscribe(userID)
+	defer unsubscribe()
+
+	var (
+		requests = make(chan SocketRequest)
+		done     = make(chan struct{})
+		orders   = make(map[string]struct{})
+		ticker   = time.NewTicker(h.heartbeat)
+	)
+	defer close(done)
+	defer ticker.Stop()
+
+	go receive(ws, requests, done)
+
+	for {
+		var err error
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			err = send(ws, handleSocketRequest(req, orders))
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if subscribedTo(e, orders) {
+				err = send(ws, SocketMessage{Type: string(e.Type), Data: e.Data})
+			}
+		case <-ticker.C:
+			err = ping(ws, h.heartbeat)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// receive читает сообщения клиента до закрытия соединения или done. Некорректное
+// сообщение передается с пустым типом.
+func receive(ws *websocket.Conn, requests chan<- SocketRequest, done <-chan struct{}) {
+	defer close(requests)
+
+	for {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return
+		}
+
+		req := SocketRequest{}
+		if err := json.Unmarshal([]byte(msg), &req); err != nil {
+			req = SocketRequest{}
+		}
+
+		select {
+		case requests <- req:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleSocketRequest изменяет подписки на события заказов orders и возвращает ответ клиенту.
+func handleSocketRequest(req SocketRequest, orders map[string]struct{}) SocketMessage {
+	switch req.Type {
+	case socketSubscribe:
+		for _, num := range req.Orders {
+			orders[num] = struct{}{}
+		}
+
+		return SocketMessage{Type: socketSubscribed, Orders: subscriptions(orders)}
+	case socketUnsubscribe:
+		for _, num := range req.Orders {
+			delete(orders, num)
+		}
+
+		return SocketMessage{Type: socketUnsubscribed, Orders: subscriptions(orders)}
+	default:
+		return SocketMessage{Type: socketError, Error: "unknown message type"}
+	}
+}
+
+// subscribedTo сообщает, нужно ли передать событие клиенту: события баланса передаются всегда,
+// события заказов - только по заказам из orders.
+func subscribedTo(e entity.Event, orders map[string]struct{}) bool {
+	if e.Type != entity.EventTypeOrder {
+		return true
+	}
+
+	order := entity.Order{}
+	if err := json.Unmarshal(e.Data, &order); err != nil {
+		return false
+	}
+
+	_, ok := orders[order.Number]
+
+	return ok
+}
+
+func subscriptions(orders map[string]struct{}) []string {
+	nums := make([]string, 0, len(orders))
+	for num := range orders {
+		nums = append(nums, num)
+	}
+	sort.Strings(nums)
+
+	return nums
+}
+
+func send(ws *websocket.Conn, msg SocketMessage) error {
+	return websocket.JSON.Send(ws, msg)
+}
+
+// ping отправляет клиенту управляющий кадр ping. Если кадр не удалось записать за timeout,
+// соединение считается потерянным.
+func ping(ws *websocket.Conn, timeout time.Duration) error {
+	if err := ws.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	ws.PayloadType = websocket.PingFrame
+	_, err := ws.Write(nil)
+	ws.PayloadType = websocket.TextFrame
+	if err != nil {
+		return err
+	}
+
+	return ws.SetWriteDeadline(time.Time{})
+}
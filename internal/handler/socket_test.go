@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSocket_Serve(t *testing.T) {
+	var (
+		userID        = 1
+		num           = "2377225624"
+		subscriber    = &EventSubscriberMock{}
+		authenticator = &AuthenticatorMock{}
+		events        = make(chan entity.Event, 3)
+		unsubscribed  = make(chan struct{})
+		handler       = NewSocket(subscriber, authenticator, 10*time.Millisecond)
+		srv           = httptest.NewServer(http.HandlerFunc(handler.Serve))
+	)
+	defer srv.Close()
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	subscriber.
+		On("Subscribe", userID).
+		Return((<-chan entity.Event)(events), func() { close(unsubscribed) }).
+		Once()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	require.NoError(t, err)
+	receive := func() SocketMessage {
+		msg := SocketMessage{}
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(time.Second)))
+		require.NoError(t, websocket.JSON.Receive(ws, &msg))
+
+		return msg
+	}
+
+	require.NoError(t, websocket.JSON.Send(ws, SocketRequest{Type: "subscribe", Orders: []string{num, "12345678903"}}))
+	assert.Equal(t, SocketMessage{Type: "subscribed", Orders: []string{"12345678903", num}}, receive(), "подписка")
+
+	require.NoError(t, websocket.JSON.Send(ws, SocketRequest{Type: "unsubscribe", Orders: []string{"12345678903"}}))
+	assert.Equal(t, SocketMessage{Type: "unsubscribed", Orders: []string{num}}, receive(), "отмена подписки")
+
+	events <- entity.Event{UserID: userID, Type: entity.EventTypeOrder, Data: []byte(`{"number":"12345678903","status":"PROCESSED"}`)}
+	events <- entity.Event{UserID: userID, Type: entity.EventTypeOrder, Data: []byte(`{"number":"2377225624","status":"PROCESSED"}`)}
+	events <- entity.Event{UserID: userID, Type: entity.EventTypeBalance, Data: []byte(`{"current":500}`)}
+	assert.Equal(
+		t,
+		SocketMessage{Type: "order", Data: []byte(`{"number":"2377225624","status":"PROCESSED"}`)},
+		receive(),
+		"событие заказа из подписки",
+	)
+	assert.Equal(t, SocketMessage{Type: "balance", Data: []byte(`{"current":500}`)}, receive(), "событие баланса")
+
+	require.NoError(t, websocket.Message.Send(ws, "not json"))
+	assert.Equal(t, SocketMessage{Type: "error", Error: "unknown message type"}, receive(), "некорректное сообщение")
+
+	require.NoError(t, ws.Close())
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "подписка не отменена после закрытия соединения")
+	}
+	authenticator.AssertExpectations(t)
+	subscriber.AssertExpectations(t)
+}
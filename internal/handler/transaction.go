@@ -1,58 +1,68 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/ivanpodgorny/gophermart/internal/export"
+	"io"
 	"net/http"
+	"time"
 )
 
 type Transaction struct {
 	processor     TransactionProcessor
 	authenticator IdentityProvider
+	partners      PartnerScopeProvider
 	validator     Validator
 }
 
 type TransactionProcessor interface {
-	GetBalance(ctx context.Context, userID int) (current float64, withdrawn float64, err error)
-	Withdraw(ctx context.Context, userID int, order string, sum float64) error
-	GetWithdrawals(ctx context.Context, userID int) ([]entity.Transaction, error)
+	GetBalance(ctx context.Context, userID int) (entity.Balance, error)
+	Withdraw(ctx context.Context, userID int, order string, sum entity.Amount) error
+	GetWithdrawals(ctx context.Context, userID int, f entity.ListFilter) ([]entity.Transaction, string, error)
+	GetStatement(ctx context.Context, userID int, f entity.StatementFilter) (entity.Statement, error)
+	ExportStatement(ctx context.Context, userID int, f entity.StatementFilter) ([]entity.StatementEntry, error)
+	GetMonthlyStatement(ctx context.Context, userID int, month time.Time) (entity.MonthlyStatement, error)
+	Refund(ctx context.Context, scope entity.PartnerScope, order string, sum *entity.Amount) (entity.Transaction, error)
+	Hold(ctx context.Context, userID int, order string, sum entity.Amount) (entity.Hold, error)
+	Capture(ctx context.Context, userID, id int) (entity.Hold, error)
+	Void(ctx context.Context, userID, id int) (entity.Hold, error)
+	GetHolds(ctx context.Context, userID int) ([]entity.Hold, error)
 }
 
-func NewTransaction(p TransactionProcessor, a IdentityProvider, v Validator) *Transaction {
+func NewTransaction(p TransactionProcessor, a IdentityProvider, ps PartnerScopeProvider, v Validator) *Transaction {
 	return &Transaction{
 		processor:     p,
 		authenticator: a,
+		partners:      ps,
 		validator:     v,
 	}
 }
 
 // GetBalance возвращает данные о текущей сумме баллов лояльности пользователя,
-// а также сумме  использованных за весь период регистрации баллов, в формате
-// {"current": 500.5,  "withdrawn": 42}.
+// сумме зарезервированных баллов, а также сумме использованных за весь период
+// регистрации баллов, в формате {"current": 500.50, "held": 10.00, "withdrawn": 42.00}.
 func (h *Transaction) GetBalance(w http.ResponseWriter, r *http.Request) {
 	userID, _ := h.authenticator.UserIdentifier(r)
-	current, withdrawn, err := h.processor.GetBalance(r.Context(), userID)
+	balance, err := h.processor.GetBalance(r.Context(), userID)
 	if err != nil {
 		serverError(w)
 
 		return
 	}
 
-	resp := struct {
-		Current   float64 `json:"current"`
-		Withdrawn float64 `json:"withdrawn"`
-	}{
-		Current:   current,
-		Withdrawn: withdrawn,
-	}
-	responseAsJSON(w, resp, http.StatusOK)
+	responseAsJSON(w, balance, http.StatusOK)
 }
 
 // Withdraw обрабатывает запрос на списание баллов в счет оплаты заказа.
 // Возвращает ответ с кодом 200 в случае успеха. Если на счету недостаточно
-// средств, возвращает ответ с кодом 402.
+// средств, возвращает ответ с кодом 402, если заказ с таким номером уже
+// существует - 422.
 func (h *Transaction) Withdraw(w http.ResponseWriter, r *http.Request) {
 	req := WithdrawRequest{}
 	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil {
@@ -73,6 +83,8 @@ func (h *Transaction) Withdraw(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusOK
 	if errors.Is(err, inerr.ErrInsufficientFunds) {
 		status = http.StatusPaymentRequired
+	} else if errors.Is(err, inerr.ErrOrderExists) {
+		status = http.StatusUnprocessableEntity
 	} else if err != nil {
 		serverError(w)
 
@@ -82,12 +94,22 @@ func (h *Transaction) Withdraw(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(status)
 }
 
-// GetWithdrawals возвращает списания баллов пользвателя. Если списаний нет,
-// возвращает ответ с кодом 204.
+// GetWithdrawals возвращает списания баллов пользвателя. Параметры запроса такие же, как у списка
+// заказов, статусы - состояния возврата: NOT_REFUNDED, PARTIALLY_REFUNDED и REFUNDED. Если списаний
+// нет, возвращает ответ с кодом 204, при некорректных параметрах - 400.
 func (h *Transaction) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
+	f, err := listFilter(r, func(s string) bool {
+		return entity.RefundStatus(s).Valid()
+	})
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
 	userID, _ := h.authenticator.UserIdentifier(r)
 
-	transactions, err := h.processor.GetWithdrawals(r.Context(), userID)
+	transactions, next, err := h.processor.GetWithdrawals(r.Context(), userID, f)
 	if err != nil {
 		serverError(w)
 
@@ -100,5 +122,262 @@ func (h *Transaction) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setNextLink(w, r, next)
 	responseAsJSON(w, transactions, http.StatusOK)
 }
+
+// Statement возвращает выписку по счёту пользователя: все операции, изменившие доступный баланс,
+// с балансом после каждой операции. Параметры запроса from и to ограничивают период (дата
+// в формате 2006-01-02 или время в формате RFC 3339, дата to входит в период), limit - количество
+// операций на странице, cursor - значение next_cursor предыдущей страницы. Если операций нет,
+// возвращает ответ с кодом 204, при некорректных параметрах - 400.
+func (h *Transaction) Statement(w http.ResponseWriter, r *http.Request) {
+	f, err := statementFilter(r)
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	st, err := h.processor.GetStatement(r.Context(), userID, f)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(st.Entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, st, http.StatusOK)
+}
+
+// StatementCSV возвращает выписку по счёту пользователя за период в формате CSV. Параметры
+// from и to такие же, как у Statement, язык выписки задается параметром lang или заголовком
+// Accept-Language (ru или en, по умолчанию ru). При некорректных параметрах возвращает ответ
+// с кодом 400.
+func (h *Transaction) StatementCSV(w http.ResponseWriter, r *http.Request) {
+	f, err := statementFilter(r)
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	entries, err := h.processor.ExportStatement(r.Context(), userID, f)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	b := &bytes.Buffer{}
+	if err := export.WriteCSV(b, entries, requestLocale(r)); err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsFile(w, b.Bytes(), "text/csv; charset=utf-8", "statement.csv")
+}
+
+// StatementPDF возвращает выписку по счёту пользователя за месяц, заданный параметром month
+// в формате 2006-01, в формате PDF. Язык выписки выбирается так же, как в StatementCSV.
+// Если месяц не указан или указан некорректно, возвращает ответ с кодом 400.
+func (h *Transaction) StatementPDF(w http.ResponseWriter, r *http.Request) {
+	month, err := time.Parse(monthLayout, r.URL.Query().Get("month"))
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	st, err := h.processor.GetMonthlyStatement(r.Context(), userID, month)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	b := &bytes.Buffer{}
+	if err := export.WritePDF(b, st, requestLocale(r)); err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsFile(w, b.Bytes(), "application/pdf", "statement-"+month.Format(monthLayout)+".pdf")
+}
+
+// Refund обрабатывает запрос администратора или партнера на возврат баллов, списанных в счёт
+// оплаты заказа. Тело запроса в формате {"sum": 100.50} необязательно: без него возвращается
+// вся оставшаяся сумма списания. Возвращает ответ с кодом 200 и данными о списании с учетом
+// возврата в случае успеха, 404 - если списание по заказу не найдено или выполнено пользователем,
+// который не является клиентом партнера, 422 - если сумма возвратов превысит сумму списания.
+func (h *Transaction) Refund(w http.ResponseWriter, r *http.Request) {
+	scope, err := h.partners.PartnerScope(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	req := RefundRequest{}
+	if len(bytes.TrimSpace(b)) > 0 {
+		if err := json.Unmarshal(b, &req); err != nil {
+			badRequest(w)
+
+			return
+		}
+
+		if err := h.validator.Struct(r.Context(), &req); err != nil {
+			badRequest(w)
+
+			return
+		}
+	}
+
+	res, err := h.processor.Refund(r.Context(), scope, chi.URLParam(r, "order"), req.Sum)
+	switch {
+	case errors.Is(err, inerr.ErrWithdrawalNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, inerr.ErrRefundExceedsSum):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case err != nil:
+		serverError(w)
+	default:
+		responseAsJSON(w, res, http.StatusOK)
+	}
+}
+
+// Hold обрабатывает запрос на резервирование баллов в счёт оплаты заказа в формате
+// {"order": "2377225624", "sum": 751}. Возвращает ответ с кодом 201 и данными резерва
+// в случае успеха. Если на счету недостаточно средств, возвращает ответ с кодом 402,
+// если заказ с таким номером уже существует - 422.
+func (h *Transaction) Hold(w http.ResponseWriter, r *http.Request) {
+	req := HoldRequest{}
+	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	if err := h.validator.Var(r.Context(), req.Order, "luhn"); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	hold, err := h.processor.Hold(r.Context(), userID, req.Order, req.Sum)
+	switch {
+	case errors.Is(err, inerr.ErrInsufficientFunds):
+		w.WriteHeader(http.StatusPaymentRequired)
+	case errors.Is(err, inerr.ErrOrderExists):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case err != nil:
+		serverError(w)
+	default:
+		responseAsJSON(w, hold, http.StatusCreated)
+	}
+}
+
+// Capture списывает зарезервированные баллы. Возвращает ответ с кодом 200 и данными резерва
+// в случае успеха, 404 - если резерв не найден, 409 - если резерв уже списан, отменен или истек.
+func (h *Transaction) Capture(w http.ResponseWriter, r *http.Request) {
+	h.changeHold(w, r, h.processor.Capture)
+}
+
+// Void отменяет резерв и возвращает баллы на счёт пользователя. Возвращает ответ с кодом 200
+// и данными резерва в случае успеха, 404 - если резерв не найден, 409 - если резерв уже
+// списан, отменен или истек.
+func (h *Transaction) Void(w http.ResponseWriter, r *http.Request) {
+	h.changeHold(w, r, h.processor.Void)
+}
+
+// GetHolds возвращает резервы баллов пользователя. Если резервов нет,
+// возвращает ответ с кодом 204.
+func (h *Transaction) GetHolds(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	holds, err := h.processor.GetHolds(r.Context(), userID)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(holds) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, holds, http.StatusOK)
+}
+
+func (h *Transaction) changeHold(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, userID, id int) (entity.Hold, error),
+) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	hold, err := change(r.Context(), userID, id)
+	switch {
+	case errors.Is(err, inerr.ErrHoldNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, inerr.ErrHoldNotActive):
+		w.WriteHeader(http.StatusConflict)
+	case err != nil:
+		serverError(w)
+	default:
+		responseAsJSON(w, hold, http.StatusOK)
+	}
+}
+
+func statementFilter(r *http.Request) (entity.StatementFilter, error) {
+	var (
+		f   = entity.StatementFilter{}
+		err error
+	)
+	if f.From, err = queryParamTime(r, "from", false); err != nil {
+		return f, err
+	}
+
+	if f.To, err = queryParamTime(r, "to", true); err != nil {
+		return f, err
+	}
+
+	if f.After, err = queryParamInt(r, "cursor"); err != nil {
+		return f, err
+	}
+
+	f.Limit, err = queryParamInt(r, "limit")
+
+	return f, err
+}
@@ -23,35 +23,87 @@ type TransactionProcessorMock struct {
 	mock.Mock
 }
 
-func (m *TransactionProcessorMock) GetBalance(_ context.Context, userID int) (float64, float64, error) {
+func (m *TransactionProcessorMock) GetBalance(_ context.Context, userID int) (entity.Balance, error) {
 	args := m.Called(userID)
 
-	return args.Get(0).(float64), args.Get(1).(float64), args.Error(2)
+	return args.Get(0).(entity.Balance), args.Error(1)
 }
 
-func (m *TransactionProcessorMock) Withdraw(_ context.Context, userID int, order string, sum float64) error {
+func (m *TransactionProcessorMock) Withdraw(_ context.Context, userID int, order string, sum entity.Amount) error {
 	args := m.Called(userID, order, sum)
 
 	return args.Error(0)
 }
 
-func (m *TransactionProcessorMock) GetWithdrawals(_ context.Context, userID int) ([]entity.Transaction, error) {
+func (m *TransactionProcessorMock) GetWithdrawals(_ context.Context, userID int, f entity.ListFilter) ([]entity.Transaction, string, error) {
+	args := m.Called(userID, f)
+
+	return args.Get(0).([]entity.Transaction), args.String(1), args.Error(2)
+}
+
+func (m *TransactionProcessorMock) GetStatement(_ context.Context, userID int, f entity.StatementFilter) (entity.Statement, error) {
+	args := m.Called(userID, f)
+
+	return args.Get(0).(entity.Statement), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) ExportStatement(_ context.Context, userID int, f entity.StatementFilter) ([]entity.StatementEntry, error) {
+	args := m.Called(userID, f)
+
+	return args.Get(0).([]entity.StatementEntry), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) GetMonthlyStatement(_ context.Context, userID int, month time.Time) (entity.MonthlyStatement, error) {
+	args := m.Called(userID, month)
+
+	return args.Get(0).(entity.MonthlyStatement), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) Refund(
+	_ context.Context,
+	scope entity.PartnerScope,
+	order string,
+	sum *entity.Amount,
+) (entity.Transaction, error) {
+	args := m.Called(scope, order, sum)
+
+	return args.Get(0).(entity.Transaction), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) Hold(_ context.Context, userID int, order string, sum entity.Amount) (entity.Hold, error) {
+	args := m.Called(userID, order, sum)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) Capture(_ context.Context, userID, id int) (entity.Hold, error) {
+	args := m.Called(userID, id)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) Void(_ context.Context, userID, id int) (entity.Hold, error) {
+	args := m.Called(userID, id)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *TransactionProcessorMock) GetHolds(_ context.Context, userID int) ([]entity.Hold, error) {
 	args := m.Called(userID)
 
-	return args.Get(0).([]entity.Transaction), args.Error(1)
+	return args.Get(0).([]entity.Hold), args.Error(1)
 }
 
 func TestTransaction_GetBalanceSuccess(t *testing.T) {
 	var (
 		userID        = 1
-		current       = 500.5
-		withdrawn     = 42.0
+		balance       = entity.Balance{Current: 500_50, Held: 10_00, Withdrawn: 42_00}
 		processor     = &TransactionProcessorMock{}
 		authenticator = &AuthenticatorMock{}
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	processor.On("GetBalance", userID).Return(current, withdrawn, nil).Once()
+	processor.On("GetBalance", userID).Return(balance, nil).Once()
 	handler := Transaction{
 		processor:     processor,
 		authenticator: authenticator,
@@ -65,7 +117,8 @@ func TestTransaction_GetBalanceSuccess(t *testing.T) {
 	assert.Equal(t, http.StatusOK, result.StatusCode)
 	b, err := io.ReadAll(result.Body)
 	require.NoError(t, err)
-	assert.JSONEq(t, fmt.Sprintf(`{"current": %f, "withdrawn": %f}`, current, withdrawn), string(b))
+	assert.JSONEq(t, `{"current": 500.50, "held": 10.00, "withdrawn": 42.00}`, string(b))
+	assert.Contains(t, string(b), `"current":500.50,"held":10.00,"withdrawn":42.00`, "суммы передаются с двумя знаками после запятой")
 	require.NoError(t, result.Body.Close())
 	processor.AssertExpectations(t)
 	authenticator.AssertExpectations(t)
@@ -81,7 +134,7 @@ func TestTransaction_GetBalanceProcessorErrors(t *testing.T) {
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
 	processor.
 		On("GetBalance", userID).
-		Return(0.0, 0.0, errors.New("")).
+		Return(entity.Balance{}, errors.New("")).
 		Once()
 
 	handler := Transaction{
@@ -103,7 +156,7 @@ func TestTransaction_WithdrawSuccess(t *testing.T) {
 	var (
 		userID        = 1
 		order         = "166221614883769"
-		sum           = 100.0
+		sum           = entity.Amount(100_00)
 		processor     = &TransactionProcessorMock{}
 		authenticator = &AuthenticatorMock{}
 		val           = &ValidatorMock{}
@@ -121,7 +174,7 @@ func TestTransaction_WithdrawSuccess(t *testing.T) {
 
 	result := sendTestRequest(
 		http.MethodPost,
-		bytes.NewBuffer([]byte(fmt.Sprintf(`{"order": "%s", "sum": %f}`, order, sum))),
+		bytes.NewBuffer([]byte(fmt.Sprintf(`{"order": "%s", "sum": %s}`, order, sum))),
 		handler.Withdraw,
 	)
 	assert.Equal(t, http.StatusOK, result.StatusCode)
@@ -135,16 +188,17 @@ func TestTransaction_WithdrawProcessorErrors(t *testing.T) {
 	var (
 		userID                = 1
 		order                 = "166221614883769"
-		sum                   = 100.0
+		sum                   = entity.Amount(100_00)
 		processorInsufficient = &TransactionProcessorMock{}
 		processorError        = &TransactionProcessorMock{}
+		processorOrderExists  = &TransactionProcessorMock{}
 		authenticator         = &AuthenticatorMock{}
 		val                   = &ValidatorMock{}
 	)
 
-	val.On("Struct", &WithdrawRequest{Order: order, Sum: sum}).Return(nil).Twice()
-	val.On("Var", order, "luhn").Return(nil).Twice()
-	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
+	val.On("Struct", &WithdrawRequest{Order: order, Sum: sum}).Return(nil).Times(3)
+	val.On("Var", order, "luhn").Return(nil).Times(3)
+	authenticator.On("UserIdentifier").Return(userID, nil).Times(3)
 	processorInsufficient.
 		On("Withdraw", userID, order, sum).
 		Return(inerr.ErrInsufficientFunds).
@@ -153,6 +207,10 @@ func TestTransaction_WithdrawProcessorErrors(t *testing.T) {
 		On("Withdraw", userID, order, sum).
 		Return(errors.New("")).
 		Once()
+	processorOrderExists.
+		On("Withdraw", userID, order, sum).
+		Return(inerr.ErrOrderExists).
+		Once()
 
 	tests := []struct {
 		name           string
@@ -169,6 +227,11 @@ func TestTransaction_WithdrawProcessorErrors(t *testing.T) {
 			processor:      processorError,
 			wantStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name:           "заказ с таким номером уже существует",
+			processor:      processorOrderExists,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -179,7 +242,7 @@ func TestTransaction_WithdrawProcessorErrors(t *testing.T) {
 			}
 			result := sendTestRequest(
 				http.MethodPost,
-				bytes.NewBuffer([]byte(fmt.Sprintf(`{"order": "%s", "sum": %f}`, order, sum))),
+				bytes.NewBuffer([]byte(fmt.Sprintf(`{"order": "%s", "sum": %s}`, order, sum))),
 				handler.Withdraw,
 			)
 			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
@@ -189,6 +252,7 @@ func TestTransaction_WithdrawProcessorErrors(t *testing.T) {
 	val.AssertExpectations(t)
 	processorInsufficient.AssertExpectations(t)
 	processorError.AssertExpectations(t)
+	processorOrderExists.AssertExpectations(t)
 	authenticator.AssertExpectations(t)
 }
 
@@ -225,6 +289,11 @@ func TestTransaction_WithdrawValidationErrors(t *testing.T) {
 			body:           `{"order": "166221614883769", "sum": -1}`,
 			wantStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:           "сумма с долями копеек",
+			body:           `{"order": "166221614883769", "sum": 100.005}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
 		{
 			name:           "неверный номер заказа",
 			body:           `{"order": "166221614883768", "sum": 100}`,
@@ -266,7 +335,7 @@ func TestTransaction_GetWithdrawalsSuccess(t *testing.T) {
 	)
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Once()
-	processor.On("GetWithdrawals", userID).Return(transactions, nil).Once()
+	processor.On("GetWithdrawals", userID, entity.ListFilter{}).Return(transactions, "", nil).Once()
 	handler := Transaction{
 		processor:     processor,
 		authenticator: authenticator,
@@ -297,12 +366,12 @@ func TestTransaction_GetWithdrawalsProcessorErrors(t *testing.T) {
 
 	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
 	processorError.
-		On("GetWithdrawals", userID).
-		Return([]entity.Transaction{}, errors.New("")).
+		On("GetWithdrawals", userID, entity.ListFilter{}).
+		Return([]entity.Transaction{}, "", errors.New("")).
 		Once()
 	processorNoContent.
-		On("GetWithdrawals", userID).
-		Return([]entity.Transaction{}, nil).
+		On("GetWithdrawals", userID, entity.ListFilter{}).
+		Return([]entity.Transaction{}, "", nil).
 		Once()
 
 	tests := []struct {
@@ -339,3 +408,477 @@ func TestTransaction_GetWithdrawalsProcessorErrors(t *testing.T) {
 	authenticator.AssertExpectations(t)
 	processorError.AssertExpectations(t)
 }
+
+func TestTransaction_Refund(t *testing.T) {
+	var (
+		order     = "2377225624"
+		sum       = entity.Amount(10_50)
+		scope     = entity.PartnerScope{UserIDs: []int{1}}
+		processor = &TransactionProcessorMock{}
+		partners  = &PartnerScopeProviderMock{}
+		v10       = v10validator.New()
+		params    = map[string]string{"order": order}
+		withdrawn = entity.Transaction{
+			Order:        order,
+			Sum:          50_00,
+			ProcessedAt:  time.Date(2020, 12, 9, 16, 9, 57, 0, time.UTC),
+			Refunded:     10_50,
+			RefundStatus: entity.RefundStatusPartial,
+		}
+	)
+	handler := Transaction{
+		processor: processor,
+		partners:  partners,
+		validator: validator.New(v10),
+	}
+	partners.On("PartnerScope").Return(scope, nil).Twice()
+	partners.On("PartnerScope").Return(entity.PartnerScope{}, errors.New("")).Once()
+	processor.On("Refund", scope, order, &sum).Return(withdrawn, nil).Once()
+	processor.On("Refund", scope, order, (*entity.Amount)(nil)).Return(entity.Transaction{}, inerr.ErrRefundExceedsSum).Once()
+
+	result := sendTestRequestWithParams(http.MethodPost, bytes.NewBufferString(`{"sum": 10.50}`), params, handler.Refund)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "частичный возврат")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"order": "2377225624", "sum": 50.00, "processed_at": "2020-12-09T16:09:57Z", "refunded": 10.50, "refund_status": "PARTIALLY_REFUNDED"}`,
+		string(b),
+		"частичный возврат",
+	)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodPost, nil, params, handler.Refund)
+	assert.Equal(t, http.StatusUnprocessableEntity, result.StatusCode, "возврат без суммы превышает списание")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithParams(http.MethodPost, nil, params, handler.Refund)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode, "запрос не аутентифицирован")
+	require.NoError(t, result.Body.Close())
+	processor.AssertExpectations(t)
+	partners.AssertExpectations(t)
+
+	tests := []struct {
+		name           string
+		body           string
+		err            error
+		wantStatusCode int
+	}{
+		{
+			name:           "невалидный JSON",
+			body:           `{"sum":`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "отрицательная сумма",
+			body:           `{"sum": -1}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "списание не найдено",
+			body:           `{}`,
+			err:            inerr.ErrWithdrawalNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "ошибка при возврате",
+			body:           `{}`,
+			err:            errors.New(""),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &TransactionProcessorMock{}
+			if tt.err != nil {
+				p.On("Refund", scope, order, (*entity.Amount)(nil)).Return(entity.Transaction{}, tt.err).Once()
+			}
+			ps := &PartnerScopeProviderMock{}
+			ps.On("PartnerScope").Return(scope, nil).Once()
+			h := Transaction{
+				processor: p,
+				partners:  ps,
+				validator: validator.New(v10),
+			}
+			result := sendTestRequestWithParams(http.MethodPost, bytes.NewBufferString(tt.body), params, h.Refund)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+			p.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTransaction_Statement(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		from      = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		to        = time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+		processed = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		statement = entity.Statement{
+			Entries: []entity.StatementEntry{
+				{ID: 11, Type: entity.TransactionTypeIn, Order: "2377225624", Sum: 100_00, Balance: 100_00, ProcessedAt: processed},
+				{ID: 12, Type: entity.TransactionTypeOut, Order: "12345678903", Sum: -30_00, Balance: 70_00, ProcessedAt: processed},
+			},
+			NextCursor: "12",
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("GetStatement", userID, entity.StatementFilter{From: from, To: to, Limit: 2}).Return(statement, nil).Once()
+	processor.On("GetStatement", userID, entity.StatementFilter{After: 12}).Return(entity.Statement{}, nil).Once()
+	processor.On("GetStatement", userID, entity.StatementFilter{From: processed}).Return(entity.Statement{}, errors.New("")).Once()
+
+	result := sendTestRequestWithQuery(http.MethodGet, "from=2023-01-01&to=2023-01-31&limit=2", handler.Statement)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "успешное получение выписки")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{
+			"entries": [
+				{"id": 11, "type": "IN", "order": "2377225624", "sum": 100.00, "balance": 100.00, "processed_at": "2023-01-10T12:00:00Z"},
+				{"id": 12, "type": "OUT", "order": "12345678903", "sum": -30.00, "balance": 70.00, "processed_at": "2023-01-10T12:00:00Z"}
+			],
+			"next_cursor": "12"
+		}`,
+		string(b),
+		"успешное получение выписки",
+	)
+	require.NoError(t, result.Body.Close())
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+	}{
+		{
+			name:           "операций нет",
+			query:          "cursor=12",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "ошибка сервиса",
+			query:          "from=2023-01-10T12:00:00Z",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "некорректная дата",
+			query:          "from=01.01.2023",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "некорректный курсор",
+			query:          "cursor=abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "некорректное количество операций",
+			query:          "limit=0",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequestWithQuery(http.MethodGet, tt.query, handler.Statement)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
+
+func TestTransaction_StatementCSV(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		from    = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		entries = []entity.StatementEntry{
+			{ID: 11, Type: entity.TransactionTypeIn, Order: "2377225624", Sum: 1500_00, Balance: 1500_00, ProcessedAt: from},
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("ExportStatement", userID, entity.StatementFilter{From: from}).Return(entries, nil).Twice()
+	processor.On("ExportStatement", userID, entity.StatementFilter{}).Return([]entity.StatementEntry{}, errors.New("")).Once()
+
+	result := sendTestRequestWithQuery(http.MethodGet, "from=2023-01-01", handler.StatementCSV)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "text/csv; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="statement.csv"`, result.Header.Get("Content-Disposition"))
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "01.01.2023 00:00;Начисление;2377225624;1500,00;1500,00", "выписка на русском языке по умолчанию")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithQuery(http.MethodGet, "from=2023-01-01&lang=en", handler.StatementCSV)
+	b, err = io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "01/01/2023 00:00,Accrual,2377225624,1500.00,1500.00", "выписка на английском языке")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithQuery(http.MethodGet, "", handler.StatementCSV)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode, "ошибка сервиса")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithQuery(http.MethodGet, "to=31.01.2023", handler.StatementCSV)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректная дата")
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+}
+
+func TestTransaction_StatementPDF(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		month = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("GetMonthlyStatement", userID, month).Return(entity.MonthlyStatement{Month: month, Opening: 10_00, Closing: 10_00}, nil).Once()
+
+	result := sendTestRequestWithQuery(http.MethodGet, "month=2023-01", handler.StatementPDF)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "application/pdf", result.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="statement-2023-01.pdf"`, result.Header.Get("Content-Disposition"))
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(b, []byte("%PDF-")))
+	require.NoError(t, result.Body.Close())
+
+	for _, query := range []string{"", "month=2023-13", "month=01.2023"} {
+		result = sendTestRequestWithQuery(http.MethodGet, query, handler.StatementPDF)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректный месяц: %q", query)
+		require.NoError(t, result.Body.Close())
+	}
+
+	processor.AssertExpectations(t)
+}
+
+func TestTransaction_Hold(t *testing.T) {
+	var (
+		userID        = 1
+		order         = "2377225624"
+		sum           = entity.Amount(30_00)
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		v10           = v10validator.New()
+		hold          = entity.Hold{
+			ID:        7,
+			Order:     order,
+			Sum:       sum,
+			Status:    entity.HoldStatusActive,
+			ExpiresAt: time.Date(2020, 12, 9, 16, 39, 57, 0, time.UTC),
+			CreatedAt: time.Date(2020, 12, 9, 16, 9, 57, 0, time.UTC),
+		}
+	)
+	require.NoError(t, v10.RegisterValidation("luhn", validator.Luhn))
+	handler := Transaction{
+		processor:     processor,
+		authenticator: authenticator,
+		validator:     validator.New(v10),
+	}
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("Hold", userID, order, sum).Return(hold, nil).Once()
+	processor.On("Hold", userID, order, entity.Amount(1000_00)).Return(entity.Hold{}, inerr.ErrInsufficientFunds).Once()
+	processor.On("Hold", userID, order, entity.Amount(1_00)).Return(entity.Hold{}, inerr.ErrOrderExists).Once()
+
+	result := sendTestRequest(http.MethodPost, bytes.NewBufferString(`{"order": "2377225624", "sum": 30}`), handler.Hold)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "успешное резервирование баллов")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"id": 7, "order": "2377225624", "sum": 30.00, "status": "ACTIVE", "expires_at": "2020-12-09T16:39:57Z", "created_at": "2020-12-09T16:09:57Z"}`,
+		string(b),
+		"успешное резервирование баллов",
+	)
+	require.NoError(t, result.Body.Close())
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+	}{
+		{
+			name:           "на счету недостаточно средств",
+			body:           `{"order": "2377225624", "sum": 1000}`,
+			wantStatusCode: http.StatusPaymentRequired,
+		},
+		{
+			name:           "заказ с таким номером уже существует",
+			body:           `{"order": "2377225624", "sum": 1}`,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "некорректная сумма",
+			body:           `{"order": "2377225624", "sum": 0}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "неверный номер заказа",
+			body:           `{"order": "2377225625", "sum": 1}`,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequest(http.MethodPost, bytes.NewBufferString(tt.body), handler.Hold)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
+
+func TestTransaction_CaptureAndVoid(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("Capture", userID, 7).Return(entity.Hold{ID: 7, Status: entity.HoldStatusCaptured}, nil).Once()
+	processor.On("Capture", userID, 8).Return(entity.Hold{}, inerr.ErrHoldNotActive).Once()
+	processor.On("Void", userID, 9).Return(entity.Hold{}, inerr.ErrHoldNotFound).Once()
+	processor.On("Void", userID, 10).Return(entity.Hold{}, errors.New("")).Once()
+
+	tests := []struct {
+		name           string
+		id             string
+		handler        http.HandlerFunc
+		wantStatusCode int
+	}{
+		{
+			name:           "успешное списание резерва",
+			id:             "7",
+			handler:        handler.Capture,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "резерв уже списан, отменен или истек",
+			id:             "8",
+			handler:        handler.Capture,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "резерв не найден",
+			id:             "9",
+			handler:        handler.Void,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "ошибка при отмене резерва",
+			id:             "10",
+			handler:        handler.Void,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "некорректный идентификатор резерва",
+			id:             "abc",
+			handler:        handler.Void,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequestWithParams(http.MethodPost, nil, map[string]string{"id": tt.id}, tt.handler)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
+
+func TestTransaction_GetHolds(t *testing.T) {
+	var (
+		userID        = 1
+		emptyUserID   = 2
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		holds         = []entity.Hold{{ID: 7, Order: "2377225624", Sum: 30_00, Status: entity.HoldStatusActive}}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	authenticator.On("UserIdentifier").Return(emptyUserID, nil).Once()
+	processor.On("GetHolds", userID).Return(holds, nil).Once()
+	processor.On("GetHolds", emptyUserID).Return([]entity.Hold{}, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, nil, handler.GetHolds)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "успешное получение резервов")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	holdsJSON, err := json.Marshal(holds)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(holdsJSON), string(b), "успешное получение резервов")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, handler.GetHolds)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode, "пустой список резервов")
+	require.NoError(t, result.Body.Close())
+	processor.AssertExpectations(t)
+	authenticator.AssertExpectations(t)
+}
+
+func TestTransaction_GetWithdrawalsFilter(t *testing.T) {
+	var (
+		userID        = 1
+		processor     = &TransactionProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = Transaction{
+			processor:     processor,
+			authenticator: authenticator,
+		}
+		transactions = []entity.Transaction{
+			{Order: "148561163482734", Sum: 500, ProcessedAt: time.Date(2023, 1, 5, 12, 0, 0, 0, time.UTC)},
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Twice()
+	processor.On("GetWithdrawals", userID, entity.ListFilter{
+		Statuses: []string{"NOT_REFUNDED", "PARTIALLY_REFUNDED"},
+		Limit:    1,
+	}).Return(transactions, "next", nil).Once()
+	processor.On("GetWithdrawals", userID, entity.ListFilter{Limit: 1}).Return(transactions, "", nil).Once()
+
+	result := sendTestRequestWithQuery(http.MethodGet, "status=NOT_REFUNDED,PARTIALLY_REFUNDED&limit=1", handler.GetWithdrawals)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(
+		t,
+		`</?cursor=next&limit=1&status=NOT_REFUNDED%2CPARTIALLY_REFUNDED>; rel="next"`,
+		result.Header.Get("Link"),
+		"ссылка на следующую страницу",
+	)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithQuery(http.MethodGet, "limit=1", handler.GetWithdrawals)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Empty(t, result.Header.Get("Link"), "последняя страница")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequestWithQuery(http.MethodGet, "status=PROCESSED", handler.GetWithdrawals)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректное состояние возврата")
+	require.NoError(t, result.Body.Close())
+
+	authenticator.AssertExpectations(t)
+	processor.AssertExpectations(t)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"net/http"
+)
+
+type Transfer struct {
+	processor     TransferProcessor
+	authenticator IdentityProvider
+	validator     Validator
+}
+
+type TransferProcessor interface {
+	Transfer(ctx context.Context, senderID int, recipient string, sum entity.Amount) (entity.Transfer, error)
+	GetTransfers(ctx context.Context, userID int) ([]entity.Transfer, error)
+}
+
+func NewTransfer(p TransferProcessor, a IdentityProvider, v Validator) *Transfer {
+	return &Transfer{
+		processor:     p,
+		authenticator: a,
+		validator:     v,
+	}
+}
+
+// Transfer обрабатывает запрос на перевод баллов другому пользователю в формате
+// {"recipient": "family", "sum": 100}. Возвращает ответ с кодом 201 и данными перевода
+// в случае успеха. Если на счету недостаточно средств, возвращает ответ с кодом 402,
+// если получатель не найден - 404, если превышен суточный лимит переводов - 422,
+// при переводе самому себе - 400.
+func (h *Transfer) Transfer(w http.ResponseWriter, r *http.Request) {
+	req := TransferRequest{}
+	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	transfer, err := h.processor.Transfer(r.Context(), userID, req.Recipient, req.Sum)
+	switch {
+	case errors.Is(err, inerr.ErrInsufficientFunds):
+		w.WriteHeader(http.StatusPaymentRequired)
+	case errors.Is(err, inerr.ErrRecipientNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, inerr.ErrTransferLimitExceeded):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case errors.Is(err, inerr.ErrTransferToSelf):
+		badRequest(w)
+	case err != nil:
+		serverError(w)
+	default:
+		responseAsJSON(w, transfer, http.StatusCreated)
+	}
+}
+
+// GetAll возвращает входящие и исходящие переводы баллов пользователя. Если переводов нет,
+// возвращает ответ с кодом 204.
+func (h *Transfer) GetAll(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.authenticator.UserIdentifier(r)
+
+	transfers, err := h.processor.GetTransfers(r.Context(), userID)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(transfers) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, transfers, http.StatusOK)
+}
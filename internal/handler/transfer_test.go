@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	v10validator "github.com/go-playground/validator/v10"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/ivanpodgorny/gophermart/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type TransferProcessorMock struct {
+	mock.Mock
+}
+
+func (m *TransferProcessorMock) Transfer(_ context.Context, senderID int, recipient string, sum entity.Amount) (entity.Transfer, error) {
+	args := m.Called(senderID, recipient, sum)
+
+	return args.Get(0).(entity.Transfer), args.Error(1)
+}
+
+func (m *TransferProcessorMock) GetTransfers(_ context.Context, userID int) ([]entity.Transfer, error) {
+	args := m.Called(userID)
+
+	return args.Get(0).([]entity.Transfer), args.Error(1)
+}
+
+func TestTransfer_Transfer(t *testing.T) {
+	var (
+		userID        = 1
+		recipient     = "family"
+		processor     = &TransferProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = NewTransfer(processor, authenticator, validator.New(v10validator.New()))
+		transfer      = entity.Transfer{
+			ID:           5,
+			Direction:    entity.TransferDirectionOut,
+			Counterparty: recipient,
+			Sum:          30_00,
+			CreatedAt:    time.Date(2020, 12, 9, 16, 9, 57, 0, time.UTC),
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil)
+	processor.On("Transfer", userID, recipient, entity.Amount(30_00)).Return(transfer, nil).Once()
+	processor.On("Transfer", userID, recipient, entity.Amount(1000_00)).Return(entity.Transfer{}, inerr.ErrInsufficientFunds).Once()
+	processor.On("Transfer", userID, "unknown", entity.Amount(1_00)).Return(entity.Transfer{}, inerr.ErrRecipientNotFound).Once()
+	processor.On("Transfer", userID, recipient, entity.Amount(500_00)).Return(entity.Transfer{}, inerr.ErrTransferLimitExceeded).Once()
+	processor.On("Transfer", userID, "me", entity.Amount(1_00)).Return(entity.Transfer{}, inerr.ErrTransferToSelf).Once()
+	processor.On("Transfer", userID, recipient, entity.Amount(2_00)).Return(entity.Transfer{}, errors.New("")).Once()
+
+	result := sendTestRequest(http.MethodPost, bytes.NewBufferString(`{"recipient": "family", "sum": 30}`), handler.Transfer)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "успешный перевод")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"id": 5, "direction": "OUT", "counterparty": "family", "sum": 30.00, "created_at": "2020-12-09T16:09:57Z"}`,
+		string(b),
+		"успешный перевод",
+	)
+	require.NoError(t, result.Body.Close())
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+	}{
+		{
+			name:           "на счету недостаточно средств",
+			body:           `{"recipient": "family", "sum": 1000}`,
+			wantStatusCode: http.StatusPaymentRequired,
+		},
+		{
+			name:           "получатель не найден",
+			body:           `{"recipient": "unknown", "sum": 1}`,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "превышен суточный лимит переводов",
+			body:           `{"recipient": "family", "sum": 500}`,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "перевод самому себе",
+			body:           `{"recipient": "me", "sum": 1}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "ошибка сервиса",
+			body:           `{"recipient": "family", "sum": 2}`,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "некорректная сумма",
+			body:           `{"recipient": "family", "sum": 0}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "не указан получатель",
+			body:           `{"sum": 1}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequest(http.MethodPost, bytes.NewBufferString(tt.body), handler.Transfer)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
+
+func TestTransfer_GetAll(t *testing.T) {
+	var (
+		userID        = 1
+		emptyUserID   = 2
+		processor     = &TransferProcessorMock{}
+		authenticator = &AuthenticatorMock{}
+		handler       = NewTransfer(processor, authenticator, nil)
+		transfers     = []entity.Transfer{
+			{
+				ID:           5,
+				Direction:    entity.TransferDirectionIn,
+				Counterparty: "friend",
+				Sum:          12_50,
+				CreatedAt:    time.Date(2020, 12, 9, 16, 9, 57, 0, time.UTC),
+			},
+		}
+	)
+	authenticator.On("UserIdentifier").Return(userID, nil).Once()
+	authenticator.On("UserIdentifier").Return(emptyUserID, nil).Once()
+	processor.On("GetTransfers", userID).Return(transfers, nil).Once()
+	processor.On("GetTransfers", emptyUserID).Return([]entity.Transfer{}, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, nil, handler.GetAll)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`[{"id": 5, "direction": "IN", "counterparty": "friend", "sum": 12.50, "created_at": "2020-12-09T16:09:57Z"}]`,
+		string(b),
+	)
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, handler.GetAll)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode, "переводов нет")
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"net/http"
+)
+
+type Webhook struct {
+	processor WebhookProcessor
+	validator Validator
+}
+
+type WebhookProcessor interface {
+	Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error)
+	GetAll(ctx context.Context) ([]entity.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	GetDeliveries(ctx context.Context, webhookID int) ([]entity.WebhookDelivery, error)
+}
+
+func NewWebhook(p WebhookProcessor, v Validator) *Webhook {
+	return &Webhook{
+		processor: p,
+		validator: v,
+	}
+}
+
+// Create создает подписку партнера на события его клиентов в формате {"url": "...",
+// "events": ["order.processed"], "user_ids": [1, 2]}. Вместо списка пользователей можно передать
+// "all_users": true, тогда подписка получает события всех пользователей. Возвращает ответ
+// с кодом 201 и подпиской, включая ключ подписи уведомлений, который больше не выводится.
+// При некорректном запросе, а также если не передан ровно один из параметров user_ids
+// и all_users, возвращает ответ с кодом 400.
+func (h *Webhook) Create(w http.ResponseWriter, r *http.Request) {
+	req := WebhookRequest{}
+	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil || req.AllUsers == (len(req.UserIDs) > 0) {
+		badRequest(w)
+
+		return
+	}
+
+	webhook, err := h.processor.Create(r.Context(), entity.Webhook{
+		URL:      req.URL,
+		Events:   req.Events,
+		UserIDs:  req.UserIDs,
+		AllUsers: req.AllUsers,
+	})
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	responseAsJSON(w, webhook, http.StatusCreated)
+}
+
+// GetAll возвращает список подписок без ключей подписи. Если подписок нет, возвращает
+// ответ с кодом 204.
+func (h *Webhook) GetAll(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.processor.GetAll(r.Context())
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(webhooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, webhooks, http.StatusOK)
+}
+
+// Delete удаляет подписку. Возвращает ответ с кодом 204 в случае успеха, 404 - если
+// подписка не найдена.
+func (h *Webhook) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	err = h.processor.Delete(r.Context(), id)
+	if errors.Is(err, inerr.ErrWebhookNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	} else if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries возвращает журнал доставки последних уведомлений по подписке: статус,
+// количество попыток, код ответа партнера и ошибку последней попытки. Если уведомлений
+// нет, возвращает ответ с кодом 204.
+func (h *Webhook) Deliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := urlParamInt(r, "id")
+	if err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	deliveries, err := h.processor.GetDeliveries(r.Context(), id)
+	if err != nil {
+		serverError(w)
+
+		return
+	}
+
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	responseAsJSON(w, deliveries, http.StatusOK)
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	v10validator "github.com/go-playground/validator/v10"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/ivanpodgorny/gophermart/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type WebhookProcessorMock struct {
+	mock.Mock
+}
+
+func (m *WebhookProcessorMock) Create(_ context.Context, w entity.Webhook) (entity.Webhook, error) {
+	args := m.Called(w)
+
+	return args.Get(0).(entity.Webhook), args.Error(1)
+}
+
+func (m *WebhookProcessorMock) GetAll(_ context.Context) ([]entity.Webhook, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.Webhook), args.Error(1)
+}
+
+func (m *WebhookProcessorMock) Delete(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func (m *WebhookProcessorMock) GetDeliveries(_ context.Context, webhookID int) ([]entity.WebhookDelivery, error) {
+	args := m.Called(webhookID)
+
+	return args.Get(0).([]entity.WebhookDelivery), args.Error(1)
+}
+
+func TestWebhook_Create(t *testing.T) {
+	var (
+		url       = "https://partner.example/hooks"
+		events    = []entity.WebhookEvent{entity.WebhookEventOrderProcessed, entity.WebhookEventPointsWithdrawn}
+		processor = &WebhookProcessorMock{}
+		webhook   = entity.Webhook{
+			ID:        1,
+			URL:       url,
+			Events:    events,
+			UserIDs:   []int{1, 2},
+			Secret:    "secret",
+			CreatedAt: time.Now(),
+		}
+		handler = Webhook{
+			processor: processor,
+			validator: validator.New(v10validator.New()),
+		}
+	)
+
+	processor.On("Create", entity.Webhook{URL: url, Events: events, UserIDs: []int{1, 2}}).Return(webhook, nil).Once()
+	processor.On("Create", entity.Webhook{URL: url, Events: events[:1], AllUsers: true}).Return(webhook, nil).Once()
+
+	result := sendTestRequest(
+		http.MethodPost,
+		bytes.NewBufferString(`{"url": "https://partner.example/hooks", "events": ["order.processed", "points.withdrawn"], "user_ids": [1, 2]}`),
+		handler.Create,
+	)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "успешное создание подписки")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	webhookJSON, err := json.Marshal(webhook)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(webhookJSON), string(b), "ответ содержит ключ подписи")
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(
+		http.MethodPost,
+		bytes.NewBufferString(`{"url": "https://partner.example/hooks", "events": ["order.processed"], "all_users": true}`),
+		handler.Create,
+	)
+	assert.Equal(t, http.StatusCreated, result.StatusCode, "подписка на события всех пользователей")
+	require.NoError(t, result.Body.Close())
+
+	for name, body := range map[string]string{
+		"некорректный адрес":              `{"url": "partner", "events": ["order.processed"], "user_ids": [1]}`,
+		"неизвестное событие":             `{"url": "https://partner.example/hooks", "events": ["order.new"], "user_ids": [1]}`,
+		"пустой список событий":           `{"url": "https://partner.example/hooks", "events": [], "user_ids": [1]}`,
+		"некорректный JSON":               `{"url":`,
+		"отсутствует адрес":               `{"events": ["order.invalid"], "user_ids": [1]}`,
+		"отсутствуют все данные":          `{}`,
+		"не указаны пользователи":         `{"url": "https://partner.example/hooks", "events": ["order.invalid"]}`,
+		"пустой список пользователей":     `{"url": "https://partner.example/hooks", "events": ["order.invalid"], "user_ids": []}`,
+		"некорректный пользователь":       `{"url": "https://partner.example/hooks", "events": ["order.invalid"], "user_ids": [0]}`,
+		"пользователи и все пользователи": `{"url": "https://partner.example/hooks", "events": ["order.invalid"], "user_ids": [1], "all_users": true}`,
+	} {
+		result = sendTestRequest(http.MethodPost, bytes.NewBufferString(body), handler.Create)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, name)
+		require.NoError(t, result.Body.Close())
+	}
+
+	processor.AssertExpectations(t)
+}
+
+func TestWebhook_GetAll(t *testing.T) {
+	var (
+		processor = &WebhookProcessorMock{}
+		empty     = &WebhookProcessorMock{}
+		webhooks  = []entity.Webhook{
+			{
+				ID:        1,
+				URL:       "https://partner.example/hooks",
+				Events:    []entity.WebhookEvent{entity.WebhookEventOrderInvalid},
+				CreatedAt: time.Now(),
+			},
+		}
+	)
+
+	processor.On("GetAll").Return(webhooks, nil).Once()
+	empty.On("GetAll").Return([]entity.Webhook{}, nil).Once()
+
+	result := sendTestRequest(http.MethodGet, nil, (&Webhook{processor: processor}).GetAll)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	webhooksJSON, err := json.Marshal(webhooks)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(webhooksJSON), string(b))
+	require.NoError(t, result.Body.Close())
+
+	result = sendTestRequest(http.MethodGet, nil, (&Webhook{processor: empty}).GetAll)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.NoError(t, result.Body.Close())
+
+	processor.AssertExpectations(t)
+	empty.AssertExpectations(t)
+}
+
+func TestWebhook_DeleteAndDeliveries(t *testing.T) {
+	processor := &WebhookProcessorMock{}
+	processor.On("Delete", 1).Return(nil).Once()
+	processor.On("Delete", 2).Return(inerr.ErrWebhookNotFound).Once()
+	processor.On("Delete", 3).Return(errors.New("")).Once()
+	processor.
+		On("GetDeliveries", 1).
+		Return([]entity.WebhookDelivery{{ID: 7, WebhookID: 1, Status: entity.DeliveryStatusDelivered}}, nil).
+		Once()
+	processor.On("GetDeliveries", 2).Return([]entity.WebhookDelivery{}, nil).Once()
+	handler := Webhook{processor: processor}
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		id             string
+		wantStatusCode int
+	}{
+		{
+			name:           "успешное удаление",
+			handler:        handler.Delete,
+			id:             "1",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "удаление: подписка не найдена",
+			handler:        handler.Delete,
+			id:             "2",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "ошибка при удалении",
+			handler:        handler.Delete,
+			id:             "3",
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "некорректный идентификатор",
+			handler:        handler.Delete,
+			id:             "id",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "журнал доставки",
+			handler:        handler.Deliveries,
+			id:             "1",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "журнал доставки пуст",
+			handler:        handler.Deliveries,
+			id:             "2",
+			wantStatusCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sendTestRequestWithParams(http.MethodGet, nil, map[string]string{"id": tt.id}, tt.handler)
+			assert.Equal(t, tt.wantStatusCode, result.StatusCode)
+			require.NoError(t, result.Body.Close())
+		})
+	}
+	processor.AssertExpectations(t)
+}
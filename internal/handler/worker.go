@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+)
+
+type Worker struct {
+	checker   WorkerPool
+	updater   WorkerPool
+	leader    LeadershipProvider
+	accrual   ProtocolMonitor
+	validator Validator
+}
+
+type WorkerPool interface {
+	Workers() int
+	Resize(n int)
+}
+
+type LeadershipProvider interface {
+	IsLeader() bool
+}
+
+type ProtocolMonitor interface {
+	ProtocolViolations() int64
+}
+
+func NewWorker(c WorkerPool, u WorkerPool, l LeadershipProvider, a ProtocolMonitor, v Validator) *Worker {
+	return &Worker{
+		checker:   c,
+		updater:   u,
+		leader:    l,
+		accrual:   a,
+		validator: v,
+	}
+}
+
+// Get возвращает количество воркеров проверки и обновления статусов заказов на экземпляре
+// сервиса, признак того, что экземпляр является ведущим, и количество ответов системы расчёта
+// начислений, не соответствующих протоколу, в формате
+// {"status_checker": 4, "order_updater": 4, "leader": true, "accrual_protocol_violations": 0}.
+func (h *Worker) Get(w http.ResponseWriter, _ *http.Request) {
+	h.respond(w)
+}
+
+// Resize изменяет количество воркеров проверки и обновления статусов заказов. Изменения
+// применяются только на экземпляре сервиса, получившем запрос: если он не является ведущим,
+// новое количество воркеров будет использовано, когда он станет ведущим.
+func (h *Worker) Resize(w http.ResponseWriter, r *http.Request) {
+	req := ResizeWorkersRequest{}
+	if err := readJSONBodyAndValidate(r.Context(), &req, r, h.validator); err != nil {
+		badRequest(w)
+
+		return
+	}
+
+	if req.StatusChecker != nil {
+		h.checker.Resize(*req.StatusChecker)
+	}
+
+	if req.OrderUpdater != nil {
+		h.updater.Resize(*req.OrderUpdater)
+	}
+
+	h.respond(w)
+}
+
+func (h *Worker) respond(w http.ResponseWriter) {
+	resp := struct {
+		StatusChecker      int   `json:"status_checker"`
+		OrderUpdater       int   `json:"order_updater"`
+		Leader             bool  `json:"leader"`
+		ProtocolViolations int64 `json:"accrual_protocol_violations"`
+	}{
+		StatusChecker:      h.checker.Workers(),
+		OrderUpdater:       h.updater.Workers(),
+		Leader:             h.leader.IsLeader(),
+		ProtocolViolations: h.accrual.ProtocolViolations(),
+	}
+	responseAsJSON(w, resp, http.StatusOK)
+}
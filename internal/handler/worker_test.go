@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bytes"
+	v10validator "github.com/go-playground/validator/v10"
+	"github.com/ivanpodgorny/gophermart/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type WorkerPoolMock struct {
+	mock.Mock
+}
+
+func (m *WorkerPoolMock) Workers() int {
+	args := m.Called()
+
+	return args.Int(0)
+}
+
+func (m *WorkerPoolMock) Resize(n int) {
+	m.Called(n)
+}
+
+type LeadershipProviderMock struct {
+	mock.Mock
+}
+
+func (m *LeadershipProviderMock) IsLeader() bool {
+	args := m.Called()
+
+	return args.Bool(0)
+}
+
+type ProtocolMonitorMock struct {
+	mock.Mock
+}
+
+func (m *ProtocolMonitorMock) ProtocolViolations() int64 {
+	args := m.Called()
+
+	return args.Get(0).(int64)
+}
+
+func TestWorker_Get(t *testing.T) {
+	var (
+		checker = &WorkerPoolMock{}
+		updater = &WorkerPoolMock{}
+		leader  = &LeadershipProviderMock{}
+		accrual = &ProtocolMonitorMock{}
+	)
+
+	checker.On("Workers").Return(4).Once()
+	updater.On("Workers").Return(2).Once()
+	leader.On("IsLeader").Return(true).Once()
+	accrual.On("ProtocolViolations").Return(int64(3)).Once()
+	handler := Worker{
+		checker: checker,
+		updater: updater,
+		leader:  leader,
+		accrual: accrual,
+	}
+
+	result := sendTestRequest(http.MethodGet, nil, handler.Get)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status_checker": 4, "order_updater": 2, "leader": true, "accrual_protocol_violations": 3}`, string(b))
+	require.NoError(t, result.Body.Close())
+	checker.AssertExpectations(t)
+	updater.AssertExpectations(t)
+	leader.AssertExpectations(t)
+	accrual.AssertExpectations(t)
+}
+
+func TestWorker_Resize(t *testing.T) {
+	var (
+		checker = &WorkerPoolMock{}
+		updater = &WorkerPoolMock{}
+		leader  = &LeadershipProviderMock{}
+		accrual = &ProtocolMonitorMock{}
+		v10     = v10validator.New()
+	)
+	handler := Worker{
+		checker:   checker,
+		updater:   updater,
+		leader:    leader,
+		accrual:   accrual,
+		validator: validator.New(v10),
+	}
+
+	checker.On("Resize", 8).Once()
+	checker.On("Workers").Return(8).Once()
+	updater.On("Workers").Return(4).Once()
+	leader.On("IsLeader").Return(false).Once()
+	accrual.On("ProtocolViolations").Return(int64(0)).Once()
+
+	result := sendTestRequest(
+		http.MethodPut,
+		bytes.NewBufferString(`{"status_checker": 8}`),
+		handler.Resize,
+	)
+	assert.Equal(t, http.StatusOK, result.StatusCode, "успешное изменение количества воркеров")
+	b, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status_checker": 8, "order_updater": 4, "leader": false, "accrual_protocol_violations": 0}`, string(b))
+	require.NoError(t, result.Body.Close())
+
+	for _, body := range []string{`{"order_updater": 0}`, `{"status_checker": 1000}`, `[]`} {
+		result = sendTestRequest(http.MethodPut, bytes.NewBufferString(body), handler.Resize)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode, "некорректное количество воркеров")
+		require.NoError(t, result.Body.Close())
+	}
+
+	checker.AssertExpectations(t)
+	updater.AssertExpectations(t)
+	leader.AssertExpectations(t)
+}
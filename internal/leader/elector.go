@@ -0,0 +1,109 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Elector выбирает ведущий экземпляр сервиса с помощью рекомендательной блокировки PostgreSQL.
+// Блокировка принадлежит сессии, поэтому при остановке ведущего экземпляра или потере им
+// соединения с базой данных она освобождается, и лидерство переходит к другому экземпляру.
+type Elector struct {
+	db       *sql.DB
+	key      int64
+	interval time.Duration
+	leading  atomic.Bool
+}
+
+func NewElector(db *sql.DB, key int64, interval time.Duration) *Elector {
+	return &Elector{
+		db:       db,
+		key:      key,
+		interval: interval,
+	}
+}
+
+// Run каждые Elector.interval пытается получить лидерство до отмены ctx. Получив лидерство,
+// вызывает lead с контекстом, который отменяется при потере лидерства или отмене ctx.
+// Блокировка освобождается только после возврата из lead, поэтому lead должна дожидаться
+// завершения запущенной работы.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for {
+		if err := e.campaign(ctx, lead); err != nil && ctx.Err() == nil {
+			log.Printf("ошибка выбора ведущего экземпляра: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// IsLeader сообщает, является ли экземпляр ведущим.
+func (e *Elector) IsLeader() bool {
+	return e.leading.Load()
+}
+
+func (e *Elector) campaign(ctx context.Context, lead func(ctx context.Context)) error {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func(conn *sql.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	acquired := false
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil {
+		return err
+	}
+
+	if !acquired {
+		return nil
+	}
+
+	log.Print("экземпляр выбран ведущим")
+	e.leading.Store(true)
+
+	defer func(conn *sql.Conn) {
+		e.leading.Store(false)
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", e.key)
+		log.Print("экземпляр перестал быть ведущим")
+	}(conn)
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			<-done
+
+			return nil
+		case <-ticker.C:
+			if err := conn.PingContext(ctx); err != nil {
+				cancel()
+				<-done
+
+				return err
+			}
+		}
+	}
+}
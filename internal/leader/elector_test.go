@@ -0,0 +1,55 @@
+package leader
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestElector_Run(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		key         = int64(42)
+		lockQuery   = "SELECT pg_try_advisory_lock($1)"
+		unlockQuery = "SELECT pg_advisory_unlock($1)"
+		leading     = make(chan struct{})
+		done        = make(chan struct{})
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	e := NewElector(db, key, 10*time.Millisecond)
+
+	mock.ExpectQuery(lockQuery).
+		WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery(lockQuery).
+		WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(unlockQuery).
+		WithArgs(key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(ctx context.Context) {
+			close(leading)
+			<-ctx.Done()
+		})
+	}()
+
+	select {
+	case <-leading:
+	case <-time.After(time.Second):
+		require.Fail(t, "лидерство не получено")
+	}
+	assert.True(t, e.IsLeader(), "экземпляр выбран ведущим")
+
+	cancel()
+	<-done
+	assert.False(t, e.IsLeader(), "экземпляр перестал быть ведущим")
+	assert.NoError(t, mock.ExpectationsWereMet(), "блокировка освобождается после завершения работы")
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuthenticate(t *testing.T) {
+	var (
+		path         = "/"
+		token        = "token"
+		invalidToken = "invalidToken"
+	)
+
+	tests := []struct {
+		name           string
+		adminToken     string
+		token          string
+		wantStatusCode int
+	}{
+		{
+			name:           "успешная проверка токена",
+			adminToken:     token,
+			token:          token,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "невалидный токен",
+			adminToken:     token,
+			token:          invalidToken,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "токен администратора не задан",
+			adminToken:     "",
+			token:          "",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(AdminAuthenticate(tt.adminToken))
+			r.Get(path, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", tt.token)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader добавляется к ответу, если он повторно отдан из сохраненных.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencySaveTimeout   = 5 * time.Second
+)
+
+type IdempotencyStore interface {
+	Begin(ctx context.Context, userID int, key, fingerprint string) (entity.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, userID int, key string, status int, contentType string, body []byte) error
+	Delete(ctx context.Context, userID int, key string) error
+}
+
+type IdentityProvider interface {
+	UserIdentifier(*http.Request) (int, error)
+}
+
+// Idempotent возвращает middleware для обработки запросов с заголовком Idempotency-Key.
+// Ответ на первый запрос с ключом сохраняется и возвращается на повторные запросы с тем же
+// ключом и телом. Если ключ использован с другим телом запроса, возвращает ответ с кодом 422,
+// если первый запрос с ключом еще обрабатывается - 409. Ответы с кодом 5xx не сохраняются,
+// чтобы запрос можно было повторить. Должен использоваться после Authenticate.
+func Idempotent(s IdempotencyStore, p IdentityProvider) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "400 bad request", http.StatusBadRequest)
+
+				return
+			}
+
+			userID, err := p.UserIdentifier(r)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "400 bad request", http.StatusBadRequest)
+
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			fingerprint := requestFingerprint(r, body)
+			rec, created, err := s.Begin(r.Context(), userID, key, fingerprint)
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+
+				return
+			}
+
+			if !created {
+				replay(w, rec, fingerprint)
+
+				return
+			}
+
+			rw := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			// Ответ сохраняется, даже если клиент уже отключился: иначе ключ останется
+			// в обработке до истечения срока действия.
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencySaveTimeout)
+			defer cancel()
+
+			status := rw.statusCode()
+			if status >= http.StatusInternalServerError {
+				err = s.Delete(ctx, userID, key)
+			} else {
+				err = s.Complete(ctx, userID, key, status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+			}
+			if err != nil {
+				log.Printf("ошибка сохранения ответа для ключа идемпотентности %q: %v", key, err)
+			}
+		})
+	}
+}
+
+// replay отвечает на повторный запрос с ключом идемпотентности.
+func replay(w http.ResponseWriter, rec entity.IdempotencyRecord, fingerprint string) {
+	switch {
+	case rec.Fingerprint != fingerprint:
+		http.Error(w, "422 idempotency key reused with different request", http.StatusUnprocessableEntity)
+	case !rec.Completed:
+		http.Error(w, "409 request with this idempotency key is in progress", http.StatusConflict)
+	default:
+		if rec.ContentType != "" {
+			w.Header().Set("Content-Type", rec.ContentType)
+		}
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(rec.StatusCode)
+		_, _ = w.Write(rec.Body)
+	}
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder передает ответ клиенту и сохраняет его код и тело.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type IdempotencyStoreMock struct {
+	mock.Mock
+}
+
+func (m *IdempotencyStoreMock) Begin(_ context.Context, userID int, key, fingerprint string) (entity.IdempotencyRecord, bool, error) {
+	args := m.Called(userID, key, fingerprint)
+
+	return args.Get(0).(entity.IdempotencyRecord), args.Bool(1), args.Error(2)
+}
+
+func (m *IdempotencyStoreMock) Complete(_ context.Context, userID int, key string, status int, contentType string, body []byte) error {
+	args := m.Called(userID, key, status, contentType, body)
+
+	return args.Error(0)
+}
+
+func (m *IdempotencyStoreMock) Delete(_ context.Context, userID int, key string) error {
+	args := m.Called(userID, key)
+
+	return args.Error(0)
+}
+
+type IdentityProviderMock struct {
+	mock.Mock
+}
+
+func (m *IdentityProviderMock) UserIdentifier(_ *http.Request) (int, error) {
+	args := m.Called()
+
+	return args.Int(0), args.Error(1)
+}
+
+func TestIdempotent(t *testing.T) {
+	var (
+		path        = "/withdraw"
+		userID      = 1
+		key         = "key"
+		body        = `{"order": "2377225624", "sum": 751}`
+		fingerprint = requestFingerprint(httptest.NewRequest(http.MethodPost, path, nil), []byte(body))
+		stored      = entity.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  http.StatusPaymentRequired,
+			ContentType: "text/plain",
+			Body:        []byte("stored"),
+		}
+	)
+
+	tests := []struct {
+		name           string
+		key            string
+		status         int
+		prepare        func(s *IdempotencyStoreMock)
+		wantCalled     bool
+		wantStatusCode int
+		wantBody       string
+		wantReplayed   bool
+	}{
+		{
+			name:           "запрос без ключа",
+			status:         http.StatusOK,
+			prepare:        func(s *IdempotencyStoreMock) {},
+			wantCalled:     true,
+			wantStatusCode: http.StatusOK,
+			wantBody:       "handled",
+		},
+		{
+			name:   "первый запрос с ключом",
+			key:    key,
+			status: http.StatusOK,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(entity.IdempotencyRecord{Fingerprint: fingerprint}, true, nil).Once()
+				s.On("Complete", userID, key, http.StatusOK, "text/plain", []byte("handled")).Return(nil).Once()
+			},
+			wantCalled:     true,
+			wantStatusCode: http.StatusOK,
+			wantBody:       "handled",
+		},
+		{
+			name:   "ошибка сервера при первом запросе",
+			key:    key,
+			status: http.StatusInternalServerError,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(entity.IdempotencyRecord{Fingerprint: fingerprint}, true, nil).Once()
+				s.On("Delete", userID, key).Return(nil).Once()
+			},
+			wantCalled:     true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantBody:       "handled",
+		},
+		{
+			name: "повторный запрос",
+			key:  key,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(stored, false, nil).Once()
+			},
+			wantStatusCode: http.StatusPaymentRequired,
+			wantBody:       "stored",
+			wantReplayed:   true,
+		},
+		{
+			name: "повторный запрос с другим телом",
+			key:  key,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(entity.IdempotencyRecord{Fingerprint: "other"}, false, nil).Once()
+			},
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "первый запрос еще обрабатывается",
+			key:  key,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(entity.IdempotencyRecord{Fingerprint: fingerprint}, false, nil).Once()
+			},
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "ошибка при сохранении ключа",
+			key:  key,
+			prepare: func(s *IdempotencyStoreMock) {
+				s.On("Begin", userID, key, fingerprint).Return(entity.IdempotencyRecord{}, false, errors.New("")).Once()
+			},
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				store    = &IdempotencyStoreMock{}
+				provider = &IdentityProviderMock{}
+				called   = false
+				r        = chi.NewRouter()
+			)
+			tt.prepare(store)
+			provider.On("UserIdentifier").Return(userID, nil).Maybe()
+			r.Use(Idempotent(store, provider))
+			r.Post(path, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				b, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, body, string(b), "тело запроса передается обработчику")
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("handled"))
+			})
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewBufferString(body))
+			require.NoError(t, err)
+			if tt.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, tt.wantCalled, called)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, string(b))
+			}
+			assert.Equal(t, tt.wantReplayed, resp.Header.Get(IdempotentReplayedHeader) == "true")
+			store.AssertExpectations(t)
+		})
+	}
+}
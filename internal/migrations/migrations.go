@@ -2,9 +2,17 @@ package migrations
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/lopezator/migrator"
+	"strings"
 )
 
+// ErrDuplicateAccruals - в журнале есть несколько начислений по одному заказу, которые
+// необходимо скорректировать вручную перед применением миграций.
+var ErrDuplicateAccruals = errors.New("duplicate accruals per order")
+
+// Up применяет миграции базы данных.
 func Up(db *sql.DB) error {
 	m, err := migrator.New(
 		migrator.Migrations(
@@ -24,6 +32,74 @@ func Up(db *sql.DB) error {
 				Name: "Create transactions table",
 				Func: createTransactionsTable,
 			},
+			&migrator.MigrationNoTx{
+				Name: "Create dead letters table",
+				Func: createDeadLettersTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add unique accrual transaction index",
+				Func: addUniqueAccrualTransactionIndex,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Store amounts as numeric",
+				Func: storeAmountsAsNumeric,
+			},
+			&migrator.Migration{
+				Name: "Create ledger",
+				Func: createLedger,
+			},
+			&migrator.Migration{
+				Name: "Create balances table",
+				Func: createBalancesTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create idempotency keys table",
+				Func: createIdempotencyKeysTable,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add refunds to ledger",
+				Func: addRefundsToLedger,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create holds",
+				Func: createHolds,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create accrual lots",
+				Func: createAccrualLots,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create transfers",
+				Func: createTransfers,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add orders keyset index",
+				Func: addOrdersKeysetIndex,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create order status history",
+				Func: createOrderStatusHistory,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create webhooks",
+				Func: createWebhooks,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create outbox",
+				Func: createOutbox,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add idempotency keys expiry index",
+				Func: addIdempotencyKeysExpiryIndex,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Add outbox published index",
+				Func: addOutboxPublishedIndex,
+			},
+			&migrator.MigrationNoTx{
+				Name: "Create partners",
+				Func: createPartners,
+			},
 		),
 	)
 	if err != nil {
@@ -132,3 +208,588 @@ EXECUTE FUNCTION check_balance()
 
 	return err
 }
+
+func createDeadLettersTable(db *sql.DB) error {
+	if _, err := db.Exec(`
+CREATE TABLE dead_letters
+(
+    id              integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_num       varchar(20)  NOT NULL,
+    status          order_status NOT NULL,
+    accrual         real         NOT NULL DEFAULT 0,
+    error           text         NOT NULL,
+    attempts        integer      NOT NULL DEFAULT 1,
+    next_attempt_at timestamptz  NOT NULL,
+    created_at      timestamptz  NOT NULL DEFAULT now()
+)
+	`); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("CREATE INDEX dead_letters_next_attempt_at ON dead_letters (next_attempt_at)")
+
+	return err
+}
+
+// addUniqueAccrualTransactionIndex запрещает повторные начисления по одному заказу. Если такие
+// начисления уже есть, миграция прерывается с ошибкой ErrDuplicateAccruals и списком заказов:
+// повторные начисления могли быть потрачены, поэтому их удаление без компенсирующих записей
+// исказило бы балансы пользователей, и решение о корректировке принимается вручную.
+func addUniqueAccrualTransactionIndex(db *sql.DB) (err error) {
+	rows, err := db.Query(`
+SELECT order_num, array_to_string(array_agg(id ORDER BY id), ',')
+FROM transactions
+WHERE type = 'IN'
+GROUP BY order_num
+HAVING count(*) > 1
+ORDER BY order_num
+	`)
+	if err != nil {
+		return err
+	}
+
+	defer func(rows *sql.Rows) {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}(rows)
+
+	var duplicates []string
+	for rows.Next() {
+		var num, ids string
+		if err = rows.Scan(&num, &ids); err != nil {
+			return err
+		}
+
+		duplicates = append(duplicates, fmt.Sprintf("%s (transactions %s)", num, ids))
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateAccruals, strings.Join(duplicates, ", "))
+	}
+
+	_, err = db.Exec("CREATE UNIQUE INDEX transactions_order_num_in ON transactions (order_num) WHERE type = 'IN'")
+
+	return err
+}
+
+// storeAmountsAsNumeric переводит суммы из real в numeric(14,2), округляя сохраненные
+// значения до копеек, и пересоздает check_balance с вычислением баланса в numeric.
+func storeAmountsAsNumeric(db *sql.DB) error {
+	for _, q := range []string{
+		"ALTER TABLE orders ALTER COLUMN accrual TYPE numeric(14, 2) USING round(accrual::numeric, 2)",
+		"ALTER TABLE transactions ALTER COLUMN amount TYPE numeric(14, 2) USING round(amount::numeric, 2)",
+		"ALTER TABLE dead_letters ALTER COLUMN accrual TYPE numeric(14, 2) USING round(accrual::numeric, 2)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`
+CREATE OR REPLACE FUNCTION check_balance() RETURNS trigger AS
+$$
+DECLARE
+    current_balance numeric(14, 2);
+BEGIN
+    IF NEW.type = 'OUT' THEN
+        current_balance := (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = NEW.user_id AND type = 'IN') -
+                           (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = NEW.user_id AND type = 'OUT');
+        IF NEW.amount > current_balance THEN
+            RAISE 'Insufficient funds' USING ERRCODE = '23514';
+        END IF;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql
+	`)
+
+	return err
+}
+
+// createLedger создает журнал операций с двойной записью, переносит в него транзакции
+// и удаляет таблицу transactions. Миграция выполняется в транзакции, чтобы перенос данных
+// не мог завершиться частично.
+func createLedger(tx *sql.Tx) error {
+	for _, q := range []string{
+		"CREATE TYPE account_type AS ENUM ('USER', 'ACCRUAL_SOURCE', 'REDEMPTION_SINK', 'ADJUSTMENTS')",
+		`
+CREATE TABLE ledger_accounts
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    type       account_type NOT NULL,
+    user_id    integer REFERENCES users (id),
+    CHECK ((type = 'USER') = (user_id IS NOT NULL)),
+    created_at timestamptz  NOT NULL DEFAULT now(),
+    UNIQUE (type, user_id)
+)
+		`,
+		"CREATE UNIQUE INDEX ledger_accounts_system ON ledger_accounts (type) WHERE user_id IS NULL",
+		"INSERT INTO ledger_accounts (type) VALUES ('ACCRUAL_SOURCE'), ('REDEMPTION_SINK'), ('ADJUSTMENTS')",
+		`
+CREATE TABLE journal_entries
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    type       tx_type     NOT NULL,
+    order_num  varchar(20) NOT NULL REFERENCES orders (num),
+    created_at timestamptz NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE UNIQUE INDEX journal_entries_order_num_type ON journal_entries (order_num, type) WHERE type IN ('IN', 'OUT')",
+		`
+CREATE TABLE postings
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    entry_id   integer        NOT NULL REFERENCES journal_entries (id),
+    account_id integer        NOT NULL REFERENCES ledger_accounts (id),
+    amount     numeric(14, 2) NOT NULL,
+    CHECK (amount <> 0)
+)
+		`,
+		"CREATE INDEX postings_entry_id ON postings (entry_id)",
+		"CREATE INDEX postings_account_id ON postings (account_id)",
+		"INSERT INTO ledger_accounts (type, user_id) SELECT 'USER', id FROM users",
+		`
+INSERT INTO journal_entries (id, type, order_num, created_at) OVERRIDING SYSTEM VALUE
+SELECT id, type, order_num, processed_at
+FROM transactions
+		`,
+		`
+SELECT setval(pg_get_serial_sequence('journal_entries', 'id'), coalesce(max(id), 0) + 1, false)
+FROM journal_entries
+		`,
+		`
+INSERT INTO postings (entry_id, account_id, amount)
+SELECT t.id, a.id, CASE WHEN t.type = 'IN' THEN t.amount ELSE -t.amount END
+FROM transactions t
+         JOIN ledger_accounts a ON a.type = 'USER' AND a.user_id = t.user_id
+UNION ALL
+SELECT t.id, a.id, CASE WHEN t.type = 'IN' THEN -t.amount ELSE t.amount END
+FROM transactions t
+         JOIN ledger_accounts a ON a.user_id IS NULL AND a.type =
+                                                         CASE WHEN t.type = 'IN' THEN 'ACCRUAL_SOURCE' ELSE 'REDEMPTION_SINK' END::account_type
+		`,
+		// Сумма проводок каждой записи должна быть равна нулю. Проверка отложена до фиксации
+		// транзакции, так как проводки записи добавляются по одной.
+		`
+CREATE FUNCTION check_entry_balanced() RETURNS trigger AS
+$$
+BEGIN
+    IF (SELECT coalesce(sum(amount), 0) FROM postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE 'Unbalanced journal entry %', NEW.entry_id USING ERRCODE = '23514';
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql
+		`,
+		`
+CREATE CONSTRAINT TRIGGER check_entry_balanced
+    AFTER INSERT OR UPDATE
+    ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION check_entry_balanced()
+		`,
+		// Остаток счёта пользователя не может стать отрицательным. Строка счёта блокируется,
+		// чтобы параллельные списания проверялись последовательно.
+		`
+CREATE FUNCTION check_account_balance() RETURNS trigger AS
+$$
+DECLARE
+    acc_type        account_type;
+    current_balance numeric(14, 2);
+BEGIN
+    IF NEW.amount < 0 THEN
+        SELECT type INTO acc_type FROM ledger_accounts WHERE id = NEW.account_id FOR UPDATE;
+        IF acc_type = 'USER' THEN
+            current_balance := (SELECT coalesce(sum(amount), 0) FROM postings WHERE account_id = NEW.account_id);
+            IF current_balance + NEW.amount < 0 THEN
+                RAISE 'Insufficient funds' USING ERRCODE = '23514';
+            END IF;
+        END IF;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql
+		`,
+		`
+CREATE TRIGGER check_account_balance
+    BEFORE INSERT
+    ON postings
+    FOR EACH ROW
+EXECUTE FUNCTION check_account_balance()
+		`,
+		"DROP TABLE transactions",
+		"DROP FUNCTION check_balance",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createBalancesTable создает таблицу балансов пользователей, заполняет ее по журналу
+// операций и удаляет проверку баланса при добавлении проводок: баланс проверяется
+// при обновлении строки в balances.
+func createBalancesTable(tx *sql.Tx) error {
+	for _, q := range []string{
+		`
+CREATE TABLE balances
+(
+    user_id    integer PRIMARY KEY REFERENCES users (id),
+    current    numeric(14, 2) NOT NULL DEFAULT 0,
+    withdrawn  numeric(14, 2) NOT NULL DEFAULT 0,
+    CHECK (current >= 0),
+    updated_at timestamptz    NOT NULL DEFAULT now()
+)
+		`,
+		`
+INSERT INTO balances (user_id, current, withdrawn)
+SELECT a.user_id,
+       coalesce(sum(p.amount), 0),
+       coalesce(sum(-p.amount) FILTER (WHERE e.type = 'OUT'), 0)
+FROM ledger_accounts a
+         LEFT JOIN postings p ON p.account_id = a.id
+         LEFT JOIN journal_entries e ON e.id = p.entry_id
+WHERE a.type = 'USER'
+GROUP BY a.user_id
+		`,
+		"DROP TRIGGER check_account_balance ON postings",
+		"DROP FUNCTION check_account_balance",
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createIdempotencyKeysTable(db *sql.DB) error {
+	_, err := db.Exec(`
+CREATE TABLE idempotency_keys
+(
+    user_id      integer      NOT NULL REFERENCES users (id),
+    key          varchar(255) NOT NULL,
+    fingerprint  char(64)     NOT NULL,
+    status_code  integer,
+    content_type text,
+    body         bytea,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, key)
+)
+	`)
+
+	return err
+}
+
+// addRefundsToLedger добавляет записи журнала о возврате списанных баллов. Запись возврата
+// ссылается на запись списания.
+func addRefundsToLedger(db *sql.DB) error {
+	for _, q := range []string{
+		"ALTER TYPE tx_type ADD VALUE 'REFUND'",
+		"ALTER TABLE journal_entries ADD COLUMN reference_id integer REFERENCES journal_entries (id)",
+		"CREATE INDEX journal_entries_reference_id ON journal_entries (reference_id)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createHolds добавляет резервирование баллов. Зарезервированные баллы переводятся со счёта
+// пользователя на его счёт резервов и учитываются в balances.held. Значения перечислений
+// добавляются отдельными запросами: новое значение нельзя использовать в транзакции,
+// в которой оно добавлено.
+func createHolds(db *sql.DB) error {
+	for _, q := range []string{
+		"ALTER TYPE account_type ADD VALUE 'HOLD'",
+		"ALTER TYPE tx_type ADD VALUE 'HOLD'",
+		"ALTER TYPE tx_type ADD VALUE 'RELEASE'",
+		`
+ALTER TABLE ledger_accounts
+    DROP CONSTRAINT ledger_accounts_check,
+    ADD CONSTRAINT ledger_accounts_check CHECK ((type IN ('USER', 'HOLD')) = (user_id IS NOT NULL))
+		`,
+		`
+ALTER TABLE balances
+    ADD COLUMN held numeric(14, 2) NOT NULL DEFAULT 0,
+    ADD CHECK (held >= 0)
+		`,
+		"CREATE TYPE hold_status AS ENUM ('ACTIVE', 'CAPTURED', 'VOIDED', 'EXPIRED')",
+		`
+CREATE TABLE holds
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    integer        NOT NULL REFERENCES users (id),
+    order_num  varchar(20)    NOT NULL UNIQUE REFERENCES orders (num),
+    amount     numeric(14, 2) NOT NULL,
+    CHECK (amount > 0),
+    status     hold_status    NOT NULL DEFAULT 'ACTIVE',
+    entry_id   integer        NOT NULL REFERENCES journal_entries (id),
+    expires_at timestamptz    NOT NULL,
+    created_at timestamptz    NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE INDEX holds_user_id ON holds (user_id)",
+		"CREATE INDEX holds_active_expires_at ON holds (expires_at) WHERE status = 'ACTIVE'",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createAccrualLots добавляет партии баллов со сроком действия, учет списаний из партий и счёт
+// истекших баллов. Баллы, доступные пользователям на момент миграции, переносятся в бессрочные
+// партии последних зачислений (более ранние считаются списанными) с временем создания исходных
+// зачислений. Сроки действия не рассчитываются от конфигурации, чтобы результат миграции
+// не зависел от окружения, в котором она применена, и баллы, начисленные до введения сроков
+// действия, не сгорали задним числом.
+func createAccrualLots(db *sql.DB) error {
+	for _, q := range []string{
+		"ALTER TYPE account_type ADD VALUE 'EXPIRATION_SINK'",
+		"ALTER TYPE tx_type ADD VALUE 'EXPIRE'",
+		"INSERT INTO ledger_accounts (type) VALUES ('EXPIRATION_SINK')",
+		`
+CREATE TABLE accrual_lots
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    integer        NOT NULL REFERENCES users (id),
+    entry_id   integer REFERENCES journal_entries (id),
+    amount     numeric(14, 2) NOT NULL,
+    CHECK (amount > 0),
+    remaining  numeric(14, 2) NOT NULL,
+    CHECK (remaining >= 0 AND remaining <= amount),
+    expires_at timestamptz,
+    created_at timestamptz    NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE INDEX accrual_lots_user_id ON accrual_lots (user_id) WHERE remaining > 0",
+		"CREATE INDEX accrual_lots_expires_at ON accrual_lots (expires_at) WHERE remaining > 0",
+		`
+CREATE TABLE lot_consumptions
+(
+    id       integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    lot_id   integer        NOT NULL REFERENCES accrual_lots (id),
+    entry_id integer        NOT NULL REFERENCES journal_entries (id),
+    amount   numeric(14, 2) NOT NULL,
+    CHECK (amount > 0),
+    restored numeric(14, 2) NOT NULL DEFAULT 0,
+    CHECK (restored >= 0 AND restored <= amount)
+)
+		`,
+		"CREATE INDEX lot_consumptions_entry_id ON lot_consumptions (entry_id)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`
+INSERT INTO accrual_lots (user_id, entry_id, amount, remaining, expires_at, created_at)
+SELECT user_id, entry_id, take, take, NULL, created_at
+FROM (SELECT b.user_id,
+             c.entry_id,
+             c.created_at,
+             least(c.amount, b.current - (sum(c.amount) OVER (PARTITION BY b.user_id ORDER BY c.entry_id DESC) -
+                                          c.amount)) take
+      FROM balances b
+               JOIN (SELECT a.user_id, e.id entry_id, e.created_at, p.amount
+                     FROM journal_entries e
+                              JOIN postings p ON p.entry_id = e.id
+                              JOIN ledger_accounts a ON a.id = p.account_id
+                     WHERE a.type = 'USER'
+                       AND p.amount > 0) c ON c.user_id = b.user_id
+      WHERE b.current > 0) l
+WHERE take > 0
+	`)
+
+	return err
+}
+
+// createTransfers добавляет переводы баллов между пользователями. Перевод не связан с заказом,
+// поэтому номер заказа в записях журнала становится необязательным.
+func createTransfers(db *sql.DB) error {
+	for _, q := range []string{
+		"ALTER TYPE tx_type ADD VALUE 'TRANSFER'",
+		"ALTER TABLE journal_entries ALTER COLUMN order_num DROP NOT NULL",
+		`
+CREATE TABLE transfers
+(
+    id           integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    sender_id    integer        NOT NULL REFERENCES users (id),
+    recipient_id integer        NOT NULL REFERENCES users (id),
+    CHECK (sender_id <> recipient_id),
+    amount       numeric(14, 2) NOT NULL,
+    CHECK (amount > 0),
+    entry_id     integer        NOT NULL REFERENCES journal_entries (id),
+    created_at   timestamptz    NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE INDEX transfers_sender_id_created_at ON transfers (sender_id, created_at)",
+		"CREATE INDEX transfers_recipient_id_created_at ON transfers (recipient_id, created_at)",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addOrdersKeysetIndex добавляет индекс для постраничной выборки заказов пользователя
+// по времени загрузки и идентификатору.
+func addOrdersKeysetIndex(db *sql.DB) error {
+	_, err := db.Exec("CREATE INDEX orders_user_id_uploaded_at_id ON orders (user_id, uploaded_at, id)")
+
+	return err
+}
+
+// createOrderStatusHistory добавляет историю изменения статусов заказов. Для загруженных ранее
+// заказов записывается статус NEW на момент загрузки, а текущий статус, если он отличается, -
+// на момент миграции, так как время его установки неизвестно.
+func createOrderStatusHistory(db *sql.DB) error {
+	for _, q := range []string{
+		`
+CREATE TABLE order_status_history
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    order_id   integer      NOT NULL REFERENCES orders (id),
+    status     order_status NOT NULL,
+    changed_at timestamptz  NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE INDEX order_status_history_order_id ON order_status_history (order_id)",
+		`
+INSERT INTO order_status_history (order_id, status, changed_at)
+SELECT id, 'NEW', uploaded_at
+FROM orders
+WHERE status IS NOT NULL
+		`,
+		`
+INSERT INTO order_status_history (order_id, status)
+SELECT id, status
+FROM orders
+WHERE status <> 'NEW'
+		`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createWebhooks добавляет подписки партнеров на уведомления о событиях и журнал доставки
+// уведомлений.
+func createWebhooks(db *sql.DB) error {
+	for _, q := range []string{
+		`
+CREATE TABLE webhooks
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url        text        NOT NULL,
+    secret     text        NOT NULL,
+    events     text[]      NOT NULL,
+    user_ids   integer[]   NOT NULL DEFAULT '{}',
+    all_users  boolean     NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL DEFAULT now()
+)
+		`,
+		"CREATE TYPE delivery_status AS ENUM ('PENDING', 'DELIVERED', 'FAILED')",
+		`
+CREATE TABLE webhook_deliveries
+(
+    id              integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    webhook_id      integer         NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           text            NOT NULL,
+    payload         jsonb           NOT NULL,
+    status          delivery_status NOT NULL DEFAULT 'PENDING',
+    attempts        integer         NOT NULL DEFAULT 0,
+    response_code   integer,
+    error           text            NOT NULL DEFAULT '',
+    next_attempt_at timestamptz     NOT NULL DEFAULT now(),
+    created_at      timestamptz     NOT NULL DEFAULT now(),
+    delivered_at    timestamptz
+)
+		`,
+		"CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id)",
+		"CREATE INDEX webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING'",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createOutbox добавляет таблицу доменных событий, которые записываются в одной транзакции
+// с изменением состояния и публикуются во внешние системы отдельно.
+func createOutbox(db *sql.DB) error {
+	for _, q := range []string{
+		`
+CREATE TABLE outbox
+(
+    id           integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    type         text        NOT NULL,
+    payload      jsonb       NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    published_at timestamptz
+)
+		`,
+		"CREATE INDEX outbox_unpublished ON outbox (id) WHERE published_at IS NULL",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addIdempotencyKeysExpiryIndex добавляет индекс для удаления истекших ключей идемпотентности.
+func addIdempotencyKeysExpiryIndex(db *sql.DB) error {
+	_, err := db.Exec("CREATE INDEX idempotency_keys_created_at ON idempotency_keys (created_at)")
+
+	return err
+}
+
+// addOutboxPublishedIndex добавляет индекс для удаления опубликованных событий.
+func addOutboxPublishedIndex(db *sql.DB) error {
+	_, err := db.Exec("CREATE INDEX outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL")
+
+	return err
+}
+
+// createPartners добавляет партнеров, которые выполняют операции от имени своих клиентов
+// по ключу API. Хранится только хэш ключа.
+func createPartners(db *sql.DB) error {
+	_, err := db.Exec(`
+CREATE TABLE partners
+(
+    id         integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name       text        NOT NULL,
+    key_hash   text        NOT NULL UNIQUE,
+    user_ids   integer[]   NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+)
+	`)
+
+	return err
+}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"github.com/imroc/req/v3"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"strconv"
+	"time"
+)
+
+// HTTP отправляет события POST-запросами на адрес url. Заголовок X-Event-ID содержит
+// идентификатор события, по которому получатель может отбрасывать повторы.
+type HTTP struct {
+	req *req.Client
+	url string
+}
+
+func NewHTTP(url string) *HTTP {
+	return &HTTP{
+		req: req.C().SetTimeout(10 * time.Second),
+		url: url,
+	}
+}
+
+// Publish отправляет событие e. Событие считается опубликованным только при ответе
+// с кодом 2xx, для остальных кодов возвращается ошибка.
+func (s *HTTP) Publish(ctx context.Context, e entity.OutboxEvent) error {
+	resp, err := s.req.R().
+		SetContext(ctx).
+		SetHeader("X-Event-ID", strconv.Itoa(e.ID)).
+		SetBodyJsonMarshal(e).
+		Post(s.url)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *HTTP) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestHTTP_Publish(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		okURL   = "https://analytics.loc/events"
+		failURL = "https://analytics.loc/broken"
+		s       = NewHTTP(okURL)
+	)
+
+	httpmock.ActivateNonDefault(s.req.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", okURL, func(req *http.Request) (*http.Response, error) {
+		e := entity.OutboxEvent{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&e))
+		assert.Equal(t, testEvent.ID, e.ID)
+		assert.Equal(t, testEvent.Type, e.Type)
+		assert.JSONEq(t, string(testEvent.Payload), string(e.Payload))
+		assert.Equal(t, "7", req.Header.Get("X-Event-ID"))
+
+		return httpmock.NewStringResponse(http.StatusAccepted, ""), nil
+	})
+	httpmock.RegisterResponder("POST", failURL, httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
+
+	assert.NoError(t, s.Publish(ctx, testEvent), "событие опубликовано")
+
+	s.url = failURL
+	assert.Error(t, s.Publish(ctx, testEvent), "ошибка на стороне получателя")
+}
@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"os"
+	"strings"
+)
+
+// Sink публикует доменные события во внешнюю систему.
+type Sink interface {
+	Publish(ctx context.Context, e entity.OutboxEvent) error
+	Close() error
+}
+
+var ErrUnknownSink = errors.New("unknown outbox sink")
+
+// Discard - получатель, который отбрасывает события. Используется, если получатель не задан:
+// события отмечаются опубликованными и удаляются по истечении срока хранения.
+type Discard struct{}
+
+func (Discard) Publish(context.Context, entity.OutboxEvent) error {
+	return nil
+}
+
+func (Discard) Close() error {
+	return nil
+}
+
+// Open возвращает получателя событий по адресу dsn:
+//   - "" - события отбрасываются;
+//   - "stdout" - запись событий в стандартный вывод;
+//   - "file:<путь>" - дозапись событий в файл;
+//   - "http://..." или "https://..." - отправка событий POST-запросами.
+func Open(dsn string) (Sink, error) {
+	switch {
+	case dsn == "":
+		return Discard{}, nil
+	case dsn == "stdout":
+		return NewWriter(os.Stdout), nil
+	case strings.HasPrefix(dsn, "file:") && len(dsn) > len("file:"):
+		return OpenFile(strings.TrimPrefix(dsn, "file:"))
+	case strings.HasPrefix(dsn, "http://") || strings.HasPrefix(dsn, "https://"):
+		return NewHTTP(dsn), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSink, dsn)
+	}
+}
@@ -0,0 +1,33 @@
+package outbox
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	s, err := Open("stdout")
+	require.NoError(t, err)
+	assert.IsType(t, &Writer{}, s, "стандартный вывод")
+	assert.NoError(t, s.Close())
+
+	s, err = Open("file:" + filepath.Join(t.TempDir(), "events.jsonl"))
+	require.NoError(t, err)
+	assert.IsType(t, &Writer{}, s, "файл")
+	assert.NoError(t, s.Close())
+
+	s, err = Open("https://analytics.loc/events")
+	require.NoError(t, err)
+	assert.IsType(t, &HTTP{}, s, "HTTP")
+
+	s, err = Open("")
+	require.NoError(t, err)
+	assert.IsType(t, Discard{}, s, "получатель не задан")
+
+	for _, dsn := range []string{"file:", "kafka://broker:9092"} {
+		_, err = Open(dsn)
+		assert.ErrorIs(t, err, ErrUnknownSink, dsn)
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"io"
+	"os"
+)
+
+// Writer записывает события в w по одному JSON-объекту на строку.
+type Writer struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// OpenFile возвращает Writer, дописывающий события в файл path. Если файл не существует,
+// он будет создан.
+func OpenFile(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriter(f), nil
+}
+
+func (s *Writer) Publish(_ context.Context, e entity.OutboxEvent) error {
+	return s.enc.Encode(e)
+}
+
+// Close закрывает файл, открытый OpenFile. Стандартный вывод и другие потоки не закрываются.
+func (s *Writer) Close() error {
+	if f, ok := s.w.(*os.File); ok && f != os.Stdout && f != os.Stderr {
+		return f.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testEvent = entity.OutboxEvent{
+	ID:        7,
+	Type:      entity.OutboxEventOrderCreated,
+	Payload:   json.RawMessage(`{"order":"2377225624","user_id":1}`),
+	CreatedAt: time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+}
+
+func TestWriter_Publish(t *testing.T) {
+	var (
+		ctx = context.Background()
+		buf = &bytes.Buffer{}
+		w   = NewWriter(buf)
+	)
+
+	require.NoError(t, w.Publish(ctx, testEvent))
+	require.NoError(t, w.Publish(ctx, testEvent))
+	assert.Equal(
+		t,
+		`{"id":7,"type":"order.created","payload":{"order":"2377225624","user_id":1},"created_at":"2023-01-10T12:00:00Z"}`+"\n"+
+			`{"id":7,"type":"order.created","payload":{"order":"2377225624","user_id":1},"created_at":"2023-01-10T12:00:00Z"}`+"\n",
+		buf.String(),
+		"по одному событию на строку",
+	)
+	assert.NoError(t, w.Close())
+}
+
+func TestOpenFile(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		path = filepath.Join(t.TempDir(), "events.jsonl")
+	)
+
+	for i := 0; i < 2; i++ {
+		w, err := OpenFile(path)
+		require.NoError(t, err)
+		require.NoError(t, w.Publish(ctx, testEvent))
+		require.NoError(t, w.Close())
+	}
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(b, []byte("\n")), "события дописываются в файл")
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"time"
+)
+
+type DeadLetter struct {
+	db *sql.DB
+}
+
+func NewDeadLetter(db *sql.DB) *DeadLetter {
+	return &DeadLetter{db: db}
+}
+
+// Create сохраняет результат проверки статуса заказа, который не удалось применить,
+// вместе с текстом ошибки и временем следующей попытки.
+func (r *DeadLetter) Create(ctx context.Context, res entity.StatusCheckResult, reason string, next time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"INSERT INTO dead_letters (order_num, status, accrual, error, next_attempt_at) VALUES ($1, $2, $3, $4, $5)",
+		res.Num,
+		res.Status,
+		res.Accrual,
+		reason,
+		next,
+	)
+
+	return err
+}
+
+// FindAll возвращает все сохраненные результаты. Данные отсортированы по времени
+// добавления от самых старых к самым новым.
+func (r *DeadLetter) FindAll(ctx context.Context) ([]entity.DeadLetter, error) {
+	return r.find(ctx, `
+SELECT id, order_num, status, accrual, error, attempts, next_attempt_at, created_at
+FROM dead_letters
+ORDER BY id
+	`)
+}
+
+// FindDue возвращает не более limit результатов, время следующей попытки сохранения
+// которых наступило к моменту now.
+func (r *DeadLetter) FindDue(ctx context.Context, now time.Time, limit int) ([]entity.DeadLetter, error) {
+	return r.find(ctx, `
+SELECT id, order_num, status, accrual, error, attempts, next_attempt_at, created_at
+FROM dead_letters
+WHERE next_attempt_at <= $1
+ORDER BY next_attempt_at
+LIMIT $2
+	`, now, limit)
+}
+
+// FindByID возвращает сохраненный результат по идентификатору. Если результат не найден,
+// возвращает ошибку errors.ErrDeadLetterNotFound.
+func (r *DeadLetter) FindByID(ctx context.Context, id int) (entity.DeadLetter, error) {
+	d := entity.DeadLetter{}
+	err := r.db.QueryRowContext(ctx, `
+SELECT id, order_num, status, accrual, error, attempts, next_attempt_at, created_at
+FROM dead_letters
+WHERE id = $1
+	`, id).Scan(&d.ID, &d.Order, &d.Status, &d.Accrual, &d.Error, &d.Attempts, &d.NextAttemptAt, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		err = inerr.ErrDeadLetterNotFound
+	}
+
+	return d, err
+}
+
+// Reschedule увеличивает счетчик попыток сохранения результата, обновляет текст ошибки
+// и время следующей попытки.
+func (r *DeadLetter) Reschedule(ctx context.Context, id int, reason string, next time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE dead_letters SET attempts = attempts + 1, error = $1, next_attempt_at = $2 WHERE id = $3",
+		reason,
+		next,
+		id,
+	)
+
+	return err
+}
+
+// Delete удаляет сохраненный результат. Если результат не найден, возвращает ошибку
+// errors.ErrDeadLetterNotFound.
+func (r *DeadLetter) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM dead_letters WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = inerr.ErrDeadLetterNotFound
+	}
+
+	return err
+}
+
+func (r *DeadLetter) find(ctx context.Context, query string, args ...any) (letters []entity.DeadLetter, err error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		d := entity.DeadLetter{}
+		err = rows.Scan(&d.ID, &d.Order, &d.Status, &d.Accrual, &d.Error, &d.Attempts, &d.NextAttemptAt, &d.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		letters = append(letters, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return letters, err
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDeadLetter_Create(t *testing.T) {
+	var (
+		ctx = context.Background()
+		res = entity.StatusCheckResult{
+			Num:     "148561163482734",
+			Status:  entity.OrderStatusProcessed,
+			Accrual: 100,
+		}
+		reason = "connection refused"
+		next   = time.Now()
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewDeadLetter(db)
+
+	mock.
+		ExpectExec("INSERT INTO dead_letters (order_num, status, accrual, error, next_attempt_at) VALUES ($1, $2, $3, $4, $5)").
+		WithArgs(res.Num, res.Status, res.Accrual, reason, next).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.NoError(t, r.Create(ctx, res, reason, next), "успешное сохранение результата")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetter_FindDue(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		now     = time.Now()
+		limit   = 10
+		letters = []entity.DeadLetter{
+			{
+				ID:            1,
+				Order:         "148561163482734",
+				Status:        entity.OrderStatusProcessed,
+				Accrual:       100,
+				Error:         "connection refused",
+				Attempts:      2,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			},
+		}
+		query = `
+SELECT id, order_num, status, accrual, error, attempts, next_attempt_at, created_at
+FROM dead_letters
+WHERE next_attempt_at <= $1
+ORDER BY next_attempt_at
+LIMIT $2
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewDeadLetter(db)
+
+	rows := sqlmock.NewRows([]string{"id", "order_num", "status", "accrual", "error", "attempts", "next_attempt_at", "created_at"})
+	for _, d := range letters {
+		rows.AddRow(d.ID, d.Order, d.Status, d.Accrual, d.Error, d.Attempts, d.NextAttemptAt, d.CreatedAt)
+	}
+	mock.ExpectQuery(query).
+		WithArgs(now, limit).
+		WillReturnRows(rows)
+
+	found, err := r.FindDue(ctx, now, limit)
+	assert.NoError(t, err, "успешное получение результатов для повторного сохранения")
+	assert.Equal(t, letters, found, "успешное получение результатов для повторного сохранения")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetter_FindByID(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		id    = 1
+		query = `
+SELECT id, order_num, status, accrual, error, attempts, next_attempt_at, created_at
+FROM dead_letters
+WHERE id = $1
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewDeadLetter(db)
+
+	mock.ExpectQuery(query).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = r.FindByID(ctx, id)
+	assert.ErrorIs(t, err, inerr.ErrDeadLetterNotFound, "результат не найден")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetter_Reschedule(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		id     = 1
+		reason = "connection refused"
+		next   = time.Now()
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewDeadLetter(db)
+
+	mock.
+		ExpectExec("UPDATE dead_letters SET attempts = attempts + 1, error = $1, next_attempt_at = $2 WHERE id = $3").
+		WithArgs(reason, next, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, r.Reschedule(ctx, id, reason, next), "успешное обновление времени следующей попытки")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetter_Delete(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		id         = 1
+		notFoundID = 2
+		query      = "DELETE FROM dead_letters WHERE id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewDeadLetter(db)
+
+	mock.ExpectExec(query).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).
+		WithArgs(notFoundID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, r.Delete(ctx, id), "успешное удаление результата")
+	assert.ErrorIs(t, r.Delete(ctx, notFoundID), inerr.ErrDeadLetterNotFound, "результат не найден")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// EventsChannel - канал уведомлений PostgreSQL, в который публикуются события пользователей.
+// Уведомление доставляется слушателям только после фиксации транзакции, в которой оно отправлено.
+const EventsChannel = "gophermart_events"
+
+// notifyOrder публикует событие изменения заказа orderID с его текущими данными.
+func notifyOrder(ctx context.Context, tx *sql.Tx, orderID int) error {
+	_, err := tx.ExecContext(ctx, `
+SELECT pg_notify($1, json_build_object(
+        'user_id', user_id,
+        'type', 'order',
+        'data', json_build_object('number', num, 'status', status, 'accrual', accrual, 'uploaded_at', uploaded_at)
+    )::text)
+FROM orders
+WHERE id = $2
+	`, EventsChannel, orderID)
+
+	return err
+}
+
+// notifyBalance публикует событие изменения баланса пользователя userID с его текущим значением.
+func notifyBalance(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `
+SELECT pg_notify($1, json_build_object(
+        'user_id', user_id,
+        'type', 'balance',
+        'data', json_build_object('current', current, 'held', held, 'withdrawn', withdrawn)
+    )::text)
+FROM balances
+WHERE user_id = $2
+	`, EventsChannel, userID)
+
+	return err
+}
@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+// Hold предоставляет доступ к резервам баллов. Резервирование переводит баллы со счёта
+// пользователя на его счёт резервов, списание резерва - со счёта резервов на счёт погашений,
+// отмена и истечение резерва возвращают баллы на счёт пользователя.
+type Hold struct {
+	db        *sql.DB
+	pointsTTL time.Duration
+}
+
+// NewHold возвращает репозиторий резервов. Баллы, возвращенные на счёт пользователя при отмене
+// или истечении резерва, сохраняют срок действия партий, из которых были зарезервированы. Если
+// партии резерва неизвестны или их срок действия уже истек, возвращенные баллы действуют
+// в течение pointsTTL, при нулевом pointsTTL - бессрочно.
+func NewHold(db *sql.DB, pointsTTL time.Duration) *Hold {
+	return &Hold{
+		db:        db,
+		pointsTTL: pointsTTL,
+	}
+}
+
+// Create создает заказ и резервирует sum баллов пользователя в счёт его оплаты до expiresAt.
+// При попытке зарезервировать недоступную сумму возвращает ошибку errors.ErrInsufficientFunds,
+// если заказ с таким номером уже существует - errors.ErrOrderExists.
+func (r *Hold) Create(ctx context.Context, userID int, order string, sum entity.Amount, expiresAt time.Time) (entity.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Hold{}, err
+	}
+
+	h, err := r.create(ctx, tx, userID, order, sum, expiresAt)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return entity.Hold{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return entity.Hold{}, err
+	}
+
+	return h, nil
+}
+
+func (r *Hold) create(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int,
+	order string,
+	sum entity.Amount,
+	expiresAt time.Time,
+) (entity.Hold, error) {
+	h := entity.Hold{
+		Order:     order,
+		Sum:       sum,
+		Status:    entity.HoldStatusActive,
+		ExpiresAt: expiresAt,
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO orders (user_id, num) VALUES ($1, $2)", userID, order); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			err = inerr.ErrOrderExists
+		}
+
+		return h, err
+	}
+
+	user, err := userAccount(ctx, tx, userID, entity.AccountTypeUser)
+	if err != nil {
+		return h, err
+	}
+
+	hold, err := userAccount(ctx, tx, userID, entity.AccountTypeHold)
+	if err != nil {
+		return h, err
+	}
+
+	current, err := lockBalance(ctx, tx, userID)
+	if err != nil {
+		return h, err
+	}
+
+	if current < sum {
+		return h, inerr.ErrInsufficientFunds
+	}
+
+	entryID, err := postEntry(
+		ctx,
+		tx,
+		entity.TransactionTypeHold,
+		order,
+		nil,
+		posting{account: user, amount: -sum},
+		posting{account: hold, amount: sum},
+	)
+	if err != nil {
+		return h, err
+	}
+
+	if err = consumeLots(ctx, tx, userID, entryID, sum); err != nil {
+		return h, err
+	}
+
+	if err = updateBalance(ctx, tx, userID, -sum, sum, 0); err != nil {
+		return h, err
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		"INSERT INTO holds (user_id, order_num, amount, entry_id, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		userID,
+		order,
+		sum,
+		entryID,
+		expiresAt,
+	).Scan(&h.ID, &h.CreatedAt)
+	if err != nil {
+		return h, err
+	}
+
+	return h, writeOutbox(ctx, tx, entity.OutboxEventPointsHeld, map[string]any{
+		"user_id": userID,
+		"hold_id": h.ID,
+		"order":   order,
+		"sum":     sum,
+	})
+}
+
+// Capture списывает зарезервированные баллы и возвращает резерв с обновленным статусом.
+// Списание записывается в журнал операций как entity.TransactionTypeOut и отображается
+// в списке списаний пользователя. Если резерв не найден, возвращает ошибку
+// errors.ErrHoldNotFound, если резерв уже списан, отменен или истек к моменту now -
+// errors.ErrHoldNotActive.
+func (r *Hold) Capture(ctx context.Context, userID, id int, now time.Time) (entity.Hold, error) {
+	return r.update(ctx, userID, id, func(tx *sql.Tx, h *entity.Hold, entryID int) error {
+		if !h.ExpiresAt.After(now) {
+			return inerr.ErrHoldNotActive
+		}
+
+		return capture(ctx, tx, userID, h, entryID)
+	})
+}
+
+// Void отменяет резерв, возвращая баллы на счёт пользователя, и возвращает резерв
+// с обновленным статусом. Если резерв не найден, возвращает ошибку errors.ErrHoldNotFound,
+// если резерв уже списан, отменен или истек - errors.ErrHoldNotActive.
+func (r *Hold) Void(ctx context.Context, userID, id int) (entity.Hold, error) {
+	return r.update(ctx, userID, id, func(tx *sql.Tx, h *entity.Hold, entryID int) error {
+		return release(ctx, tx, userID, h, entryID, entity.HoldStatusVoided, r.pointsTTL)
+	})
+}
+
+// ExpireDue возвращает на счета пользователей баллы не более чем limit активных резервов,
+// срок действия которых истек к моменту now, и возвращает количество истекших резервов.
+// Резервы, которые в этот момент списываются или отменяются, пропускаются.
+func (r *Hold) ExpireDue(ctx context.Context, now time.Time, limit int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.expireDue(ctx, tx, now, limit)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// dueHold - истекший резерв, баллы которого нужно вернуть пользователю.
+type dueHold struct {
+	hold    entity.Hold
+	userID  int
+	entryID int
+}
+
+func (r *Hold) expireDue(ctx context.Context, tx *sql.Tx, now time.Time, limit int) (int, error) {
+	// Резервы упорядочены по пользователю, чтобы строки балансов блокировались
+	// в одном порядке.
+	rows, err := tx.QueryContext(ctx, `
+SELECT id, user_id, order_num, amount, entry_id
+FROM holds
+WHERE status = 'ACTIVE'
+  AND expires_at <= $1
+ORDER BY user_id, id
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+	`, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []dueHold
+	for rows.Next() {
+		d := dueHold{}
+		if err = rows.Scan(&d.hold.ID, &d.userID, &d.hold.Order, &d.hold.Sum, &d.entryID); err != nil {
+			_ = rows.Close()
+
+			return 0, err
+		}
+
+		due = append(due, d)
+	}
+
+	if err = rows.Close(); err != nil {
+		return 0, err
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		if err = release(ctx, tx, due[i].userID, &due[i].hold, due[i].entryID, entity.HoldStatusExpired, r.pointsTTL); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(due), nil
+}
+
+// FindAllByUserID возвращает список резервов пользователя. Данные отсортированы
+// по времени создания от самых старых к самым новым.
+func (r *Hold) FindAllByUserID(ctx context.Context, userID int) (holds []entity.Hold, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, order_num, amount, status, expires_at, created_at
+FROM holds
+WHERE user_id = $1
+ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		h := entity.Hold{}
+		err = rows.Scan(&h.ID, &h.Order, &h.Sum, &h.Status, &h.ExpiresAt, &h.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		holds = append(holds, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holds, err
+}
+
+// update блокирует активный резерв пользователя и изменяет его функцией fn в одной транзакции.
+func (r *Hold) update(
+	ctx context.Context,
+	userID int,
+	id int,
+	fn func(tx *sql.Tx, h *entity.Hold, entryID int) error,
+) (entity.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Hold{}, err
+	}
+
+	var (
+		h       = entity.Hold{}
+		entryID = 0
+	)
+	err = tx.QueryRowContext(ctx, `
+SELECT id, order_num, amount, status, expires_at, created_at, entry_id
+FROM holds
+WHERE id = $1
+  AND user_id = $2
+FOR UPDATE
+	`, id, userID).Scan(&h.ID, &h.Order, &h.Sum, &h.Status, &h.ExpiresAt, &h.CreatedAt, &entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = inerr.ErrHoldNotFound
+	} else if err == nil && h.Status != entity.HoldStatusActive {
+		err = inerr.ErrHoldNotActive
+	}
+	if err == nil {
+		err = fn(tx, &h, entryID)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+
+		return entity.Hold{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return entity.Hold{}, err
+	}
+
+	return h, nil
+}
+
+// capture переводит баллы резерва h со счёта резервов на счёт погашений, добавляет
+// уведомления партнеров о списании и записывает событие в outbox.
+func capture(ctx context.Context, tx *sql.Tx, userID int, h *entity.Hold, entryID int) error {
+	hold, err := userAccount(ctx, tx, userID, entity.AccountTypeHold)
+	if err != nil {
+		return err
+	}
+
+	if _, err = lockBalance(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	system, err := systemAccount(ctx, tx, entity.AccountTypeRedemptionSink)
+	if err != nil {
+		return err
+	}
+
+	_, err = postEntry(
+		ctx,
+		tx,
+		entity.TransactionTypeOut,
+		h.Order,
+		&entryID,
+		posting{account: hold, amount: -h.Sum},
+		posting{account: system, amount: h.Sum},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = updateBalance(ctx, tx, userID, 0, -h.Sum, h.Sum); err != nil {
+		return err
+	}
+
+	data := map[string]any{"user_id": userID, "order": h.Order, "sum": h.Sum}
+	if err = enqueueWebhooks(ctx, tx, userID, entity.WebhookEventPointsWithdrawn, data); err != nil {
+		return err
+	}
+
+	if err = writeOutbox(ctx, tx, entity.OutboxEventPointsWithdrawn, data); err != nil {
+		return err
+	}
+
+	return setHoldStatus(ctx, tx, h, entity.HoldStatusCaptured)
+}
+
+// release возвращает баллы резерва h со счёта резервов на счёт пользователя в партии, из которых
+// они были зарезервированы, и переводит резерв в статус status.
+func release(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int,
+	h *entity.Hold,
+	entryID int,
+	status entity.HoldStatus,
+	pointsTTL time.Duration,
+) error {
+	user, err := userAccount(ctx, tx, userID, entity.AccountTypeUser)
+	if err != nil {
+		return err
+	}
+
+	hold, err := userAccount(ctx, tx, userID, entity.AccountTypeHold)
+	if err != nil {
+		return err
+	}
+
+	if _, err = lockBalance(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	releaseID, err := postEntry(
+		ctx,
+		tx,
+		entity.TransactionTypeRelease,
+		h.Order,
+		&entryID,
+		posting{account: hold, amount: -h.Sum},
+		posting{account: user, amount: h.Sum},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = restoreLots(ctx, tx, userID, entryID, releaseID, h.Sum, pointsTTL); err != nil {
+		return err
+	}
+
+	if err = updateBalance(ctx, tx, userID, h.Sum, -h.Sum, 0); err != nil {
+		return err
+	}
+
+	err = writeOutbox(ctx, tx, entity.OutboxEventPointsReleased, map[string]any{
+		"user_id": userID,
+		"hold_id": h.ID,
+		"order":   h.Order,
+		"sum":     h.Sum,
+		"status":  status,
+	})
+	if err != nil {
+		return err
+	}
+
+	return setHoldStatus(ctx, tx, h, status)
+}
+
+func setHoldStatus(ctx context.Context, tx *sql.Tx, h *entity.Hold, status entity.HoldStatus) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1 WHERE id = $2", status, h.ID); err != nil {
+		return err
+	}
+
+	h.Status = status
+
+	return nil
+}
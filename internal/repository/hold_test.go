@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const (
+	lockHoldQuery = `
+SELECT id, order_num, amount, status, expires_at, created_at, entry_id
+FROM holds
+WHERE id = $1
+  AND user_id = $2
+FOR UPDATE
+`
+	setHoldStatusQuery = "UPDATE holds SET status = $1 WHERE id = $2"
+)
+
+// expectHoldAccounts добавляет ожидания получения счетов пользователя и блокировки его баланса.
+func expectHoldAccounts(mock sqlmock.Sqlmock, userID int, types ...entity.AccountType) {
+	for i, t := range types {
+		mock.ExpectQuery(userAccountQuery).
+			WithArgs(t, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10 + i))
+	}
+	mock.ExpectExec(createBalanceQuery).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lockBalanceQuery).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("100.00"))
+}
+
+// expectRelease добавляет ожидания запросов, которые выполняет release, если в партии
+// резерва возвращается restored баллов.
+func expectRelease(
+	mock sqlmock.Sqlmock,
+	userID int,
+	h entity.Hold,
+	entryID int,
+	restored entity.Amount,
+	status entity.HoldStatus,
+) {
+	expectHoldAccounts(mock, userID, entity.AccountTypeUser, entity.AccountTypeHold)
+	mock.ExpectQuery(insertEntryQuery).
+		WithArgs(entity.TransactionTypeRelease, h.Order, entryID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(200))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(200, 11, -h.Sum).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(200, 10, h.Sum).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery(restoreLotsQuery).
+		WithArgs(entryID, h.Sum).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(restored.String()))
+	if restored < h.Sum {
+		mock.ExpectExec(addLotQuery).
+			WithArgs(userID, 200, h.Sum-restored, testPointsTTL.Seconds()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	expectUpdateBalance(mock, userID, h.Sum, -h.Sum, entity.Amount(0))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventPointsReleased,
+			fmt.Sprintf(
+				`{"hold_id":%d,"order":"%s","status":"%s","sum":%s,"user_id":%d}`,
+				h.ID, h.Order, status, h.Sum, userID,
+			),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(setHoldStatusQuery).
+		WithArgs(status, h.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestHold_Create(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		userID      = 1
+		order       = "2377225624"
+		sum         = entity.Amount(30_00)
+		expiresAt   = time.Now().Add(time.Hour)
+		createdAt   = time.Now()
+		orderQuery  = "INSERT INTO orders (user_id, num) VALUES ($1, $2)"
+		insertQuery = "INSERT INTO holds (user_id, order_num, amount, entry_id, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewHold(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(orderQuery).WithArgs(userID, order).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectHoldAccounts(mock, userID, entity.AccountTypeUser, entity.AccountTypeHold)
+	mock.ExpectQuery(insertEntryQuery).
+		WithArgs(entity.TransactionTypeHold, order, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(100, 10, -sum).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(100, 11, sum).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(consumeLotsQuery).
+		WithArgs(userID, sum, 100).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectUpdateBalance(mock, userID, -sum, sum, entity.Amount(0))
+	mock.ExpectQuery(insertQuery).
+		WithArgs(userID, order, sum, 100, expiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, createdAt))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventPointsHeld,
+			fmt.Sprintf(`{"hold_id":7,"order":"%s","sum":%s,"user_id":%d}`, order, sum, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(orderQuery).WithArgs(userID, order).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectHoldAccounts(mock, userID, entity.AccountTypeUser, entity.AccountTypeHold)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(orderQuery).WithArgs(userID, order).WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
+	mock.ExpectRollback()
+
+	h, err := r.Create(ctx, userID, order, sum, expiresAt)
+	assert.NoError(t, err, "успешное резервирование баллов")
+	assert.Equal(
+		t,
+		entity.Hold{ID: 7, Order: order, Sum: sum, Status: entity.HoldStatusActive, ExpiresAt: expiresAt, CreatedAt: createdAt},
+		h,
+		"успешное резервирование баллов",
+	)
+
+	_, err = r.Create(ctx, userID, order, 100_01, expiresAt)
+	assert.ErrorIs(t, err, inerr.ErrInsufficientFunds, "на счету недостаточно баллов")
+
+	_, err = r.Create(ctx, userID, order, sum, expiresAt)
+	assert.ErrorIs(t, err, inerr.ErrOrderExists, "заказ с таким номером уже существует")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_Capture(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		userID  = 1
+		entryID = 100
+		now     = time.Now()
+		h       = entity.Hold{
+			ID:        7,
+			Order:     "2377225624",
+			Sum:       30_00,
+			Status:    entity.HoldStatusActive,
+			ExpiresAt: now.Add(time.Minute),
+			CreatedAt: now.Add(-time.Minute),
+		}
+		holdRow = func(h entity.Hold) *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"id", "order_num", "amount", "status", "expires_at", "created_at", "entry_id"}).
+				AddRow(h.ID, h.Order, h.Sum.String(), h.Status, h.ExpiresAt, h.CreatedAt, entryID)
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewHold(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).WithArgs(h.ID, userID).WillReturnRows(holdRow(h))
+	expectHoldAccounts(mock, userID, entity.AccountTypeHold)
+	mock.ExpectQuery(systemAccountQuery).
+		WithArgs(entity.AccountTypeRedemptionSink).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(insertEntryQuery).
+		WithArgs(entity.TransactionTypeOut, h.Order, entryID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(101))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(101, 10, -h.Sum).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(101, 2, h.Sum).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	expectUpdateBalance(mock, userID, entity.Amount(0), -h.Sum, h.Sum)
+	mock.ExpectExec(webhookQuery).
+		WithArgs(
+			entity.WebhookEventPointsWithdrawn,
+			fmt.Sprintf(`{"order":"%s","sum":%s,"user_id":%d}`, h.Order, h.Sum, userID),
+			userID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventPointsWithdrawn,
+			fmt.Sprintf(`{"order":"%s","sum":%s,"user_id":%d}`, h.Order, h.Sum, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(setHoldStatusQuery).
+		WithArgs(entity.HoldStatusCaptured, h.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	expired := h
+	expired.ExpiresAt = now
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).WithArgs(h.ID, userID).WillReturnRows(holdRow(expired))
+	mock.ExpectRollback()
+
+	voided := h
+	voided.Status = entity.HoldStatusVoided
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).WithArgs(h.ID, userID).WillReturnRows(holdRow(voided))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).WithArgs(h.ID, userID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	res, err := r.Capture(ctx, userID, h.ID, now)
+	assert.NoError(t, err, "успешное списание резерва")
+	captured := h
+	captured.Status = entity.HoldStatusCaptured
+	assert.Equal(t, captured, res, "успешное списание резерва")
+
+	_, err = r.Capture(ctx, userID, h.ID, now)
+	assert.ErrorIs(t, err, inerr.ErrHoldNotActive, "срок действия резерва истек")
+
+	_, err = r.Capture(ctx, userID, h.ID, now)
+	assert.ErrorIs(t, err, inerr.ErrHoldNotActive, "резерв отменен")
+
+	_, err = r.Capture(ctx, userID, h.ID, now)
+	assert.ErrorIs(t, err, inerr.ErrHoldNotFound, "резерв не найден")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_Void(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		userID  = 1
+		entryID = 100
+		h       = entity.Hold{
+			ID:        7,
+			Order:     "2377225624",
+			Sum:       30_00,
+			Status:    entity.HoldStatusActive,
+			ExpiresAt: time.Now().Add(time.Minute),
+			CreatedAt: time.Now(),
+		}
+		columns = []string{"id", "order_num", "amount", "status", "expires_at", "created_at", "entry_id"}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewHold(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).
+		WithArgs(h.ID, userID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(h.ID, h.Order, h.Sum.String(), h.Status, h.ExpiresAt, h.CreatedAt, entryID))
+	expectRelease(mock, userID, h, entryID, h.Sum, entity.HoldStatusVoided)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockHoldQuery).
+		WithArgs(h.ID, userID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(h.ID, h.Order, h.Sum.String(), entity.HoldStatusCaptured, h.ExpiresAt, h.CreatedAt, entryID))
+	mock.ExpectRollback()
+
+	res, err := r.Void(ctx, userID, h.ID)
+	assert.NoError(t, err, "успешная отмена резерва")
+	assert.Equal(t, entity.HoldStatusVoided, res.Status, "успешная отмена резерва")
+
+	_, err = r.Void(ctx, userID, h.ID)
+	assert.ErrorIs(t, err, inerr.ErrHoldNotActive, "резерв уже списан")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_ExpireDue(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		now      = time.Now()
+		limit    = 10
+		dueQuery = `
+SELECT id, user_id, order_num, amount, entry_id
+FROM holds
+WHERE status = 'ACTIVE'
+  AND expires_at <= $1
+ORDER BY user_id, id
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+		first  = entity.Hold{ID: 7, Order: "2377225624", Sum: 30_00}
+		second = entity.Hold{ID: 8, Order: "12345678903", Sum: 5_50}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewHold(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(dueQuery).
+		WithArgs(now, limit).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "order_num", "amount", "entry_id"}).
+				AddRow(first.ID, 1, first.Order, first.Sum.String(), 100).
+				AddRow(second.ID, 2, second.Order, second.Sum.String(), 101),
+		)
+	expectRelease(mock, 1, first, 100, first.Sum, entity.HoldStatusExpired)
+	// Партии второго резерва неизвестны, баллы зачисляются новой партией.
+	expectRelease(mock, 2, second, 101, 0, entity.HoldStatusExpired)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(dueQuery).WithArgs(now, limit).WillReturnError(errors.New(""))
+	mock.ExpectRollback()
+
+	n, err := r.ExpireDue(ctx, now, limit)
+	assert.NoError(t, err, "успешное истечение резервов")
+	assert.Equal(t, 2, n, "успешное истечение резервов")
+
+	_, err = r.ExpireDue(ctx, now, limit)
+	assert.Error(t, err, "ошибка при истечении резервов")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHold_FindAllByUserID(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		userID    = 1
+		errUserID = 2
+		holds     = []entity.Hold{
+			{
+				ID:        7,
+				Order:     "2377225624",
+				Sum:       30_00,
+				Status:    entity.HoldStatusCaptured,
+				ExpiresAt: time.Now(),
+				CreatedAt: time.Now(),
+			},
+		}
+		query = `
+SELECT id, order_num, amount, status, expires_at, created_at
+FROM holds
+WHERE user_id = $1
+ORDER BY created_at
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewHold(db, testPointsTTL)
+
+	rows := sqlmock.NewRows([]string{"id", "order_num", "amount", "status", "expires_at", "created_at"})
+	for _, h := range holds {
+		rows.AddRow(h.ID, h.Order, h.Sum.String(), h.Status, h.ExpiresAt, h.CreatedAt)
+	}
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs(errUserID).WillReturnError(errors.New(""))
+
+	found, err := r.FindAllByUserID(ctx, userID)
+	assert.NoError(t, err, "успешное получение резервов пользователя")
+	assert.Equal(t, holds, found, "успешное получение резервов пользователя")
+
+	_, err = r.FindAllByUserID(ctx, errUserID)
+	assert.Error(t, err, "ошибка при получении резервов пользователя")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"time"
+)
+
+// Idempotency хранит ключи идемпотентности запросов и ответы на них. Ключ действует
+// в течение ttl с момента первого запроса, после этого может быть использован повторно.
+// Ключ запроса, ответ на который не сохранен в течение staleAfter (например, из-за
+// аварийного завершения обработки), освобождается для повторного запроса.
+type Idempotency struct {
+	db         *sql.DB
+	ttl        time.Duration
+	staleAfter time.Duration
+}
+
+func NewIdempotency(db *sql.DB, ttl, staleAfter time.Duration) *Idempotency {
+	return &Idempotency{
+		db:         db,
+		ttl:        ttl,
+		staleAfter: staleAfter,
+	}
+}
+
+// Begin сохраняет ключ идемпотентности key пользователя с отпечатком запроса fingerprint.
+// Если ключ уже использован и не истек, возвращает сохраненную запись и false. Истекший
+// ключ и ключ зависшего запроса перезаписываются.
+func (r *Idempotency) Begin(ctx context.Context, userID int, key, fingerprint string) (entity.IdempotencyRecord, bool, error) {
+	created := false
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO idempotency_keys (user_id, key, fingerprint)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, key) DO UPDATE SET fingerprint  = excluded.fingerprint,
+                                         status_code  = NULL,
+                                         content_type = NULL,
+                                         body         = NULL,
+                                         created_at   = now()
+WHERE idempotency_keys.created_at < now() - make_interval(secs => $4)
+   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < now() - make_interval(secs => $5))
+RETURNING true
+	`, userID, key, fingerprint, r.ttl.Seconds(), r.staleAfter.Seconds()).Scan(&created)
+	if err == nil {
+		return entity.IdempotencyRecord{Fingerprint: fingerprint}, true, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return entity.IdempotencyRecord{}, false, err
+	}
+
+	var (
+		rec         = entity.IdempotencyRecord{}
+		status      sql.NullInt32
+		contentType sql.NullString
+	)
+	err = r.db.QueryRowContext(
+		ctx,
+		"SELECT fingerprint, status_code, content_type, body FROM idempotency_keys WHERE user_id = $1 AND key = $2",
+		userID,
+		key,
+	).Scan(&rec.Fingerprint, &status, &contentType, &rec.Body)
+	rec.Completed = status.Valid
+	rec.StatusCode = int(status.Int32)
+	rec.ContentType = contentType.String
+
+	return rec, false, err
+}
+
+// Complete сохраняет ответ на запрос с ключом идемпотентности key пользователя.
+func (r *Idempotency) Complete(ctx context.Context, userID int, key string, status int, contentType string, body []byte) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE idempotency_keys SET status_code = $1, content_type = $2, body = $3 WHERE user_id = $4 AND key = $5",
+		status,
+		contentType,
+		body,
+		userID,
+		key,
+	)
+
+	return err
+}
+
+// Delete удаляет ключ идемпотентности key пользователя, чтобы запрос можно было повторить.
+func (r *Idempotency) Delete(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+
+	return err
+}
+
+// DeleteExpired удаляет истекшие ключи идемпотентности. Возвращает количество удаленных ключей.
+func (r *Idempotency) DeleteExpired(ctx context.Context) (int, error) {
+	res, err := r.db.ExecContext(
+		ctx,
+		"DELETE FROM idempotency_keys WHERE created_at < now() - make_interval(secs => $1)",
+		r.ttl.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
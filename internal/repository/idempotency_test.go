@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotency_Begin(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		userID      = 1
+		key         = "key"
+		fingerprint = "fingerprint"
+		ttl         = time.Hour
+		staleAfter  = time.Minute
+		insertQuery = `
+INSERT INTO idempotency_keys (user_id, key, fingerprint)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, key) DO UPDATE SET fingerprint  = excluded.fingerprint,
+                                         status_code  = NULL,
+                                         content_type = NULL,
+                                         body         = NULL,
+                                         created_at   = now()
+WHERE idempotency_keys.created_at < now() - make_interval(secs => $4)
+   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < now() - make_interval(secs => $5))
+RETURNING true
+`
+		selectQuery = "SELECT fingerprint, status_code, content_type, body FROM idempotency_keys WHERE user_id = $1 AND key = $2"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewIdempotency(db, ttl, staleAfter)
+
+	mock.ExpectQuery(insertQuery).
+		WithArgs(userID, key, fingerprint, ttl.Seconds(), staleAfter.Seconds()).
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+
+	mock.ExpectQuery(insertQuery).
+		WithArgs(userID, key, fingerprint, ttl.Seconds(), staleAfter.Seconds()).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(selectQuery).
+		WithArgs(userID, key).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"fingerprint", "status_code", "content_type", "body"}).
+				AddRow(fingerprint, http.StatusOK, "application/json", []byte("{}")),
+		)
+
+	mock.ExpectQuery(insertQuery).
+		WithArgs(userID, key, fingerprint, ttl.Seconds(), staleAfter.Seconds()).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(selectQuery).
+		WithArgs(userID, key).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"fingerprint", "status_code", "content_type", "body"}).
+				AddRow(fingerprint, nil, nil, nil),
+		)
+
+	rec, created, err := r.Begin(ctx, userID, key, fingerprint)
+	assert.NoError(t, err, "новый ключ")
+	assert.True(t, created, "новый ключ")
+	assert.Equal(t, entity.IdempotencyRecord{Fingerprint: fingerprint}, rec, "новый ключ")
+
+	rec, created, err = r.Begin(ctx, userID, key, fingerprint)
+	assert.NoError(t, err, "ключ с сохраненным ответом")
+	assert.False(t, created, "ключ с сохраненным ответом")
+	assert.Equal(
+		t,
+		entity.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  http.StatusOK,
+			ContentType: "application/json",
+			Body:        []byte("{}"),
+		},
+		rec,
+		"ключ с сохраненным ответом",
+	)
+
+	rec, created, err = r.Begin(ctx, userID, key, fingerprint)
+	assert.NoError(t, err, "ключ запроса в обработке")
+	assert.False(t, created, "ключ запроса в обработке")
+	assert.False(t, rec.Completed, "ключ запроса в обработке")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_CompleteAndDelete(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		userID = 1
+		key    = "key"
+		body   = []byte("{}")
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewIdempotency(db, time.Hour, time.Minute)
+
+	mock.ExpectExec("UPDATE idempotency_keys SET status_code = $1, content_type = $2, body = $3 WHERE user_id = $4 AND key = $5").
+		WithArgs(http.StatusOK, "application/json", body, userID, key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2").
+		WithArgs(userID, key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, r.Complete(ctx, userID, key, http.StatusOK, "application/json", body), "сохранение ответа")
+	assert.NoError(t, r.Delete(ctx, userID, key), "удаление ключа")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotency_DeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewIdempotency(db, time.Hour, time.Minute)
+
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE created_at < now() - make_interval(secs => $1)").
+		WithArgs(time.Hour.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := r.DeleteExpired(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+// Ledger предоставляет доступ к журналу операций с баллами. Каждая операция записывается
+// в журнал записью с проводками по счетам, сумма проводок записи равна нулю: баллы
+// не появляются и не исчезают, а переходят между счетами. Остаток счёта равен сумме его проводок.
+type Ledger struct {
+	db *sql.DB
+}
+
+// posting - проводка по счёту: положительная сумма увеличивает остаток счёта, отрицательная - уменьшает.
+type posting struct {
+	account int
+	amount  entity.Amount
+}
+
+// counterparties - системные счета, с которыми проводятся операции по счёту пользователя.
+var counterparties = map[entity.TransactionType]entity.AccountType{
+	entity.TransactionTypeIn:  entity.AccountTypeAccrualSource,
+	entity.TransactionTypeOut: entity.AccountTypeRedemptionSink,
+}
+
+// actualBalancesQuery вычисляет балансы пользователей по журналу операций: доступные баллы
+// равны остатку счёта пользователя, зарезервированные - остатку счёта резервов.
+const actualBalancesQuery = `
+SELECT a.user_id,
+       coalesce(sum(p.amount) FILTER (WHERE a.type = 'USER'), 0)              current,
+       coalesce(sum(p.amount) FILTER (WHERE a.type = 'HOLD'), 0)              held,
+       coalesce(sum(-p.amount) FILTER (WHERE e.type IN ('OUT', 'REFUND')), 0) withdrawn
+FROM ledger_accounts a
+         LEFT JOIN postings p ON p.account_id = a.id
+         LEFT JOIN journal_entries e ON e.id = p.entry_id
+WHERE a.type IN ('USER', 'HOLD')
+GROUP BY a.user_id
+`
+
+// rebuildBalancesQuery записывает в таблицу balances балансы, вычисленные по журналу операций,
+// если они отличаются от сохраненных.
+const rebuildBalancesQuery = `
+INSERT INTO balances (user_id, current, held, withdrawn, updated_at)
+SELECT user_id, current, held, withdrawn, now()
+FROM (` + actualBalancesQuery + `) actual
+ON CONFLICT (user_id) DO UPDATE SET current    = excluded.current,
+                                    held       = excluded.held,
+                                    withdrawn  = excluded.withdrawn,
+                                    updated_at = excluded.updated_at
+WHERE balances.current <> excluded.current
+   OR balances.held <> excluded.held
+   OR balances.withdrawn <> excluded.withdrawn
+`
+
+func NewLedger(db *sql.DB) *Ledger {
+	return &Ledger{db: db}
+}
+
+// RebuildBalances пересчитывает балансы всех пользователей по журналу операций и возвращает
+// количество исправленных балансов. На время пересчета изменение балансов блокируется.
+func (r *Ledger) RebuildBalances(ctx context.Context) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, "LOCK TABLE balances IN EXCLUSIVE MODE"); err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, rebuildBalancesQuery)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// Reconcile выполняет сверку журнала: находит несбалансированные записи, пользователей
+// со счетами с отрицательным остатком и балансы, не совпадающие с остатками счетов.
+func (r *Ledger) Reconcile(ctx context.Context) (entity.Reconciliation, error) {
+	res := entity.Reconciliation{
+		UnbalancedEntries:  []int{},
+		NegativeAccounts:   []int{},
+		MismatchedBalances: []int{},
+	}
+	if err := r.db.QueryRowContext(ctx, "SELECT coalesce(sum(amount), 0) FROM postings").Scan(&res.Total); err != nil {
+		return res, err
+	}
+
+	var err error
+	res.UnbalancedEntries, err = r.ids(ctx, `
+SELECT e.id
+FROM journal_entries e
+         LEFT JOIN postings p ON p.entry_id = e.id
+GROUP BY e.id
+HAVING coalesce(sum(p.amount), 0) <> 0
+    OR count(p.id) < 2
+ORDER BY e.id
+	`)
+	if err != nil {
+		return res, err
+	}
+
+	res.NegativeAccounts, err = r.ids(ctx, `
+SELECT a.user_id
+FROM ledger_accounts a
+         JOIN postings p ON p.account_id = a.id
+WHERE a.type IN ('USER', 'HOLD')
+GROUP BY a.id
+HAVING sum(p.amount) < 0
+ORDER BY a.user_id
+	`)
+	if err != nil {
+		return res, err
+	}
+
+	res.MismatchedBalances, err = r.ids(ctx, `
+WITH actual AS (`+actualBalancesQuery+`)
+SELECT a.user_id
+FROM actual a
+         LEFT JOIN balances b ON b.user_id = a.user_id
+WHERE b.user_id IS NULL AND (a.current <> 0 OR a.held <> 0 OR a.withdrawn <> 0)
+   OR b.current <> a.current
+   OR b.held <> a.held
+   OR b.withdrawn <> a.withdrawn
+ORDER BY a.user_id
+	`)
+	if err != nil {
+		return res, err
+	}
+
+	res.Balanced = res.Total == 0 &&
+		len(res.UnbalancedEntries) == 0 &&
+		len(res.NegativeAccounts) == 0 &&
+		len(res.MismatchedBalances) == 0
+
+	return res, nil
+}
+
+func (r *Ledger) ids(ctx context.Context, query string) (ids []int, err error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}(rows)
+
+	ids = []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, err
+}
+
+// postTransaction записывает в журнал начисление или списание баллов пользователя и обновляет
+// его баланс. Начисление переводит sum со счёта источника начислений на счёт пользователя,
+// списание - со счёта пользователя на счёт погашений. Строка баланса пользователя блокируется
+// до конца транзакции tx, поэтому параллельные операции по одному пользователю выполняются
+// последовательно. Начисление образует партию баллов со сроком действия pointsTTL, списание
+// уменьшает остатки партий. Если на счету пользователя недостаточно баллов для списания,
+// возвращает ошибку errors.ErrInsufficientFunds.
+func postTransaction(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int,
+	order string,
+	sum entity.Amount,
+	t entity.TransactionType,
+	pointsTTL time.Duration,
+) error {
+	user, err := userAccount(ctx, tx, userID, entity.AccountTypeUser)
+	if err != nil {
+		return err
+	}
+
+	current, err := lockBalance(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	withdrawn := entity.Amount(0)
+	if t == entity.TransactionTypeOut {
+		if current < sum {
+			return inerr.ErrInsufficientFunds
+		}
+
+		withdrawn = sum
+		sum = -sum
+	}
+
+	system, err := systemAccount(ctx, tx, counterparties[t])
+	if err != nil {
+		return err
+	}
+
+	entryID, err := postEntry(ctx, tx, t, order, nil, posting{account: user, amount: sum}, posting{account: system, amount: -sum})
+	if err != nil {
+		return err
+	}
+
+	if t == entity.TransactionTypeOut {
+		err = consumeLots(ctx, tx, userID, entryID, withdrawn)
+	} else {
+		err = addLot(ctx, tx, userID, entryID, sum, pointsTTL)
+	}
+	if err != nil {
+		return err
+	}
+
+	return updateBalance(ctx, tx, userID, sum, 0, withdrawn)
+}
+
+// postEntry создает запись журнала типа t с проводками postings и возвращает ее идентификатор.
+// reference - идентификатор записи, к которой относится создаваемая (например, списание
+// для возврата), или nil. Баланс записи проверяется базой данных при фиксации транзакции.
+func postEntry(
+	ctx context.Context,
+	tx *sql.Tx,
+	t entity.TransactionType,
+	order string,
+	reference *int,
+	postings ...posting,
+) (int, error) {
+	// Записи, не связанные с заказом (переводы между пользователями), сохраняются без номера заказа.
+	var orderNum any
+	if order != "" {
+		orderNum = order
+	}
+
+	id := 0
+	err := tx.QueryRowContext(
+		ctx,
+		"INSERT INTO journal_entries (type, order_num, reference_id) VALUES ($1, $2, $3) RETURNING id",
+		t,
+		orderNum,
+		reference,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range postings {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO postings (entry_id, account_id, amount) VALUES ($1, $2, $3)",
+			id,
+			p.account,
+			p.amount,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// lockBalance блокирует строку баланса пользователя до конца транзакции tx, создавая ее
+// при первом обращении, и возвращает текущий баланс.
+func lockBalance(ctx context.Context, tx *sql.Tx, userID int) (entity.Amount, error) {
+	if _, err := tx.ExecContext(ctx, "INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING", userID); err != nil {
+		return 0, err
+	}
+
+	var current entity.Amount
+	err := tx.QueryRowContext(ctx, "SELECT current FROM balances WHERE user_id = $1 FOR UPDATE", userID).Scan(&current)
+
+	return current, err
+}
+
+// updateBalance изменяет доступные баллы пользователя на current, зарезервированные - на held,
+// а сумму списанных баллов - на withdrawn, и публикует событие изменения баланса. Если баланс
+// становится отрицательным, возвращает ошибку errors.ErrInsufficientFunds.
+func updateBalance(ctx context.Context, tx *sql.Tx, userID int, current, held, withdrawn entity.Amount) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"UPDATE balances SET current = current + $1, held = held + $2, withdrawn = withdrawn + $3, updated_at = now() WHERE user_id = $4",
+		current,
+		held,
+		withdrawn,
+		userID,
+	)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+		return inerr.ErrInsufficientFunds
+	}
+	if err != nil {
+		return err
+	}
+
+	return notifyBalance(ctx, tx, userID)
+}
+
+// userAccount возвращает идентификатор счёта пользователя типа t (entity.AccountTypeUser
+// или entity.AccountTypeHold), создавая счёт при первом обращении.
+func userAccount(ctx context.Context, tx *sql.Tx, userID int, t entity.AccountType) (int, error) {
+	id := 0
+	err := tx.QueryRowContext(ctx, `
+INSERT INTO ledger_accounts (type, user_id)
+VALUES ($1, $2)
+ON CONFLICT (type, user_id) DO UPDATE SET user_id = excluded.user_id
+RETURNING id
+	`, t, userID).Scan(&id)
+
+	return id, err
+}
+
+// systemAccount возвращает идентификатор системного счёта типа t.
+func systemAccount(ctx context.Context, tx *sql.Tx, t entity.AccountType) (int, error) {
+	id := 0
+	err := tx.QueryRowContext(ctx, "SELECT id FROM ledger_accounts WHERE type = $1 AND user_id IS NULL", t).Scan(&id)
+
+	return id, err
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const (
+	userAccountQuery = `
+INSERT INTO ledger_accounts (type, user_id)
+VALUES ($1, $2)
+ON CONFLICT (type, user_id) DO UPDATE SET user_id = excluded.user_id
+RETURNING id
+`
+	systemAccountQuery = "SELECT id FROM ledger_accounts WHERE type = $1 AND user_id IS NULL"
+	insertEntryQuery   = "INSERT INTO journal_entries (type, order_num, reference_id) VALUES ($1, $2, $3) RETURNING id"
+	insertPostingQuery = "INSERT INTO postings (entry_id, account_id, amount) VALUES ($1, $2, $3)"
+	createBalanceQuery = "INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING"
+	lockBalanceQuery   = "SELECT current FROM balances WHERE user_id = $1 FOR UPDATE"
+	updateBalanceQuery = "UPDATE balances SET current = current + $1, held = held + $2, withdrawn = withdrawn + $3, updated_at = now() WHERE user_id = $4"
+	addLotQuery        = "INSERT INTO accrual_lots (user_id, entry_id, amount, remaining, expires_at) VALUES ($1, $2, $3, $3, now() + make_interval(secs => $4))"
+	consumeLotsQuery   = `
+WITH c AS (SELECT id,
+                  least(remaining, $2::numeric - (sum(remaining) OVER (ORDER BY created_at, id) - remaining)) take
+           FROM accrual_lots
+           WHERE user_id = $1
+             AND remaining > 0),
+     u AS (UPDATE accrual_lots l
+         SET remaining = l.remaining - c.take
+         FROM c
+         WHERE l.id = c.id
+           AND c.take > 0
+         RETURNING l.id, c.take)
+INSERT
+INTO lot_consumptions (lot_id, entry_id, amount)
+SELECT id, $3, take
+FROM u
+`
+	restoreLotsQuery = `
+WITH c AS (SELECT lc.id,
+                  lc.lot_id,
+                  l.expires_at <= now() expired,
+                  least(lc.amount - lc.restored,
+                        $2::numeric - (sum(lc.amount - lc.restored) OVER (ORDER BY l.created_at, l.id) -
+                                       (lc.amount - lc.restored))) give
+           FROM lot_consumptions lc
+                    JOIN accrual_lots l ON l.id = lc.lot_id
+           WHERE lc.entry_id = $1
+             AND lc.restored < lc.amount),
+     u AS (UPDATE lot_consumptions lc
+         SET restored = lc.restored + c.give
+         FROM c
+         WHERE lc.id = c.id
+           AND c.give > 0
+         RETURNING c.lot_id, c.expired, c.give),
+     r AS (UPDATE accrual_lots l
+         SET remaining = l.remaining + u.give
+         FROM u
+         WHERE l.id = u.lot_id
+           AND u.expired IS NOT TRUE
+         RETURNING u.give)
+SELECT coalesce(sum(give), 0)
+FROM r
+`
+	carryLotsQuery = `
+WITH n AS (
+    INSERT INTO accrual_lots (user_id, entry_id, amount, remaining, expires_at)
+        SELECT $1, $2, sum(lc.amount), sum(lc.amount), l.expires_at
+        FROM lot_consumptions lc
+                 JOIN accrual_lots l ON l.id = lc.lot_id
+        WHERE lc.entry_id = $2
+        GROUP BY l.expires_at
+        RETURNING amount)
+SELECT coalesce(sum(amount), 0)
+FROM n
+`
+	// testPointsTTL - срок действия баллов, с которым создаются репозитории в тестах.
+	testPointsTTL = 24 * time.Hour
+)
+
+// expectPostTransaction добавляет ожидания запросов, которые выполняет postTransaction, если
+// текущий баланс пользователя равен current. Если err не nil, обновление баланса завершается
+// ошибкой err.
+func expectPostTransaction(
+	mock sqlmock.Sqlmock,
+	userID int,
+	order string,
+	sum entity.Amount,
+	t entity.TransactionType,
+	current entity.Amount,
+	err error,
+) {
+	var (
+		userAcc   = 10
+		systemAcc = 1
+		entryID   = 100
+		withdrawn = entity.Amount(0)
+	)
+
+	mock.ExpectQuery(userAccountQuery).
+		WithArgs(entity.AccountTypeUser, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userAcc))
+	mock.ExpectExec(createBalanceQuery).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lockBalanceQuery).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow(current.String()))
+	if t == entity.TransactionTypeOut {
+		if current < sum {
+			return
+		}
+
+		withdrawn = sum
+		sum = -sum
+	}
+
+	mock.ExpectQuery(systemAccountQuery).
+		WithArgs(counterparties[t]).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(systemAcc))
+	mock.ExpectQuery(insertEntryQuery).
+		WithArgs(t, order, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(entryID))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(entryID, userAcc, sum).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(entryID, systemAcc, -sum).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	if t == entity.TransactionTypeOut {
+		mock.ExpectExec(consumeLotsQuery).
+			WithArgs(userID, withdrawn, entryID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	} else {
+		mock.ExpectExec(addLotQuery).
+			WithArgs(userID, entryID, sum, testPointsTTL.Seconds()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	e := mock.ExpectExec(updateBalanceQuery).WithArgs(sum, entity.Amount(0), withdrawn, userID)
+	if err != nil {
+		e.WillReturnError(err)
+
+		return
+	}
+
+	e.WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(balanceEventQuery).
+		WithArgs(EventsChannel, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectUpdateBalance добавляет ожидания запросов, которые выполняет updateBalance.
+func expectUpdateBalance(mock sqlmock.Sqlmock, userID int, current, held, withdrawn entity.Amount) {
+	mock.ExpectExec(updateBalanceQuery).
+		WithArgs(current, held, withdrawn, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(balanceEventQuery).
+		WithArgs(EventsChannel, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestPostTransaction(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		userID = 1
+		order  = "148561163482734"
+		sum    = entity.Amount(100_50)
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	for _, tt := range []entity.TransactionType{entity.TransactionTypeIn, entity.TransactionTypeOut} {
+		mock.ExpectBegin()
+		expectPostTransaction(mock, userID, order, sum, tt, sum, nil)
+		mock.ExpectCommit()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		assert.NoError(t, postTransaction(ctx, tx, userID, order, sum, tt, testPointsTTL), "успешная запись операции %s", tt)
+		require.NoError(t, tx.Commit())
+	}
+
+	tests := []struct {
+		name    string
+		current entity.Amount
+		err     error
+	}{
+		{
+			name:    "недостаточно баллов для списания",
+			current: sum - 1,
+		},
+		{
+			name:    "баланс стал отрицательным при обновлении",
+			current: sum,
+			err:     &pgconn.PgError{Code: pgerrcode.CheckViolation},
+		},
+	}
+	for _, tt := range tests {
+		mock.ExpectBegin()
+		expectPostTransaction(mock, userID, order, sum, entity.TransactionTypeOut, tt.current, tt.err)
+		mock.ExpectRollback()
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		assert.ErrorIs(
+			t,
+			postTransaction(ctx, tx, userID, order, sum, entity.TransactionTypeOut, testPointsTTL),
+			inerr.ErrInsufficientFunds,
+			tt.name,
+		)
+		require.NoError(t, tx.Rollback())
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedger_Reconcile(t *testing.T) {
+	var (
+		ctx             = context.Background()
+		totalQuery      = "SELECT coalesce(sum(amount), 0) FROM postings"
+		unbalancedQuery = `
+SELECT e.id
+FROM journal_entries e
+         LEFT JOIN postings p ON p.entry_id = e.id
+GROUP BY e.id
+HAVING coalesce(sum(p.amount), 0) <> 0
+    OR count(p.id) < 2
+ORDER BY e.id
+`
+		negativeQuery = `
+SELECT a.user_id
+FROM ledger_accounts a
+         JOIN postings p ON p.account_id = a.id
+WHERE a.type IN ('USER', 'HOLD')
+GROUP BY a.id
+HAVING sum(p.amount) < 0
+ORDER BY a.user_id
+`
+		mismatchedQuery = `
+WITH actual AS (` + actualBalancesQuery + `)
+SELECT a.user_id
+FROM actual a
+         LEFT JOIN balances b ON b.user_id = a.user_id
+WHERE b.user_id IS NULL AND (a.current <> 0 OR a.held <> 0 OR a.withdrawn <> 0)
+   OR b.current <> a.current
+   OR b.held <> a.held
+   OR b.withdrawn <> a.withdrawn
+ORDER BY a.user_id
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewLedger(db)
+
+	mock.ExpectQuery(totalQuery).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("0.00"))
+	mock.ExpectQuery(unbalancedQuery).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(negativeQuery).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery(mismatchedQuery).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	mock.ExpectQuery(totalQuery).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("-1.50"))
+	mock.ExpectQuery(unbalancedQuery).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(7))
+	mock.ExpectQuery(negativeQuery).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2))
+	mock.ExpectQuery(mismatchedQuery).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(2).AddRow(5))
+
+	mock.ExpectQuery(totalQuery).WillReturnError(errors.New(""))
+
+	res, err := r.Reconcile(ctx)
+	assert.NoError(t, err, "сверка сбалансированного журнала")
+	assert.Equal(
+		t,
+		entity.Reconciliation{Balanced: true, UnbalancedEntries: []int{}, NegativeAccounts: []int{}, MismatchedBalances: []int{}},
+		res,
+		"сверка сбалансированного журнала",
+	)
+
+	res, err = r.Reconcile(ctx)
+	assert.NoError(t, err, "сверка несбалансированного журнала")
+	assert.Equal(
+		t,
+		entity.Reconciliation{
+			UnbalancedEntries:  []int{3, 7},
+			NegativeAccounts:   []int{2},
+			MismatchedBalances: []int{2, 5},
+			Total:              -1_50,
+		},
+		res,
+		"сверка несбалансированного журнала",
+	)
+
+	_, err = r.Reconcile(ctx)
+	assert.Error(t, err, "ошибка при сверке журнала")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedger_RebuildBalances(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewLedger(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("LOCK TABLE balances IN EXCLUSIVE MODE").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(rebuildBalancesQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("LOCK TABLE balances IN EXCLUSIVE MODE").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(rebuildBalancesQuery).WillReturnError(errors.New(""))
+	mock.ExpectRollback()
+
+	n, err := r.RebuildBalances(ctx)
+	assert.NoError(t, err, "успешный пересчет балансов")
+	assert.Equal(t, int64(2), n, "успешный пересчет балансов")
+
+	_, err = r.RebuildBalances(ctx)
+	assert.Error(t, err, "ошибка при пересчете балансов")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"time"
+)
+
+// Lot предоставляет доступ к партиям баллов. Каждое зачисление баллов на счёт пользователя
+// (начисление за заказ) образует партию со своим сроком действия, списания уменьшают остатки
+// партий в порядке зачисления (FIFO). Возврат списания и отмена резерва возвращают баллы в те
+// партии, из которых они были списаны, если их срок действия не истек. Сумма остатков партий пользователя равна его
+// доступному балансу. Остатки партий изменяются только при заблокированной строке баланса
+// пользователя.
+type Lot struct {
+	db *sql.DB
+}
+
+func NewLot(db *sql.DB) *Lot {
+	return &Lot{db: db}
+}
+
+// ExpireDue списывает остатки партий не более чем limit пользователей, срок действия которых
+// истек к моменту now, и возвращает количество истекших партий. Истечение каждой партии
+// записывается в журнал операций записью, связанной с записью зачисления партии.
+func (r *Lot) ExpireDue(ctx context.Context, now time.Time, limit int) (int, error) {
+	users, err := r.dueUsers(ctx, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, userID := range users {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return expired, err
+		}
+
+		n, err := expireUserLots(ctx, tx, userID, now)
+		if err != nil {
+			_ = tx.Rollback()
+
+			return expired, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			_ = tx.Rollback()
+
+			return expired, err
+		}
+
+		expired += n
+	}
+
+	return expired, nil
+}
+
+// FindUpcoming возвращает остатки партий пользователя, срок действия которых истекает
+// до момента until. Данные отсортированы по сроку действия.
+func (r *Lot) FindUpcoming(ctx context.Context, userID int, until time.Time) (expirations []entity.Expiration, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT remaining, expires_at
+FROM accrual_lots
+WHERE user_id = $1
+  AND remaining > 0
+  AND expires_at <= $2
+ORDER BY expires_at, id
+	`, userID, until)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		e := entity.Expiration{}
+		err = rows.Scan(&e.Sum, &e.ExpiresAt)
+		if err != nil {
+			continue
+		}
+
+		expirations = append(expirations, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return expirations, err
+}
+
+func (r *Lot) dueUsers(ctx context.Context, now time.Time, limit int) (users []int, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT DISTINCT user_id
+FROM accrual_lots
+WHERE remaining > 0
+  AND expires_at <= $1
+ORDER BY user_id
+LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}(rows)
+
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		users = append(users, id)
+	}
+
+	return users, rows.Err()
+}
+
+// dueLot - партия с истекшим сроком действия.
+type dueLot struct {
+	id        int
+	remaining entity.Amount
+	order     string
+	entryID   int
+}
+
+// expireUserLots списывает остатки партий пользователя, срок действия которых истек к моменту
+// now, на счёт истекших баллов, записывает событие в outbox и возвращает количество истекших
+// партий.
+func expireUserLots(ctx context.Context, tx *sql.Tx, userID int, now time.Time) (int, error) {
+	user, err := userAccount(ctx, tx, userID, entity.AccountTypeUser)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = lockBalance(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+
+	lots, err := findDueLots(ctx, tx, userID, now)
+	if err != nil || len(lots) == 0 {
+		return 0, err
+	}
+
+	system, err := systemAccount(ctx, tx, entity.AccountTypeExpirationSink)
+	if err != nil {
+		return 0, err
+	}
+
+	total := entity.Amount(0)
+	for _, l := range lots {
+		_, err = postEntry(
+			ctx,
+			tx,
+			entity.TransactionTypeExpire,
+			l.order,
+			&l.entryID,
+			posting{account: user, amount: -l.remaining},
+			posting{account: system, amount: l.remaining},
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = tx.ExecContext(ctx, "UPDATE accrual_lots SET remaining = 0 WHERE id = $1", l.id); err != nil {
+			return 0, err
+		}
+
+		total += l.remaining
+	}
+
+	if err = updateBalance(ctx, tx, userID, -total, 0, 0); err != nil {
+		return 0, err
+	}
+
+	err = writeOutbox(ctx, tx, entity.OutboxEventPointsExpired, map[string]any{
+		"user_id": userID,
+		"sum":     total,
+	})
+
+	return len(lots), err
+}
+
+func findDueLots(ctx context.Context, tx *sql.Tx, userID int, now time.Time) (lots []dueLot, err error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT l.id, l.remaining, coalesce(e.order_num, ''), e.id
+FROM accrual_lots l
+         JOIN journal_entries e ON e.id = l.entry_id
+WHERE l.user_id = $1
+  AND l.remaining > 0
+  AND l.expires_at <= $2
+ORDER BY l.id
+	`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}(rows)
+
+	for rows.Next() {
+		l := dueLot{}
+		if err = rows.Scan(&l.id, &l.remaining, &l.order, &l.entryID); err != nil {
+			return nil, err
+		}
+
+		lots = append(lots, l)
+	}
+
+	return lots, rows.Err()
+}
+
+// addLot создает партию из sum баллов, зачисленных на счёт пользователя записью журнала entryID.
+// Срок действия партии равен pointsTTL с момента зачисления, при нулевом pointsTTL партия бессрочна.
+func addLot(ctx context.Context, tx *sql.Tx, userID, entryID int, sum entity.Amount, pointsTTL time.Duration) error {
+	var ttl any
+	if pointsTTL > 0 {
+		ttl = pointsTTL.Seconds()
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO accrual_lots (user_id, entry_id, amount, remaining, expires_at) VALUES ($1, $2, $3, $3, now() + make_interval(secs => $4))",
+		userID,
+		entryID,
+		sum,
+		ttl,
+	)
+
+	return err
+}
+
+// consumeLots уменьшает остатки партий пользователя на sum в порядке зачисления (сначала самые
+// старые партии) и запоминает, из каких партий баллы списаны записью журнала entryID. Баланс пользователя проверяется до вызова, поэтому если остатков
+// партий не хватает (например, из-за расхождения с балансом), списываются все оставшиеся.
+func consumeLots(ctx context.Context, tx *sql.Tx, userID, entryID int, sum entity.Amount) error {
+	_, err := tx.ExecContext(ctx, `
+WITH c AS (SELECT id,
+                  least(remaining, $2::numeric - (sum(remaining) OVER (ORDER BY created_at, id) - remaining)) take
+           FROM accrual_lots
+           WHERE user_id = $1
+             AND remaining > 0),
+     u AS (UPDATE accrual_lots l
+         SET remaining = l.remaining - c.take
+         FROM c
+         WHERE l.id = c.id
+           AND c.take > 0
+         RETURNING l.id, c.take)
+INSERT
+INTO lot_consumptions (lot_id, entry_id, amount)
+SELECT id, $3, take
+FROM u
+	`, userID, sum, entryID)
+
+	return err
+}
+
+// restoreLots возвращает sum баллов в партии, из которых они были списаны записью журнала
+// spendID, сохраняя сроки действия партий. Баллы партий, срок действия которых уже истек,
+// и баллы, списание которых не отражено в партиях (например, зарезервированные до введения
+// партий), зачисляются новой партией записи creditID со сроком действия pointsTTL.
+func restoreLots(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int,
+	spendID int,
+	creditID int,
+	sum entity.Amount,
+	pointsTTL time.Duration,
+) error {
+	restored := entity.Amount(0)
+	err := tx.QueryRowContext(ctx, `
+WITH c AS (SELECT lc.id,
+                  lc.lot_id,
+                  l.expires_at <= now() expired,
+                  least(lc.amount - lc.restored,
+                        $2::numeric - (sum(lc.amount - lc.restored) OVER (ORDER BY l.created_at, l.id) -
+                                       (lc.amount - lc.restored))) give
+           FROM lot_consumptions lc
+                    JOIN accrual_lots l ON l.id = lc.lot_id
+           WHERE lc.entry_id = $1
+             AND lc.restored < lc.amount),
+     u AS (UPDATE lot_consumptions lc
+         SET restored = lc.restored + c.give
+         FROM c
+         WHERE lc.id = c.id
+           AND c.give > 0
+         RETURNING c.lot_id, c.expired, c.give),
+     r AS (UPDATE accrual_lots l
+         SET remaining = l.remaining + u.give
+         FROM u
+         WHERE l.id = u.lot_id
+           AND u.expired IS NOT TRUE
+         RETURNING u.give)
+SELECT coalesce(sum(give), 0)
+FROM r
+	`, spendID, sum).Scan(&restored)
+	if err != nil || restored >= sum {
+		return err
+	}
+
+	return addLot(ctx, tx, userID, creditID, sum-restored, pointsTTL)
+}
+
+// carryLots зачисляет пользователю sum баллов, списанных из партий записью журнала entryID,
+// партиями с теми же сроками действия. Баллы, списание которых не отражено в партиях,
+// зачисляются новой партией со сроком действия pointsTTL.
+func carryLots(ctx context.Context, tx *sql.Tx, userID, entryID int, sum entity.Amount, pointsTTL time.Duration) error {
+	carried := entity.Amount(0)
+	err := tx.QueryRowContext(ctx, `
+WITH n AS (
+    INSERT INTO accrual_lots (user_id, entry_id, amount, remaining, expires_at)
+        SELECT $1, $2, sum(lc.amount), sum(lc.amount), l.expires_at
+        FROM lot_consumptions lc
+                 JOIN accrual_lots l ON l.id = lc.lot_id
+        WHERE lc.entry_id = $2
+        GROUP BY l.expires_at
+        RETURNING amount)
+SELECT coalesce(sum(amount), 0)
+FROM n
+	`, userID, entryID).Scan(&carried)
+	if err != nil || carried >= sum {
+		return err
+	}
+
+	return addLot(ctx, tx, userID, entryID, sum-carried, pointsTTL)
+}
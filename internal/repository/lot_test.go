@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAddLot(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		userID = 1
+		sum    = entity.Amount(100_00)
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(addLotQuery).
+		WithArgs(userID, 5, sum, float64(3600)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(addLotQuery).
+		WithArgs(userID, 6, sum, nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	assert.NoError(t, addLot(ctx, tx, userID, 5, sum, time.Hour), "партия с ограниченным сроком действия")
+	assert.NoError(t, addLot(ctx, tx, userID, 6, sum, 0), "бессрочная партия")
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLot_ExpireDue(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		now        = time.Now()
+		limit      = 10
+		userID     = 1
+		usersQuery = `
+SELECT DISTINCT user_id
+FROM accrual_lots
+WHERE remaining > 0
+  AND expires_at <= $1
+ORDER BY user_id
+LIMIT $2
+`
+		lotsQuery = `
+SELECT l.id, l.remaining, coalesce(e.order_num, ''), e.id
+FROM accrual_lots l
+         JOIN journal_entries e ON e.id = l.entry_id
+WHERE l.user_id = $1
+  AND l.remaining > 0
+  AND l.expires_at <= $2
+ORDER BY l.id
+`
+		expireQuery = "UPDATE accrual_lots SET remaining = 0 WHERE id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewLot(db)
+
+	mock.ExpectQuery(usersQuery).
+		WithArgs(now, limit).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectBegin()
+	mock.ExpectQuery(userAccountQuery).
+		WithArgs(entity.AccountTypeUser, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectExec(createBalanceQuery).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lockBalanceQuery).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("100.00"))
+	mock.ExpectQuery(lotsQuery).
+		WithArgs(userID, now).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "remaining", "order_num", "entry_id"}).
+				AddRow(3, "30.00", "2377225624", 100).
+				AddRow(4, "12.50", "12345678903", 101),
+		)
+	mock.ExpectQuery(systemAccountQuery).
+		WithArgs(entity.AccountTypeExpirationSink).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	for i, l := range []struct {
+		id      int
+		order   string
+		entryID int
+		amount  entity.Amount
+	}{
+		{id: 3, order: "2377225624", entryID: 100, amount: 30_00},
+		{id: 4, order: "12345678903", entryID: 101, amount: 12_50},
+	} {
+		mock.ExpectQuery(insertEntryQuery).
+			WithArgs(entity.TransactionTypeExpire, l.order, l.entryID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(200 + i))
+		mock.ExpectExec(insertPostingQuery).
+			WithArgs(200+i, 10, -l.amount).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(insertPostingQuery).
+			WithArgs(200+i, 4, l.amount).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec(expireQuery).
+			WithArgs(l.id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectUpdateBalance(mock, userID, entity.Amount(-42_50), entity.Amount(0), entity.Amount(0))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(entity.OutboxEventPointsExpired, fmt.Sprintf(`{"sum":42.50,"user_id":%d}`, userID)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(usersQuery).
+		WithArgs(now, limit).
+		WillReturnError(errors.New(""))
+
+	n, err := r.ExpireDue(ctx, now, limit)
+	assert.NoError(t, err, "успешное списание истекших баллов")
+	assert.Equal(t, 2, n, "успешное списание истекших баллов")
+
+	_, err = r.ExpireDue(ctx, now, limit)
+	assert.Error(t, err, "ошибка при поиске истекших баллов")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLot_FindUpcoming(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		userID      = 1
+		errUserID   = 2
+		until       = time.Now().Add(time.Hour)
+		expirations = []entity.Expiration{
+			{Sum: 30_00, ExpiresAt: time.Now()},
+			{Sum: 12_50, ExpiresAt: time.Now().Add(time.Minute)},
+		}
+		query = `
+SELECT remaining, expires_at
+FROM accrual_lots
+WHERE user_id = $1
+  AND remaining > 0
+  AND expires_at <= $2
+ORDER BY expires_at, id
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewLot(db)
+
+	rows := sqlmock.NewRows([]string{"remaining", "expires_at"})
+	for _, e := range expirations {
+		rows.AddRow(e.Sum.String(), e.ExpiresAt)
+	}
+	mock.ExpectQuery(query).WithArgs(userID, until).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs(errUserID, until).WillReturnError(errors.New(""))
+
+	found, err := r.FindUpcoming(ctx, userID, until)
+	assert.NoError(t, err, "успешное получение истекающих баллов")
+	assert.Equal(t, expirations, found, "успешное получение истекающих баллов")
+
+	_, err = r.FindUpcoming(ctx, errUserID, until)
+	assert.Error(t, err, "ошибка при получении истекающих баллов")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
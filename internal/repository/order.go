@@ -3,25 +3,42 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"time"
 )
 
 type Order struct {
-	db *sql.DB
+	db        *sql.DB
+	pointsTTL time.Duration
 }
 
-func NewOrder(db *sql.DB) *Order {
-	return &Order{db: db}
+// NewOrder возвращает репозиторий заказов. Баллы, начисленные за заказ, действуют в течение
+// pointsTTL, при нулевом pointsTTL - бессрочно.
+func NewOrder(db *sql.DB, pointsTTL time.Duration) *Order {
+	return &Order{
+		db:        db,
+		pointsTTL: pointsTTL,
+	}
 }
 
-// Create добавляет новый заказ. Если номер заказа уже был загружен этим пользователем,
-// возвращает ошибку errors.ErrOrderExists. Если номер заказа уже был загружен
+// Create добавляет новый заказ, записывает статус entity.OrderStatusNew в историю его изменения
+// и событие entity.OutboxEventOrderCreated в outbox. Если номер заказа уже был загружен этим
+// пользователем, возвращает ошибку errors.ErrOrderExists. Если номер заказа уже был загружен
 // другим пользователем, возвращает ошибку errors.ErrOrderNotBelongToUser.
 func (r *Order) Create(ctx context.Context, userID int, num string) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW')", userID, num)
+	_, err := r.db.ExecContext(ctx, `
+WITH o AS (INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW') RETURNING id, user_id, num),
+     h AS (INSERT INTO order_status_history (order_id, status) SELECT id, 'NEW' FROM o)
+INSERT
+INTO outbox (type, payload)
+SELECT $3::text, json_build_object('user_id', user_id, 'order', num)::jsonb
+FROM o
+	`, userID, num, entity.OutboxEventOrderCreated)
 	if err != nil && err.(*pgconn.PgError).Code == pgerrcode.UniqueViolation {
 		ownerID := 0
 		if err = r.db.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE num = $1", num).Scan(&ownerID); err != nil {
@@ -37,16 +54,87 @@ func (r *Order) Create(ctx context.Context, userID int, num string) error {
 	return err
 }
 
-// FindAllByUserID возвращает список добавленных заказов пользователя. Данные отсортированы
-// по времени добавления от самых старых к самым новым.
-func (r *Order) FindAllByUserID(ctx context.Context, userID int) (orders []entity.Order, err error) {
-	rows, err := r.db.QueryContext(ctx, `
-SELECT num, status, accrual, uploaded_at
+// CreateBatch добавляет заказы с номерами nums в одной транзакции и возвращает результат
+// загрузки каждого номера в порядке nums. Номера, уже загруженные этим или другим пользователем,
+// а также повторы номеров в nums не добавляются.
+func (r *Order) CreateBatch(ctx context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]entity.OrderUploadResult, 0, len(nums))
+	for _, num := range nums {
+		status, err := r.createInBatch(ctx, tx, userID, num)
+		if err != nil {
+			_ = tx.Rollback()
+
+			return nil, err
+		}
+
+		results = append(results, entity.OrderUploadResult{Number: num, Status: status})
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (r *Order) createInBatch(ctx context.Context, tx *sql.Tx, userID int, num string) (entity.OrderUploadStatus, error) {
+	res, err := tx.ExecContext(ctx, `
+WITH o AS (INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW') ON CONFLICT (num) DO NOTHING RETURNING id, user_id, num),
+     h AS (INSERT INTO order_status_history (order_id, status) SELECT id, 'NEW' FROM o)
+INSERT
+INTO outbox (type, payload)
+SELECT $3::text, json_build_object('user_id', user_id, 'order', num)::jsonb
+FROM o
+	`, userID, num, entity.OutboxEventOrderCreated)
+	if err != nil {
+		return "", err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	if inserted > 0 {
+		return entity.OrderUploadAccepted, nil
+	}
+
+	ownerID := 0
+	if err = tx.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE num = $1", num).Scan(&ownerID); err != nil {
+		return "", err
+	}
+
+	if ownerID != userID {
+		return entity.OrderUploadConflict, nil
+	}
+
+	return entity.OrderUploadAlreadyUploaded, nil
+}
+
+// FindAllByUserID возвращает страницу списка добавленных заказов пользователя, подходящих
+// под условия f. Данные отсортированы по времени добавления и идентификатору заказа, по умолчанию
+// от самых старых к самым новым.
+func (r *Order) FindAllByUserID(ctx context.Context, userID int, f entity.ListFilter) (orders []entity.Order, err error) {
+	cmp, dir := listOrder(f)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+SELECT id, num, status, accrual, uploaded_at
 FROM orders
 WHERE user_id = $1
   AND status IS NOT NULL
-ORDER BY uploaded_at
-	`, userID)
+  AND ($2 = '' OR status::text = ANY (string_to_array($2, ',')))
+  AND ($3::timestamptz IS NULL OR uploaded_at >= $3)
+  AND ($4::timestamptz IS NULL OR uploaded_at < $4)
+  AND ($5::timestamptz IS NULL OR (uploaded_at, id) %[1]s ($5, $6))
+ORDER BY uploaded_at %[2]s, id %[2]s
+LIMIT $7
+	`, cmp, dir), append([]any{userID}, listParams(f)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +145,7 @@ ORDER BY uploaded_at
 
 	for rows.Next() {
 		order := entity.Order{}
-		err = rows.Scan(&order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
+		err = rows.Scan(&order.ID, &order.Number, &order.Status, &order.Accrual, &order.UploadedAt)
 		if err != nil {
 			continue
 		}
@@ -72,29 +160,187 @@ ORDER BY uploaded_at
 	return orders, err
 }
 
-// UpdateStatus обновляет статус заказа. Если статус изменился на entity.OrderStatusProcessed,
-// создает транзакцию с суммой accrual.
-func (r *Order) UpdateStatus(ctx context.Context, num string, status entity.OrderStatus, accrual float64) error {
+// FindByNumber возвращает заказ пользователя с номером num, историю изменения его статуса
+// и записи журнала операций по заказу. Если заказ не найден или загружен другим пользователем,
+// возвращает ошибку errors.ErrOrderNotFound.
+func (r *Order) FindByNumber(ctx context.Context, userID int, num string) (entity.OrderDetail, error) {
+	o := entity.OrderDetail{}
+	err := r.db.QueryRowContext(ctx, `
+SELECT id, num, status, accrual, uploaded_at
+FROM orders
+WHERE num = $1
+  AND user_id = $2
+  AND status IS NOT NULL
+	`, num, userID).Scan(&o.ID, &o.Number, &o.Status, &o.Accrual, &o.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, inerr.ErrOrderNotFound
+	}
+	if err != nil {
+		return o, err
+	}
+
+	if o.History, err = r.findStatusHistory(ctx, o.ID); err != nil {
+		return o, err
+	}
+
+	o.Entries, err = r.findEntries(ctx, userID, num)
+
+	return o, err
+}
+
+func (r *Order) findStatusHistory(ctx context.Context, orderID int) (history []entity.OrderStatusChange, err error) {
+	history = []entity.OrderStatusChange{}
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT status, changed_at FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id",
+		orderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		c := entity.OrderStatusChange{}
+		if err = rows.Scan(&c.Status, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		history = append(history, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, err
+}
+
+// findEntries возвращает записи журнала операций с номером заказа num и изменение баланса
+// пользователя по каждой записи.
+func (r *Order) findEntries(ctx context.Context, userID int, num string) (entries []entity.OrderEntry, err error) {
+	entries = []entity.OrderEntry{}
+	rows, err := r.db.QueryContext(ctx, `
+SELECT e.id, e.type, sum(p.amount), e.created_at
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.order_num = $1
+  AND a.user_id = $2
+  AND a.type IN ('USER', 'HOLD')
+GROUP BY e.id
+ORDER BY e.id
+	`, num, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		e := entity.OrderEntry{}
+		if err = rows.Scan(&e.ID, &e.Type, &e.Sum, &e.ProcessedAt); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, err
+}
+
+// UpdateStatus обновляет статус заказа и добавляет запись в историю его изменения. Если статус
+// изменился на entity.OrderStatusProcessed, записывает в журнал операций начисление суммы accrual.
+// Публикует событие изменения заказа, а при начислении - и событие изменения баланса, добавляет
+// уведомления партнеров о конечном статусе и записывает событие в outbox. Повторное
+// обновление до текущего статуса ничего не изменяет. Если переход в новый статус недопустим,
+// возвращает ошибку errors.ErrInvalidTransition, если заказ не найден - errors.ErrOrderNotFound.
+func (r *Order) UpdateStatus(ctx context.Context, num string, status entity.OrderStatus, accrual entity.Amount) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	userID := 0
-	if err = tx.QueryRowContext(ctx, "UPDATE orders SET status = $1, accrual = $2 WHERE num = $3 RETURNING user_id", status, accrual, num).Scan(&userID); err != nil {
+	var (
+		orderID = 0
+		userID  = 0
+		current sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, "SELECT id, user_id, status FROM orders WHERE num = $1 FOR UPDATE", num).
+		Scan(&orderID, &userID, &current)
+	if err != nil {
 		_ = tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			err = inerr.ErrOrderNotFound
+		}
 
 		return err
 	}
 
-	if status == entity.OrderStatusProcessed {
-		if _, err = tx.ExecContext(ctx, "INSERT INTO transactions (order_num, amount, type, user_id) VALUES ($1, $2, 'IN', $3)", num, accrual, userID); err != nil {
+	if entity.OrderStatus(current.String) == status {
+		return tx.Rollback()
+	}
+
+	if !current.Valid || !entity.OrderStatus(current.String).CanTransitionTo(status) {
+		_ = tx.Rollback()
+
+		return fmt.Errorf("%w: %s -> %s", inerr.ErrInvalidTransition, current.String, status)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE orders SET status = $1, accrual = $2 WHERE num = $3", status, accrual, num); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO order_status_history (order_id, status) VALUES ($1, $2)", orderID, status)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err = notifyOrder(ctx, tx, orderID); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if status == entity.OrderStatusProcessed && accrual > 0 {
+		if err = postTransaction(ctx, tx, userID, num, accrual, entity.TransactionTypeIn, r.pointsTTL); err != nil {
 			_ = tx.Rollback()
 
 			return err
 		}
 	}
 
+	if err = enqueueOrderWebhooks(ctx, tx, userID, num, status, accrual); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	err = writeOutbox(ctx, tx, entity.OutboxEventOrderStatusUpdated, map[string]any{
+		"user_id": userID,
+		"order":   num,
+		"status":  status,
+		"accrual": accrual,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		_ = tx.Rollback()
 
@@ -104,6 +350,86 @@ func (r *Order) UpdateStatus(ctx context.Context, num string, status entity.Orde
 	return nil
 }
 
+// Delete отменяет заказ пользователя, по которому не начислены баллы (статус, отличный от
+// entity.OrderStatusProcessed): удаляет заказ с историей изменения статуса, освобождая номер
+// для повторной загрузки, и записывает событие в outbox. Если заказ не найден или загружен
+// другим пользователем, возвращает ошибку errors.ErrOrderNotFound, если заказ уже обработан -
+// errors.ErrOrderNotCancellable.
+func (r *Order) Delete(ctx context.Context, userID int, num string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var (
+		orderID = 0
+		status  sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, "SELECT id, status FROM orders WHERE num = $1 AND user_id = $2 FOR UPDATE", num, userID).
+		Scan(&orderID, &status)
+	if err == nil && !status.Valid {
+		// Заказ создан при списании баллов и не отображается в списке загруженных заказов.
+		err = sql.ErrNoRows
+	}
+
+	if err != nil {
+		_ = tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			err = inerr.ErrOrderNotFound
+		}
+
+		return err
+	}
+
+	if s := entity.OrderStatus(status.String); s == entity.OrderStatusProcessed {
+		_ = tx.Rollback()
+
+		return fmt.Errorf("%w: %s", inerr.ErrOrderNotCancellable, s)
+	}
+
+	for _, q := range []string{
+		"DELETE FROM order_status_history WHERE order_id = $1",
+		"DELETE FROM orders WHERE id = $1",
+	} {
+		if _, err = tx.ExecContext(ctx, q, orderID); err != nil {
+			_ = tx.Rollback()
+
+			return err
+		}
+	}
+
+	err = writeOutbox(ctx, tx, entity.OutboxEventOrderCancelled, map[string]any{
+		"user_id": userID,
+		"order":   num,
+		"status":  status.String,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	return nil
+}
+
+// FindStatus возвращает статус заказа с номером num. Если заказ не найден (в том числе отменен
+// пользователем), возвращает ошибку errors.ErrOrderNotFound.
+func (r *Order) FindStatus(ctx context.Context, num string) (entity.OrderStatus, error) {
+	var status entity.OrderStatus
+	err := r.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE num = $1 AND status IS NOT NULL", num).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", inerr.ErrOrderNotFound
+	}
+
+	return status, err
+}
+
 // FindUnprocessed возвращает список всех заказов, которые необходимо обработать
 // (статус равен entity.OrderStatusNew или entity.OrderStatusProcessing).
 func (r *Order) FindUnprocessed(ctx context.Context) (orders []entity.Order) {
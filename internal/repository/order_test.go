@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
@@ -14,6 +16,38 @@ import (
 	"time"
 )
 
+// orderEventQuery и balanceEventQuery - запросы публикации событий изменения заказа и баланса,
+// webhookQuery - запрос добавления уведомлений для подписок на событие, outboxQuery - запрос
+// записи события в outbox.
+const (
+	orderEventQuery = `
+SELECT pg_notify($1, json_build_object(
+        'user_id', user_id,
+        'type', 'order',
+        'data', json_build_object('number', num, 'status', status, 'accrual', accrual, 'uploaded_at', uploaded_at)
+    )::text)
+FROM orders
+WHERE id = $2
+`
+	balanceEventQuery = `
+SELECT pg_notify($1, json_build_object(
+        'user_id', user_id,
+        'type', 'balance',
+        'data', json_build_object('current', current, 'held', held, 'withdrawn', withdrawn)
+    )::text)
+FROM balances
+WHERE user_id = $2
+`
+	webhookQuery = `
+INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT id, $1, json_build_object('event', $1::text, 'created_at', now(), 'data', $2::json)::jsonb
+FROM webhooks
+WHERE $1 = ANY (events)
+  AND (all_users OR $3 = ANY (user_ids))
+	`
+	outboxQuery = "INSERT INTO outbox (type, payload) VALUES ($1, $2::jsonb)"
+)
+
 func TestOrder_Create(t *testing.T) {
 	var (
 		ctx              = context.Background()
@@ -22,25 +56,32 @@ func TestOrder_Create(t *testing.T) {
 		order            = "148561163482734"
 		duplicatedOrder  = "267624438264306"
 		anotherUserOrder = "166221614883769"
-		insertQuery      = "INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW')"
-		getUserQuery     = "SELECT user_id FROM orders WHERE num = $1"
+		insertQuery      = `
+WITH o AS (INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW') RETURNING id, user_id, num),
+     h AS (INSERT INTO order_status_history (order_id, status) SELECT id, 'NEW' FROM o)
+INSERT
+INTO outbox (type, payload)
+SELECT $3::text, json_build_object('user_id', user_id, 'order', num)::jsonb
+FROM o
+`
+		getUserQuery = "SELECT user_id FROM orders WHERE num = $1"
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewOrder(db)
+	r := NewOrder(db, testPointsTTL)
 
 	mock.ExpectExec(insertQuery).
-		WithArgs(userID, order).
+		WithArgs(userID, order, entity.OutboxEventOrderCreated).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(insertQuery).
-		WithArgs(userID, duplicatedOrder).
+		WithArgs(userID, duplicatedOrder, entity.OutboxEventOrderCreated).
 		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
 	mock.ExpectQuery(getUserQuery).
 		WithArgs(duplicatedOrder).
 		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
 	mock.ExpectExec(insertQuery).
-		WithArgs(userID, anotherUserOrder).
+		WithArgs(userID, anotherUserOrder, entity.OutboxEventOrderCreated).
 		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
 	mock.ExpectQuery(getUserQuery).
 		WithArgs(anotherUserOrder).
@@ -63,54 +104,142 @@ func TestOrder_Create(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestOrder_CreateBatch(t *testing.T) {
+	var (
+		ctx           = context.Background()
+		userID        = 1
+		anotherUserID = 2
+		nums          = []string{"148561163482734", "267624438264306", "166221614883769"}
+		insertQuery   = `
+WITH o AS (INSERT INTO orders (user_id, num, status) VALUES ($1, $2, 'NEW') ON CONFLICT (num) DO NOTHING RETURNING id, user_id, num),
+     h AS (INSERT INTO order_status_history (order_id, status) SELECT id, 'NEW' FROM o)
+INSERT
+INTO outbox (type, payload)
+SELECT $3::text, json_build_object('user_id', user_id, 'order', num)::jsonb
+FROM o
+`
+		getUserQuery = "SELECT user_id FROM orders WHERE num = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOrder(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertQuery).
+		WithArgs(userID, nums[0], entity.OutboxEventOrderCreated).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertQuery).
+		WithArgs(userID, nums[1], entity.OutboxEventOrderCreated).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(getUserQuery).
+		WithArgs(nums[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+	mock.ExpectExec(insertQuery).
+		WithArgs(userID, nums[2], entity.OutboxEventOrderCreated).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(getUserQuery).
+		WithArgs(nums[2]).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(anotherUserID))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertQuery).
+		WithArgs(userID, nums[0], entity.OutboxEventOrderCreated).
+		WillReturnError(errors.New(""))
+	mock.ExpectRollback()
+
+	res, err := r.CreateBatch(ctx, userID, nums)
+	assert.NoError(t, err, "успешное добавление пакета заказов")
+	assert.Equal(
+		t,
+		[]entity.OrderUploadResult{
+			{Number: nums[0], Status: entity.OrderUploadAccepted},
+			{Number: nums[1], Status: entity.OrderUploadAlreadyUploaded},
+			{Number: nums[2], Status: entity.OrderUploadConflict},
+		},
+		res,
+		"успешное добавление пакета заказов",
+	)
+
+	_, err = r.CreateBatch(ctx, userID, nums)
+	assert.Error(t, err, "ошибка при добавлении пакета заказов")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestOrder_FindAllByUserID(t *testing.T) {
 	var (
 		ctx       = context.Background()
 		userID    = 1
 		errUserID = 2
+		from      = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		after     = entity.Cursor{Time: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), ID: 7}
 		orders    = []entity.Order{
 			{
+				ID:         1,
 				Number:     "148561163482734",
 				Status:     entity.OrderStatusProcessing,
 				Accrual:    0,
 				UploadedAt: time.Now(),
 			},
 			{
+				ID:         2,
 				Number:     "267624438264306",
 				Status:     entity.OrderStatusProcessed,
 				Accrual:    100,
 				UploadedAt: time.Now(),
 			},
 		}
-		query = `
-SELECT num, status, accrual, uploaded_at
+		query = func(cmp, dir string) string {
+			return fmt.Sprintf(`
+SELECT id, num, status, accrual, uploaded_at
 FROM orders
 WHERE user_id = $1
   AND status IS NOT NULL
-ORDER BY uploaded_at
-`
+  AND ($2 = '' OR status::text = ANY (string_to_array($2, ',')))
+  AND ($3::timestamptz IS NULL OR uploaded_at >= $3)
+  AND ($4::timestamptz IS NULL OR uploaded_at < $4)
+  AND ($5::timestamptz IS NULL OR (uploaded_at, id) %[1]s ($5, $6))
+ORDER BY uploaded_at %[2]s, id %[2]s
+LIMIT $7
+`, cmp, dir)
+		}
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewOrder(db)
+	r := NewOrder(db, testPointsTTL)
 
-	rows := sqlmock.NewRows([]string{"num", "status", "accrual", "uploaded_at"})
+	rows := sqlmock.NewRows([]string{"id", "num", "status", "accrual", "uploaded_at"})
 	for _, o := range orders {
-		rows.AddRow(o.Number, o.Status, o.Accrual, o.UploadedAt)
+		rows.AddRow(o.ID, o.Number, o.Status, o.Accrual, o.UploadedAt)
 	}
-	mock.ExpectQuery(query).
-		WithArgs(userID).
+	mock.ExpectQuery(query(">", "ASC")).
+		WithArgs(userID, "", nil, nil, nil, nil, nil).
 		WillReturnRows(rows)
-	mock.ExpectQuery(query).
-		WithArgs(errUserID).
+	mock.ExpectQuery(query("<", "DESC")).
+		WithArgs(userID, "NEW,PROCESSING", from, nil, after.Time, after.ID, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "num", "status", "accrual", "uploaded_at"}))
+	mock.ExpectQuery(query(">", "ASC")).
+		WithArgs(errUserID, "", nil, nil, nil, nil, nil).
 		WillReturnError(errors.New(""))
 
-	foundOrders, err := r.FindAllByUserID(ctx, userID)
+	foundOrders, err := r.FindAllByUserID(ctx, userID, entity.ListFilter{})
 	assert.NoError(t, err, "успешное получение заказов пользователя")
 	assert.Equal(t, orders, foundOrders, "успешное получение заказов пользователя")
 
-	_, err = r.FindAllByUserID(ctx, errUserID)
+	foundOrders, err = r.FindAllByUserID(ctx, userID, entity.ListFilter{
+		Statuses:   []string{"NEW", "PROCESSING"},
+		From:       from,
+		Descending: true,
+		After:      &after,
+		Limit:      10,
+	})
+	assert.NoError(t, err, "получение страницы заказов с фильтрами")
+	assert.Empty(t, foundOrders, "получение страницы заказов с фильтрами")
+
+	_, err = r.FindAllByUserID(ctx, errUserID, entity.ListFilter{})
 	assert.Error(t, err, "ошибка при получении заказов пользователя")
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -133,7 +262,7 @@ func TestOrder_FindUnprocessed(t *testing.T) {
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewOrder(db)
+	r := NewOrder(db, testPointsTTL)
 
 	rows := sqlmock.NewRows([]string{"num", "status"})
 	for _, o := range orders {
@@ -147,6 +276,34 @@ func TestOrder_FindUnprocessed(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestOrder_FindStatus(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		num   = "148561163482734"
+		query = "SELECT status FROM orders WHERE num = $1 AND status IS NOT NULL"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOrder(db, testPointsTTL)
+
+	mock.ExpectQuery(query).
+		WithArgs(num).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(entity.OrderStatusProcessing))
+	mock.ExpectQuery(query).
+		WithArgs(num).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	status, err := r.FindStatus(ctx, num)
+	assert.NoError(t, err, "успешное получение статуса заказа")
+	assert.Equal(t, entity.OrderStatusProcessing, status, "успешное получение статуса заказа")
+
+	_, err = r.FindStatus(ctx, num)
+	assert.ErrorIs(t, err, inerr.ErrOrderNotFound, "заказ не найден")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestOrder_UpdateStatus(t *testing.T) {
 	var (
 		ctx              = context.Background()
@@ -166,41 +323,98 @@ func TestOrder_UpdateStatus(t *testing.T) {
 			Status:  entity.OrderStatusProcessed,
 			Accrual: 100,
 		}
-		updateQuery = "UPDATE orders SET status = $1, accrual = $2 WHERE num = $3 RETURNING user_id"
-		insertQuery = "INSERT INTO transactions (order_num, amount, type, user_id) VALUES ($1, $2, 'IN', $3)"
+		orderID      = 5
+		selectQuery  = "SELECT id, user_id, status FROM orders WHERE num = $1 FOR UPDATE"
+		updateQuery  = "UPDATE orders SET status = $1, accrual = $2 WHERE num = $3"
+		historyQuery = "INSERT INTO order_status_history (order_id, status) VALUES ($1, $2)"
+		statusRows   = func(status any) *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(orderID, userID, status)
+		}
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewOrder(db)
+	r := NewOrder(db, testPointsTTL)
 
 	mock.ExpectBegin()
 	mock.
-		ExpectQuery(updateQuery).
+		ExpectQuery(selectQuery).
+		WithArgs(unprocessedOrder.Number).
+		WillReturnRows(statusRows(entity.OrderStatusNew))
+	mock.
+		ExpectExec(updateQuery).
 		WithArgs(unprocessedOrder.Status, unprocessedOrder.Accrual, unprocessedOrder.Number).
-		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(historyQuery).
+		WithArgs(orderID, unprocessedOrder.Status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(orderEventQuery).
+		WithArgs(EventsChannel, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventOrderStatusUpdated,
+			fmt.Sprintf(`{"accrual":0.00,"order":"%s","status":"PROCESSING","user_id":%d}`, unprocessedOrder.Number, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	mock.ExpectBegin()
 	mock.
-		ExpectQuery(updateQuery).
+		ExpectQuery(selectQuery).
+		WithArgs(processedOrder.Number).
+		WillReturnRows(statusRows(entity.OrderStatusProcessing))
+	mock.
+		ExpectExec(updateQuery).
 		WithArgs(processedOrder.Status, processedOrder.Accrual, processedOrder.Number).
-		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.
-		ExpectExec(insertQuery).
-		WithArgs(processedOrder.Number, processedOrder.Accrual, userID).
+		ExpectExec(historyQuery).
+		WithArgs(orderID, processedOrder.Status).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(orderEventQuery).
+		WithArgs(EventsChannel, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPostTransaction(mock, userID, processedOrder.Number, processedOrder.Accrual, entity.TransactionTypeIn, 0, nil)
+	mock.
+		ExpectExec(webhookQuery).
+		WithArgs(
+			entity.WebhookEventOrderProcessed,
+			fmt.Sprintf(`{"accrual":%s,"order":"%s","user_id":%d}`, processedOrder.Accrual, processedOrder.Number, userID),
+			userID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventOrderStatusUpdated,
+			fmt.Sprintf(`{"accrual":%s,"order":"%s","status":"PROCESSED","user_id":%d}`, processedOrder.Accrual, processedOrder.Number, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
 
 	mock.ExpectBegin()
 	mock.
-		ExpectQuery(updateQuery).
+		ExpectQuery(selectQuery).
+		WithArgs(processedOrderError.Number).
+		WillReturnRows(statusRows(entity.OrderStatusProcessing))
+	mock.
+		ExpectExec(updateQuery).
 		WithArgs(processedOrderError.Status, processedOrderError.Accrual, processedOrderError.Number).
-		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.
-		ExpectExec(insertQuery).
-		WithArgs(processedOrderError.Number, processedOrderError.Accrual, userID).
-		WillReturnError(errors.New(""))
+		ExpectExec(historyQuery).
+		WithArgs(orderID, processedOrderError.Status).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(orderEventQuery).
+		WithArgs(EventsChannel, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPostTransaction(mock, userID, processedOrderError.Number, processedOrderError.Accrual, entity.TransactionTypeIn, 0, errors.New(""))
 	mock.ExpectRollback()
 
 	assert.NoError(
@@ -220,3 +434,223 @@ func TestOrder_UpdateStatus(t *testing.T) {
 	)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestOrder_UpdateStatusTransitions(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		num         = "267624438264306"
+		selectQuery = "SELECT id, user_id, status FROM orders WHERE num = $1 FOR UPDATE"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOrder(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(selectQuery).
+		WithArgs(num).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(5, 1, entity.OrderStatusProcessed))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(selectQuery).
+		WithArgs(num).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(5, 1, entity.OrderStatusProcessed))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(selectQuery).
+		WithArgs(num).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(5, 1, nil))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(selectQuery).
+		WithArgs(num).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	assert.NoError(
+		t,
+		r.UpdateStatus(ctx, num, entity.OrderStatusProcessed, 100),
+		"повторное обновление до текущего статуса",
+	)
+	assert.ErrorIs(
+		t,
+		r.UpdateStatus(ctx, num, entity.OrderStatusProcessing, 0),
+		inerr.ErrInvalidTransition,
+		"обновление окончательного статуса",
+	)
+	assert.ErrorIs(
+		t,
+		r.UpdateStatus(ctx, num, entity.OrderStatusProcessing, 0),
+		inerr.ErrInvalidTransition,
+		"обновление статуса заказа, созданного при списании",
+	)
+	assert.ErrorIs(
+		t,
+		r.UpdateStatus(ctx, num, entity.OrderStatusProcessing, 0),
+		inerr.ErrOrderNotFound,
+		"заказ не найден",
+	)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrder_FindByNumber(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		userID      = 1
+		num         = "2377225624"
+		uploadedAt  = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		processedAt = uploadedAt.Add(time.Minute)
+		orderQuery  = `
+SELECT id, num, status, accrual, uploaded_at
+FROM orders
+WHERE num = $1
+  AND user_id = $2
+  AND status IS NOT NULL
+`
+		historyQuery = "SELECT status, changed_at FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id"
+		entriesQuery = `
+SELECT e.id, e.type, sum(p.amount), e.created_at
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.order_num = $1
+  AND a.user_id = $2
+  AND a.type IN ('USER', 'HOLD')
+GROUP BY e.id
+ORDER BY e.id
+`
+		want = entity.OrderDetail{
+			Order: entity.Order{
+				ID:         5,
+				Number:     num,
+				Status:     entity.OrderStatusProcessed,
+				Accrual:    500_00,
+				UploadedAt: uploadedAt,
+			},
+			History: []entity.OrderStatusChange{
+				{Status: entity.OrderStatusNew, ChangedAt: uploadedAt},
+				{Status: entity.OrderStatusProcessing, ChangedAt: uploadedAt.Add(time.Second)},
+				{Status: entity.OrderStatusProcessed, ChangedAt: processedAt},
+			},
+			Entries: []entity.OrderEntry{
+				{ID: 11, Type: entity.TransactionTypeIn, Sum: 500_00, ProcessedAt: processedAt},
+			},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOrder(db, testPointsTTL)
+
+	mock.ExpectQuery(orderQuery).
+		WithArgs(num, userID).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "num", "status", "accrual", "uploaded_at"}).
+				AddRow(5, num, entity.OrderStatusProcessed, "500.00", uploadedAt),
+		)
+	history := sqlmock.NewRows([]string{"status", "changed_at"})
+	for _, c := range want.History {
+		history.AddRow(c.Status, c.ChangedAt)
+	}
+	mock.ExpectQuery(historyQuery).WithArgs(5).WillReturnRows(history)
+	mock.ExpectQuery(entriesQuery).
+		WithArgs(num, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "sum", "created_at"}).AddRow(11, "IN", "500.00", processedAt))
+	mock.ExpectQuery(orderQuery).
+		WithArgs(num, userID).
+		WillReturnError(sql.ErrNoRows)
+
+	res, err := r.FindByNumber(ctx, userID, num)
+	assert.NoError(t, err, "заказ найден")
+	assert.Equal(t, want, res, "заказ найден")
+
+	_, err = r.FindByNumber(ctx, userID, num)
+	assert.ErrorIs(t, err, inerr.ErrOrderNotFound, "заказ не найден")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrder_Delete(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		userID      = 1
+		num         = "2377225624"
+		orderID     = 5
+		selectQuery = "SELECT id, status FROM orders WHERE num = $1 AND user_id = $2 FOR UPDATE"
+		statusRows  = func(status any) *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"id", "status"}).AddRow(orderID, status)
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOrder(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).
+		WithArgs(num, userID).
+		WillReturnRows(statusRows(entity.OrderStatusProcessing))
+	mock.ExpectExec("DELETE FROM order_status_history WHERE order_id = $1").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM orders WHERE id = $1").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventOrderCancelled,
+			fmt.Sprintf(`{"order":"%s","status":"PROCESSING","user_id":%d}`, num, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).
+		WithArgs(num, userID).
+		WillReturnRows(statusRows(entity.OrderStatusInvalid))
+	mock.ExpectExec("DELETE FROM order_status_history WHERE order_id = $1").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM orders WHERE id = $1").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventOrderCancelled,
+			fmt.Sprintf(`{"order":"%s","status":"INVALID","user_id":%d}`, num, userID),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).
+		WithArgs(num, userID).
+		WillReturnRows(statusRows(entity.OrderStatusProcessed))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).
+		WithArgs(num, userID).
+		WillReturnRows(statusRows(nil))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).
+		WithArgs(num, userID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	assert.NoError(t, r.Delete(ctx, userID, num), "отмена необработанного заказа")
+	assert.NoError(t, r.Delete(ctx, userID, num), "отмена заказа с неверным номером")
+	assert.ErrorIs(t, r.Delete(ctx, userID, num), inerr.ErrOrderNotCancellable, "отмена обработанного заказа")
+	assert.ErrorIs(t, r.Delete(ctx, userID, num), inerr.ErrOrderNotFound, "отмена заказа, созданного при списании")
+	assert.ErrorIs(t, r.Delete(ctx, userID, num), inerr.ErrOrderNotFound, "заказ не найден")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
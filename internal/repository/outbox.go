@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"time"
+)
+
+// Outbox предоставляет доступ к доменным событиям, которые записываются в одной транзакции
+// с изменением состояния и ожидают публикации. Опубликованные события хранятся в течение
+// retention.
+type Outbox struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+func NewOutbox(db *sql.DB, retention time.Duration) *Outbox {
+	return &Outbox{
+		db:        db,
+		retention: retention,
+	}
+}
+
+// FindUnpublished возвращает не более limit неопубликованных событий в порядке их записи.
+func (r *Outbox) FindUnpublished(ctx context.Context, limit int) (events []entity.OutboxEvent, err error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, type, payload, created_at FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var (
+			e       = entity.OutboxEvent{}
+			payload = ""
+		)
+		if err = rows.Scan(&e.ID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, err
+}
+
+// MarkPublished отмечает события с идентификаторами ids как опубликованные.
+func (r *Outbox) MarkPublished(ctx context.Context, ids []int) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE outbox SET published_at = now() WHERE id = ANY (string_to_array($1, ',')::integer[])",
+		joinIDs(ids),
+	)
+
+	return err
+}
+
+// DeletePublished удаляет события, опубликованные раньше срока хранения. Возвращает количество
+// удаленных событий.
+func (r *Outbox) DeletePublished(ctx context.Context) (int, error) {
+	res, err := r.db.ExecContext(
+		ctx,
+		"DELETE FROM outbox WHERE published_at < now() - make_interval(secs => $1)",
+		r.retention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
+
+// writeOutbox записывает в outbox событие t с данными data в транзакции tx.
+func writeOutbox(ctx context.Context, tx *sql.Tx, t entity.OutboxEventType, data map[string]any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (type, payload) VALUES ($1, $2::jsonb)", t, string(b))
+
+	return err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOutbox_FindUnpublished(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		query     = "SELECT id, type, payload, created_at FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1"
+		want      = []entity.OutboxEvent{
+			{
+				ID:        1,
+				Type:      entity.OutboxEventOrderCreated,
+				Payload:   json.RawMessage(`{"order": "2377225624", "user_id": 1}`),
+				CreatedAt: createdAt,
+			},
+			{
+				ID:        2,
+				Type:      entity.OutboxEventOrderStatusUpdated,
+				Payload:   json.RawMessage(`{"order": "2377225624", "status": "PROCESSING", "accrual": 0.00, "user_id": 1}`),
+				CreatedAt: createdAt.Add(time.Second),
+			},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOutbox(db, time.Hour)
+
+	rows := sqlmock.NewRows([]string{"id", "type", "payload", "created_at"})
+	for _, e := range want {
+		rows.AddRow(e.ID, e.Type, string(e.Payload), e.CreatedAt)
+	}
+	mock.ExpectQuery(query).WithArgs(100).WillReturnRows(rows)
+
+	res, err := r.FindUnpublished(ctx, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_MarkPublished(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		query = "UPDATE outbox SET published_at = now() WHERE id = ANY (string_to_array($1, ',')::integer[])"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOutbox(db, time.Hour)
+
+	mock.ExpectExec(query).WithArgs("1,2,5").WillReturnResult(sqlmock.NewResult(0, 3))
+
+	assert.NoError(t, r.MarkPublished(ctx, []int{1, 2, 5}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_DeletePublished(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewOutbox(db, time.Hour)
+
+	mock.ExpectExec("DELETE FROM outbox WHERE published_at < now() - make_interval(secs => $1)").
+		WithArgs(time.Hour.Seconds()).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := r.DeletePublished(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"strings"
+)
+
+// listParams возвращает значения параметров запроса страницы списка в порядке: статусы через
+// запятую, начало и конец периода, время и идентификатор курсора, количество элементов.
+// Незаданные условия передаются как NULL.
+func listParams(f entity.ListFilter) []any {
+	params := []any{strings.Join(f.Statuses, ","), nil, nil, nil, nil, nil}
+	if !f.From.IsZero() {
+		params[1] = f.From
+	}
+	if !f.To.IsZero() {
+		params[2] = f.To
+	}
+	if f.After != nil {
+		params[3], params[4] = f.After.Time, f.After.ID
+	}
+	if f.Limit > 0 {
+		params[5] = f.Limit
+	}
+
+	return params
+}
+
+// listOrder возвращает оператор сравнения ключа элемента с курсором и направление сортировки.
+func listOrder(f entity.ListFilter) (string, string) {
+	if f.Descending {
+		return "<", "DESC"
+	}
+
+	return ">", "ASC"
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+)
+
+// Partner предоставляет доступ к партнерам, которые выполняют операции от имени своих клиентов.
+type Partner struct {
+	db *sql.DB
+}
+
+func NewPartner(db *sql.DB) *Partner {
+	return &Partner{db: db}
+}
+
+// Create добавляет партнера и возвращает его с идентификатором и временем создания.
+func (r *Partner) Create(ctx context.Context, p entity.Partner) (entity.Partner, error) {
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO partners (name, key_hash, user_ids)
+VALUES ($1, $2, string_to_array($3, ',')::integer[])
+RETURNING id, created_at
+	`, p.Name, p.KeyHash, joinIDs(p.UserIDs)).Scan(&p.ID, &p.CreatedAt)
+
+	return p, err
+}
+
+// FindAll возвращает всех партнеров без хэшей ключей. Данные отсортированы по времени создания.
+func (r *Partner) FindAll(ctx context.Context) (partners []entity.Partner, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, name, array_to_string(user_ids, ','), created_at
+FROM partners
+ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var (
+			p       = entity.Partner{}
+			userIDs = ""
+		)
+		if err = rows.Scan(&p.ID, &p.Name, &userIDs, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if p.UserIDs, err = splitIDs(userIDs); err != nil {
+			return nil, err
+		}
+		partners = append(partners, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return partners, err
+}
+
+// FindScope возвращает клиентов партнера с хэшем ключа keyHash. Если партнер не найден,
+// возвращает ошибку errors.ErrPartnerNotFound.
+func (r *Partner) FindScope(ctx context.Context, keyHash string) (entity.PartnerScope, error) {
+	var (
+		s       = entity.PartnerScope{}
+		userIDs = ""
+	)
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT array_to_string(user_ids, ',') FROM partners WHERE key_hash = $1",
+		keyHash,
+	).Scan(&userIDs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, inerr.ErrPartnerNotFound
+	}
+	if err != nil {
+		return s, err
+	}
+
+	s.UserIDs, err = splitIDs(userIDs)
+
+	return s, err
+}
+
+// Delete удаляет партнера. Если партнер не найден, возвращает ошибку errors.ErrPartnerNotFound.
+func (r *Partner) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM partners WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = inerr.ErrPartnerNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPartner_Create(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		p         = entity.Partner{
+			Name:    "shop",
+			UserIDs: []int{1, 2},
+			Key:     "key",
+			KeyHash: "hash",
+		}
+		query = `
+INSERT INTO partners (name, key_hash, user_ids)
+VALUES ($1, $2, string_to_array($3, ',')::integer[])
+RETURNING id, created_at
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewPartner(db)
+
+	mock.ExpectQuery(query).
+		WithArgs(p.Name, p.KeyHash, "1,2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+
+	res, err := r.Create(ctx, p)
+	assert.NoError(t, err)
+	p.ID = 3
+	p.CreatedAt = createdAt
+	assert.Equal(t, p, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartner_FindAll(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		query     = `
+SELECT id, name, array_to_string(user_ids, ','), created_at
+FROM partners
+ORDER BY id
+`
+		want = []entity.Partner{
+			{ID: 1, Name: "shop", UserIDs: []int{1, 2}, CreatedAt: createdAt},
+			{ID: 2, Name: "cafe", UserIDs: []int{3}, CreatedAt: createdAt},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewPartner(db)
+
+	mock.ExpectQuery(query).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "user_ids", "created_at"}).
+				AddRow(1, "shop", "1,2", createdAt).
+				AddRow(2, "cafe", "3", createdAt),
+		)
+
+	res, err := r.FindAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartner_FindScope(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		query = "SELECT array_to_string(user_ids, ',') FROM partners WHERE key_hash = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewPartner(db)
+
+	mock.ExpectQuery(query).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_ids"}).AddRow("1,2"))
+	mock.ExpectQuery(query).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"user_ids"}))
+
+	s, err := r.FindScope(ctx, "hash")
+	assert.NoError(t, err, "успешное получение клиентов партнера")
+	assert.Equal(t, entity.PartnerScope{UserIDs: []int{1, 2}}, s, "успешное получение клиентов партнера")
+
+	_, err = r.FindScope(ctx, "unknown")
+	assert.ErrorIs(t, err, inerr.ErrPartnerNotFound, "партнер не найден")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartner_Delete(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		query = "DELETE FROM partners WHERE id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewPartner(db)
+
+	mock.ExpectExec(query).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, r.Delete(ctx, 1), "партнер удален")
+	assert.ErrorIs(t, r.Delete(ctx, 2), inerr.ErrPartnerNotFound, "партнер не найден")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
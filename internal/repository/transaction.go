@@ -3,34 +3,49 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"time"
 )
 
 type Transaction struct {
-	db *sql.DB
+	db        *sql.DB
+	pointsTTL time.Duration
 }
 
-func NewTransaction(db *sql.DB) *Transaction {
-	return &Transaction{db: db}
+// NewTransaction возвращает репозиторий операций с баллами. Зачисленные баллы действуют
+// в течение pointsTTL, при нулевом pointsTTL - бессрочно.
+func NewTransaction(db *sql.DB, pointsTTL time.Duration) *Transaction {
+	return &Transaction{
+		db:        db,
+		pointsTTL: pointsTTL,
+	}
 }
 
-// GetBalance возвращает сумму доступных и списанных баллов пользователя.
-func (r *Transaction) GetBalance(ctx context.Context, userID int) (float64, float64, error) {
-	var accrued, withdrawn float64
-	err := r.db.QueryRowContext(ctx, `
-SELECT (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = $1 AND type = 'IN')  accrued,
-       (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = $1 AND type = 'OUT') withdrawn
-	`, userID).Scan(&accrued, &withdrawn)
+// GetBalance возвращает сумму доступных, зарезервированных и списанных баллов пользователя.
+func (r *Transaction) GetBalance(ctx context.Context, userID int) (entity.Balance, error) {
+	b := entity.Balance{}
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT current, held, withdrawn FROM balances WHERE user_id = $1",
+		userID,
+	).Scan(&b.Current, &b.Held, &b.Withdrawn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.Balance{}, nil
+	}
 
-	return accrued - withdrawn, withdrawn, err
+	return b, err
 }
 
-// Create создает запись о списании или начислении баллов для пользователя. При попытке списать
-// недоступную сумму возвращает ошибку errors.ErrInsufficientFunds.
-func (r *Transaction) Create(ctx context.Context, userID int, order string, sum float64, t entity.TransactionType) error {
+// Create создает заказ и записывает в журнал операций списание или начисление баллов
+// для пользователя. Списание сопровождается уведомлениями партнеров и событием в outbox.
+// При попытке списать недоступную сумму возвращает ошибку errors.ErrInsufficientFunds,
+// если заказ с таким номером уже существует - errors.ErrOrderExists.
+func (r *Transaction) Create(ctx context.Context, userID int, order string, sum entity.Amount, t entity.TransactionType) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
@@ -38,27 +53,35 @@ func (r *Transaction) Create(ctx context.Context, userID int, order string, sum
 
 	if _, err = tx.ExecContext(ctx, "INSERT INTO orders (user_id, num) VALUES ($1, $2)", userID, order); err != nil {
 		_ = tx.Rollback()
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			err = inerr.ErrOrderExists
+		}
 
 		return err
 	}
 
-	_, err = tx.ExecContext(
-		ctx,
-		"INSERT INTO transactions (user_id, order_num, amount, type) VALUES ($1, $2, $3, $4)",
-		userID,
-		order,
-		sum,
-		t,
-	)
-	if err != nil {
+	if err = postTransaction(ctx, tx, userID, order, sum, t, r.pointsTTL); err != nil {
 		_ = tx.Rollback()
-		if err.(*pgconn.PgError).Code == pgerrcode.CheckViolation {
-			err = inerr.ErrInsufficientFunds
-		}
 
 		return err
 	}
 
+	if t == entity.TransactionTypeOut {
+		data := map[string]any{"user_id": userID, "order": order, "sum": sum}
+		if err = enqueueWebhooks(ctx, tx, userID, entity.WebhookEventPointsWithdrawn, data); err != nil {
+			_ = tx.Rollback()
+
+			return err
+		}
+
+		if err = writeOutbox(ctx, tx, entity.OutboxEventPointsWithdrawn, data); err != nil {
+			_ = tx.Rollback()
+
+			return err
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		_ = tx.Rollback()
 
@@ -68,16 +91,46 @@ func (r *Transaction) Create(ctx context.Context, userID int, order string, sum
 	return nil
 }
 
-// FindAllByUserID возвращает список транзакций пользователя типа t. Данные отсортированы
-// по времени транзакции от самых старых к самым новым.
-func (r *Transaction) FindAllByUserID(ctx context.Context, userID int, t entity.TransactionType) (txs []entity.Transaction, err error) {
-	rows, err := r.db.QueryContext(ctx, `
-SELECT order_num, amount, processed_at
-FROM transactions
-WHERE user_id = $1
-  AND type = $2
-ORDER BY processed_at
-	`, userID, t)
+// FindAllByUserID возвращает страницу списка транзакций пользователя типа t с суммами возвратов,
+// подходящих под условия f. Статусы в f - состояния возврата (entity.RefundStatus). Данные
+// отсортированы по времени транзакции и идентификатору записи журнала, по умолчанию от самых
+// старых к самым новым.
+func (r *Transaction) FindAllByUserID(
+	ctx context.Context,
+	userID int,
+	t entity.TransactionType,
+	f entity.ListFilter,
+) (txs []entity.Transaction, err error) {
+	cmp, dir := listOrder(f)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+SELECT id, order_num, amount, created_at, refunded
+FROM (SELECT e.id,
+             e.order_num,
+             abs(p.amount) amount,
+             e.created_at,
+             coalesce((SELECT sum(rp.amount)
+                       FROM journal_entries re
+                                JOIN postings rp ON rp.entry_id = re.id
+                                JOIN ledger_accounts ra ON ra.id = rp.account_id
+                       WHERE re.reference_id = e.id
+                         AND re.type = 'REFUND'
+                         AND ra.type = 'USER'), 0) refunded
+      FROM journal_entries e
+               JOIN postings p ON p.entry_id = e.id
+               JOIN ledger_accounts a ON a.id = p.account_id
+      WHERE a.type IN ('USER', 'HOLD')
+        AND a.user_id = $1
+        AND e.type = $2) w
+WHERE ($3 = '' OR CASE
+                      WHEN refunded = 0 THEN 'NOT_REFUNDED'
+                      WHEN refunded < amount THEN 'PARTIALLY_REFUNDED'
+                      ELSE 'REFUNDED' END = ANY (string_to_array($3, ',')))
+  AND ($4::timestamptz IS NULL OR created_at >= $4)
+  AND ($5::timestamptz IS NULL OR created_at < $5)
+  AND ($6::timestamptz IS NULL OR (created_at, id) %[1]s ($6, $7))
+ORDER BY created_at %[2]s, id %[2]s
+LIMIT $8
+	`, cmp, dir), append([]any{userID, t}, listParams(f)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +141,12 @@ ORDER BY processed_at
 
 	for rows.Next() {
 		tx := entity.Transaction{}
-		err = rows.Scan(&tx.Order, &tx.Sum, &tx.ProcessedAt)
+		err = rows.Scan(&tx.ID, &tx.Order, &tx.Sum, &tx.ProcessedAt, &tx.Refunded)
 		if err != nil {
 			continue
 		}
 
+		tx.RefundStatus = entity.NewRefundStatus(tx.Sum, tx.Refunded)
 		txs = append(txs, tx)
 	}
 
@@ -102,3 +156,220 @@ ORDER BY processed_at
 
 	return txs, err
 }
+
+// FindStatement возвращает операции со счётом пользователя, подходящие под условия f, с доступным
+// балансом после каждой операции. Баланс рассчитывается по всей истории счёта, поэтому не зависит
+// от периода и страницы. Данные отсортированы по идентификатору записи журнала.
+func (r *Transaction) FindStatement(ctx context.Context, userID int, f entity.StatementFilter) (entries []entity.StatementEntry, err error) {
+	var from, to, after any
+	if !f.From.IsZero() {
+		from = f.From
+	}
+	if !f.To.IsZero() {
+		to = f.To
+	}
+	if f.After > 0 {
+		after = f.After
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, type, order_num, amount, balance, created_at
+FROM (SELECT e.id,
+             e.type,
+             coalesce(e.order_num, '') order_num,
+             p.amount,
+             sum(p.amount) OVER (ORDER BY e.id) balance,
+             e.created_at
+      FROM journal_entries e
+               JOIN postings p ON p.entry_id = e.id
+               JOIN ledger_accounts a ON a.id = p.account_id
+      WHERE a.type = 'USER'
+        AND a.user_id = $1) s
+WHERE ($2::timestamptz IS NULL OR created_at >= $2)
+  AND ($3::timestamptz IS NULL OR created_at < $3)
+  AND ($4::integer IS NULL OR id > $4)
+ORDER BY id
+LIMIT $5
+	`, userID, from, to, after, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		e := entity.StatementEntry{}
+		err = rows.Scan(&e.ID, &e.Type, &e.Order, &e.Sum, &e.Balance, &e.ProcessedAt)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, err
+}
+
+// GetBalanceAt возвращает доступный баланс пользователя по операциям, выполненным до момента t.
+func (r *Transaction) GetBalanceAt(ctx context.Context, userID int, t time.Time) (entity.Amount, error) {
+	var balance entity.Amount
+	err := r.db.QueryRowContext(ctx, `
+SELECT coalesce(sum(p.amount), 0)
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE a.type = 'USER'
+  AND a.user_id = $1
+  AND e.created_at < $2
+	`, userID, t).Scan(&balance)
+
+	return balance, err
+}
+
+// Refund возвращает пользователю sum баллов, списанных в счёт оплаты заказа order, и возвращает
+// списание с учетом возврата. Если sum равна nil, возвращается вся оставшаяся сумма списания.
+// Возврат записывается в журнал операций записью, связанной с записью списания, баллы
+// возвращаются в партии, из которых были списаны, с их сроком действия. Если списание
+// не найдено или выполнено пользователем, не входящим в scope, возвращает ошибку
+// errors.ErrWithdrawalNotFound, если сумма возвратов превысит сумму списания - errors.ErrRefundExceedsSum.
+func (r *Transaction) Refund(
+	ctx context.Context,
+	scope entity.PartnerScope,
+	order string,
+	sum *entity.Amount,
+) (entity.Transaction, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Transaction{}, err
+	}
+
+	res, err := r.refund(ctx, tx, scope, order, sum)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return entity.Transaction{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return entity.Transaction{}, err
+	}
+
+	return res, nil
+}
+
+func (r *Transaction) refund(
+	ctx context.Context,
+	tx *sql.Tx,
+	scope entity.PartnerScope,
+	order string,
+	sum *entity.Amount,
+) (entity.Transaction, error) {
+	var (
+		res     = entity.Transaction{Order: order}
+		entryID = 0
+		spendID = 0
+		userID  = 0
+	)
+	// Запись списания блокируется, чтобы параллельные возвраты по одному заказу
+	// проверялись последовательно. Баллы списания резерва были взяты из партий при
+	// резервировании, поэтому возвращаются в партии записи резерва.
+	err := tx.QueryRowContext(ctx, `
+SELECT e.id, coalesce(e.reference_id, e.id), a.user_id, -p.amount, e.created_at
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.order_num = $1
+  AND e.type = 'OUT'
+  AND a.type IN ('USER', 'HOLD')
+FOR UPDATE OF e
+	`, order).Scan(&entryID, &spendID, &userID, &res.Sum, &res.ProcessedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, inerr.ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return res, err
+	}
+
+	// Списания пользователей, которые не являются клиентами партнера, для него не существуют.
+	if !scope.Contains(userID) {
+		return res, inerr.ErrWithdrawalNotFound
+	}
+
+	err = tx.QueryRowContext(ctx, `
+SELECT coalesce(sum(p.amount), 0)
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.reference_id = $1
+  AND e.type = 'REFUND'
+  AND a.type = 'USER'
+	`, entryID).Scan(&res.Refunded)
+	if err != nil {
+		return res, err
+	}
+
+	amount := res.Sum - res.Refunded
+	if sum != nil {
+		amount = *sum
+	}
+	if amount <= 0 || res.Refunded+amount > res.Sum {
+		return res, inerr.ErrRefundExceedsSum
+	}
+
+	user, err := userAccount(ctx, tx, userID, entity.AccountTypeUser)
+	if err != nil {
+		return res, err
+	}
+
+	if _, err = lockBalance(ctx, tx, userID); err != nil {
+		return res, err
+	}
+
+	system, err := systemAccount(ctx, tx, entity.AccountTypeRedemptionSink)
+	if err != nil {
+		return res, err
+	}
+
+	refundID, err := postEntry(
+		ctx,
+		tx,
+		entity.TransactionTypeRefund,
+		order,
+		&entryID,
+		posting{account: user, amount: amount},
+		posting{account: system, amount: -amount},
+	)
+	if err != nil {
+		return res, err
+	}
+
+	if err = restoreLots(ctx, tx, userID, spendID, refundID, amount, r.pointsTTL); err != nil {
+		return res, err
+	}
+
+	if err = updateBalance(ctx, tx, userID, amount, 0, -amount); err != nil {
+		return res, err
+	}
+
+	err = writeOutbox(ctx, tx, entity.OutboxEventPointsRefunded, map[string]any{
+		"user_id": userID,
+		"order":   order,
+		"sum":     amount,
+	})
+	if err != nil {
+		return res, err
+	}
+
+	res.Refunded += amount
+	res.RefundStatus = entity.NewRefundStatus(res.Sum, res.Refunded)
+
+	return res, nil
+}
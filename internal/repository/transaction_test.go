@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
 	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
@@ -19,23 +21,35 @@ func TestTransaction_Create(t *testing.T) {
 		ctx              = context.Background()
 		userID           = 1
 		order            = "148561163482734"
-		amount           = 100.0
-		wrongAmount      = 100.0
+		amount           = entity.Amount(100_00)
+		wrongAmount      = entity.Amount(100_00)
 		tt               = entity.TransactionTypeOut
 		insertOrderQuery = "INSERT INTO orders (user_id, num) VALUES ($1, $2)"
-		insertTxQuery    = "INSERT INTO transactions (user_id, order_num, amount, type) VALUES ($1, $2, $3, $4)"
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewTransaction(db)
+	r := NewTransaction(db, testPointsTTL)
 
 	mock.ExpectBegin()
 	mock.ExpectExec(insertOrderQuery).
 		WithArgs(userID, order).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(insertTxQuery).
-		WithArgs(userID, order, amount, tt).
+	expectPostTransaction(mock, userID, order, amount, tt, amount, nil)
+	mock.
+		ExpectExec(webhookQuery).
+		WithArgs(
+			entity.WebhookEventPointsWithdrawn,
+			fmt.Sprintf(`{"order":"%s","sum":%s,"user_id":%d}`, order, amount, userID),
+			userID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventPointsWithdrawn,
+			fmt.Sprintf(`{"order":"%s","sum":%s,"user_id":%d}`, order, amount, userID),
+		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -43,9 +57,13 @@ func TestTransaction_Create(t *testing.T) {
 	mock.ExpectExec(insertOrderQuery).
 		WithArgs(userID, order).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(insertTxQuery).
-		WithArgs(userID, order, wrongAmount, tt).
-		WillReturnError(&pgconn.PgError{Code: pgerrcode.CheckViolation})
+	expectPostTransaction(mock, userID, order, wrongAmount, tt, wrongAmount-1, nil)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertOrderQuery).
+		WithArgs(userID, order).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.UniqueViolation})
 	mock.ExpectRollback()
 
 	assert.NoError(
@@ -59,6 +77,12 @@ func TestTransaction_Create(t *testing.T) {
 		inerr.ErrInsufficientFunds,
 		"ошибка при создании транзакции",
 	)
+	assert.ErrorIs(
+		t,
+		r.Create(ctx, userID, order, amount, tt),
+		inerr.ErrOrderExists,
+		"заказ с таким номером уже существует",
+	)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -69,83 +93,366 @@ func TestTransaction_FindAllByUserID(t *testing.T) {
 		userID       = 1
 		errUserID    = 2
 		tt           = entity.TransactionTypeOut
+		to           = time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+		after        = entity.Cursor{Time: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), ID: 7}
 		transactions = []entity.Transaction{
 			{
+				ID:          1,
 				Order:       "148561163482734",
 				Sum:         0,
 				ProcessedAt: time.Now(),
 			},
 			{
-				Order:       "267624438264306",
-				Sum:         100,
-				ProcessedAt: time.Now(),
+				ID:           2,
+				Order:        "267624438264306",
+				Sum:          100,
+				ProcessedAt:  time.Now(),
+				Refunded:     40,
+				RefundStatus: entity.RefundStatusPartial,
 			},
 		}
-		query = `
-SELECT order_num, amount, processed_at
-FROM transactions
-WHERE user_id = $1
-  AND type = $2
-ORDER BY processed_at
-`
+		query = func(cmp, dir string) string {
+			return fmt.Sprintf(`
+SELECT id, order_num, amount, created_at, refunded
+FROM (SELECT e.id,
+             e.order_num,
+             abs(p.amount) amount,
+             e.created_at,
+             coalesce((SELECT sum(rp.amount)
+                       FROM journal_entries re
+                                JOIN postings rp ON rp.entry_id = re.id
+                                JOIN ledger_accounts ra ON ra.id = rp.account_id
+                       WHERE re.reference_id = e.id
+                         AND re.type = 'REFUND'
+                         AND ra.type = 'USER'), 0) refunded
+      FROM journal_entries e
+               JOIN postings p ON p.entry_id = e.id
+               JOIN ledger_accounts a ON a.id = p.account_id
+      WHERE a.type IN ('USER', 'HOLD')
+        AND a.user_id = $1
+        AND e.type = $2) w
+WHERE ($3 = '' OR CASE
+                      WHEN refunded = 0 THEN 'NOT_REFUNDED'
+                      WHEN refunded < amount THEN 'PARTIALLY_REFUNDED'
+                      ELSE 'REFUNDED' END = ANY (string_to_array($3, ',')))
+  AND ($4::timestamptz IS NULL OR created_at >= $4)
+  AND ($5::timestamptz IS NULL OR created_at < $5)
+  AND ($6::timestamptz IS NULL OR (created_at, id) %[1]s ($6, $7))
+ORDER BY created_at %[2]s, id %[2]s
+LIMIT $8
+`, cmp, dir)
+		}
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewTransaction(db)
+	r := NewTransaction(db, testPointsTTL)
 
-	rows := sqlmock.NewRows([]string{"order_num", "amount", "processed_at"})
+	rows := sqlmock.NewRows([]string{"id", "order_num", "amount", "processed_at", "refunded"})
 	for _, tx := range transactions {
-		rows.AddRow(tx.Order, tx.Sum, tx.ProcessedAt)
+		rows.AddRow(tx.ID, tx.Order, tx.Sum.String(), tx.ProcessedAt, tx.Refunded.String())
 	}
-	mock.ExpectQuery(query).
-		WithArgs(userID, tt).
+	mock.ExpectQuery(query(">", "ASC")).
+		WithArgs(userID, tt, "", nil, nil, nil, nil, nil).
 		WillReturnRows(rows)
-	mock.ExpectQuery(query).
-		WithArgs(errUserID, tt).
+	mock.ExpectQuery(query(">", "ASC")).
+		WithArgs(userID, tt, "REFUNDED", nil, to, after.Time, after.ID, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_num", "amount", "processed_at", "refunded"}))
+	mock.ExpectQuery(query("<", "DESC")).
+		WithArgs(errUserID, tt, "", nil, nil, nil, nil, nil).
 		WillReturnError(errors.New(""))
 
-	foundTransactions, err := r.FindAllByUserID(ctx, userID, tt)
+	foundTransactions, err := r.FindAllByUserID(ctx, userID, tt, entity.ListFilter{})
 	assert.NoError(t, err, "успешное получение списаний пользователя")
 	assert.Equal(t, transactions, foundTransactions, "успешное получение списаний пользователя")
 
-	_, err = r.FindAllByUserID(ctx, errUserID, tt)
+	foundTransactions, err = r.FindAllByUserID(ctx, userID, tt, entity.ListFilter{
+		Statuses: []string{"REFUNDED"},
+		To:       to,
+		After:    &after,
+		Limit:    5,
+	})
+	assert.NoError(t, err, "получение страницы списаний с фильтрами")
+	assert.Empty(t, foundTransactions, "получение страницы списаний с фильтрами")
+
+	_, err = r.FindAllByUserID(ctx, errUserID, tt, entity.ListFilter{Descending: true})
 	assert.Error(t, err, "ошибка при получении списаний пользователя")
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestTransaction_FindStatement(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		userID  = 1
+		from    = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		to      = time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+		entries = []entity.StatementEntry{
+			{ID: 11, Type: entity.TransactionTypeIn, Order: "2377225624", Sum: 100_00, Balance: 150_00, ProcessedAt: from},
+			{ID: 12, Type: entity.TransactionTypeTransfer, Sum: -30_00, Balance: 120_00, ProcessedAt: from},
+		}
+		query = `
+SELECT id, type, order_num, amount, balance, created_at
+FROM (SELECT e.id,
+             e.type,
+             coalesce(e.order_num, '') order_num,
+             p.amount,
+             sum(p.amount) OVER (ORDER BY e.id) balance,
+             e.created_at
+      FROM journal_entries e
+               JOIN postings p ON p.entry_id = e.id
+               JOIN ledger_accounts a ON a.id = p.account_id
+      WHERE a.type = 'USER'
+        AND a.user_id = $1) s
+WHERE ($2::timestamptz IS NULL OR created_at >= $2)
+  AND ($3::timestamptz IS NULL OR created_at < $3)
+  AND ($4::integer IS NULL OR id > $4)
+ORDER BY id
+LIMIT $5
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransaction(db, testPointsTTL)
+
+	rows := sqlmock.NewRows([]string{"id", "type", "order_num", "amount", "balance", "created_at"})
+	for _, e := range entries {
+		rows.AddRow(e.ID, e.Type, e.Order, e.Sum.String(), e.Balance.String(), e.ProcessedAt)
+	}
+	mock.ExpectQuery(query).
+		WithArgs(userID, from, to, 10, 50).
+		WillReturnRows(rows)
+	mock.ExpectQuery(query).
+		WithArgs(userID, nil, nil, nil, 50).
+		WillReturnError(errors.New(""))
+
+	res, err := r.FindStatement(ctx, userID, entity.StatementFilter{From: from, To: to, After: 10, Limit: 50})
+	assert.NoError(t, err, "успешное получение выписки")
+	assert.Equal(t, entries, res, "успешное получение выписки")
+
+	_, err = r.FindStatement(ctx, userID, entity.StatementFilter{Limit: 50})
+	assert.Error(t, err, "ошибка при получении выписки без фильтров")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransaction_GetBalanceAt(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		userID = 1
+		at     = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		query  = `
+SELECT coalesce(sum(p.amount), 0)
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE a.type = 'USER'
+  AND a.user_id = $1
+  AND e.created_at < $2
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransaction(db, testPointsTTL)
+
+	mock.ExpectQuery(query).
+		WithArgs(userID, at).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("120.50"))
+
+	balance, err := r.GetBalanceAt(ctx, userID, at)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.Amount(120_50), balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 func TestTransaction_GetBalance(t *testing.T) {
 	var (
 		ctx       = context.Background()
 		userID    = 1
-		errUserID = 2
-		accrued   = 100.0
-		withdrawn = 20.0
-		query     = `
-SELECT (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = $1 AND type = 'IN')  accrued,
-       (SELECT coalesce(sum(amount), 0) FROM transactions WHERE user_id = $1 AND type = 'OUT') withdrawn
-`
+		newUserID = 2
+		errUserID = 3
+		balance   = entity.Balance{Current: 80_00, Held: 5_50, Withdrawn: 20_00}
+		query     = "SELECT current, held, withdrawn FROM balances WHERE user_id = $1"
 	)
 
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	require.NoError(t, err)
-	r := NewTransaction(db)
+	r := NewTransaction(db, testPointsTTL)
 
 	mock.ExpectQuery(query).
 		WithArgs(userID).
-		WillReturnRows(sqlmock.NewRows([]string{"accrued", "withdrawn"}).AddRow(accrued, withdrawn))
+		WillReturnRows(
+			sqlmock.NewRows([]string{"current", "held", "withdrawn"}).
+				AddRow(balance.Current.String(), balance.Held.String(), balance.Withdrawn.String()),
+		)
+	mock.ExpectQuery(query).
+		WithArgs(newUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"current", "held", "withdrawn"}))
 	mock.ExpectQuery(query).
 		WithArgs(errUserID).
 		WillReturnError(errors.New(""))
 
-	foundCurrent, foundWithdrawn, err := r.GetBalance(ctx, userID)
+	found, err := r.GetBalance(ctx, userID)
 	assert.NoError(t, err, "успешное получение баланса пользователя")
-	assert.Equal(t, accrued-withdrawn, foundCurrent, "успешное получение баланса пользователя")
-	assert.Equal(t, withdrawn, foundWithdrawn, "успешное получение баланса пользователя")
+	assert.Equal(t, balance, found, "успешное получение баланса пользователя")
 
-	_, _, err = r.GetBalance(ctx, errUserID)
+	found, err = r.GetBalance(ctx, newUserID)
+	assert.NoError(t, err, "получение баланса пользователя без операций")
+	assert.Zero(t, found, "получение баланса пользователя без операций")
+
+	_, err = r.GetBalance(ctx, errUserID)
 	assert.Error(t, err, "ошибка при получении баланса пользователя")
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestTransaction_Refund(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransaction(db, testPointsTTL)
+
+	var (
+		ctx         = context.Background()
+		order       = "2377225624"
+		entryID     = 5
+		holdEntryID = 4
+		userID      = 1
+		processedAt = time.Now()
+		withdrawn   = entity.Amount(50_00)
+		refunded    = entity.Amount(10_00)
+		partial     = entity.Amount(15_50)
+		all         = entity.PartnerScope{AllUsers: true}
+		outQuery    = `
+SELECT e.id, coalesce(e.reference_id, e.id), a.user_id, -p.amount, e.created_at
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.order_num = $1
+  AND e.type = 'OUT'
+  AND a.type IN ('USER', 'HOLD')
+FOR UPDATE OF e
+`
+		refundedQuery = `
+SELECT coalesce(sum(p.amount), 0)
+FROM journal_entries e
+         JOIN postings p ON p.entry_id = e.id
+         JOIN ledger_accounts a ON a.id = p.account_id
+WHERE e.reference_id = $1
+  AND e.type = 'REFUND'
+  AND a.type = 'USER'
+`
+		expectWithdrawal = func() {
+			mock.ExpectQuery(outQuery).
+				WithArgs(order).
+				WillReturnRows(
+					sqlmock.NewRows([]string{"id", "spend_id", "user_id", "amount", "created_at"}).
+						AddRow(entryID, holdEntryID, userID, withdrawn.String(), processedAt),
+				)
+			mock.ExpectQuery(refundedQuery).
+				WithArgs(entryID).
+				WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(refunded.String()))
+		}
+		expectRefund = func(amount, restored entity.Amount) {
+			mock.ExpectQuery(userAccountQuery).
+				WithArgs(entity.AccountTypeUser, userID).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+			mock.ExpectExec(createBalanceQuery).
+				WithArgs(userID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(lockBalanceQuery).
+				WithArgs(userID).
+				WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("0.00"))
+			mock.ExpectQuery(systemAccountQuery).
+				WithArgs(entity.AccountTypeRedemptionSink).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+			mock.ExpectQuery(insertEntryQuery).
+				WithArgs(entity.TransactionTypeRefund, order, entryID).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+			mock.ExpectExec(insertPostingQuery).
+				WithArgs(6, 10, amount).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(insertPostingQuery).
+				WithArgs(6, 2, -amount).
+				WillReturnResult(sqlmock.NewResult(2, 1))
+			mock.ExpectQuery(restoreLotsQuery).
+				WithArgs(holdEntryID, amount).
+				WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(restored.String()))
+			if restored < amount {
+				mock.ExpectExec(addLotQuery).
+					WithArgs(userID, 6, amount-restored, testPointsTTL.Seconds()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			expectUpdateBalance(mock, userID, amount, entity.Amount(0), -amount)
+			mock.ExpectExec(outboxQuery).
+				WithArgs(
+					entity.OutboxEventPointsRefunded,
+					fmt.Sprintf(`{"order":"%s","sum":%s,"user_id":%d}`, order, amount, userID),
+				).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+	)
+
+	mock.ExpectBegin()
+	expectWithdrawal()
+	expectRefund(partial, partial)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	expectWithdrawal()
+	// Часть баллов списана до введения учета партий или из истекших партий и зачисляется
+	// новой партией.
+	expectRefund(withdrawn-refunded, 10_00)
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	expectWithdrawal()
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(outQuery).
+		WithArgs(order).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(outQuery).
+		WithArgs(order).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "spend_id", "user_id", "amount", "created_at"}).
+				AddRow(entryID, holdEntryID, userID, withdrawn.String(), processedAt),
+		)
+	mock.ExpectRollback()
+
+	res, err := r.Refund(ctx, entity.PartnerScope{UserIDs: []int{userID}}, order, &partial)
+	assert.NoError(t, err, "частичный возврат")
+	assert.Equal(
+		t,
+		entity.Transaction{
+			Order:        order,
+			Sum:          withdrawn,
+			ProcessedAt:  processedAt,
+			Refunded:     refunded + partial,
+			RefundStatus: entity.RefundStatusPartial,
+		},
+		res,
+		"частичный возврат",
+	)
+
+	res, err = r.Refund(ctx, all, order, nil)
+	assert.NoError(t, err, "возврат оставшейся суммы")
+	assert.Equal(t, withdrawn, res.Refunded, "возврат оставшейся суммы")
+	assert.Equal(t, entity.RefundStatusFull, res.RefundStatus, "возврат оставшейся суммы")
+
+	excess := withdrawn - refunded + 1
+	_, err = r.Refund(ctx, all, order, &excess)
+	assert.ErrorIs(t, err, inerr.ErrRefundExceedsSum, "сумма возвратов превышает сумму списания")
+
+	_, err = r.Refund(ctx, all, order, nil)
+	assert.ErrorIs(t, err, inerr.ErrWithdrawalNotFound, "списание не найдено")
+
+	_, err = r.Refund(ctx, entity.PartnerScope{UserIDs: []int{userID + 1}}, order, nil)
+	assert.ErrorIs(t, err, inerr.ErrWithdrawalNotFound, "списание пользователя, который не является клиентом партнера")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"time"
+)
+
+// Transfer предоставляет доступ к переводам баллов между пользователями. Перевод записывается
+// в журнал операций одной записью с проводками по счетам отправителя и получателя.
+type Transfer struct {
+	db        *sql.DB
+	pointsTTL time.Duration
+}
+
+// NewTransfer возвращает репозиторий переводов. Переведенные баллы зачисляются получателю
+// партиями с оставшимися сроками действия партий отправителя. Если партии отправителя
+// не покрывают сумму перевода, остаток действует в течение pointsTTL, при нулевом pointsTTL -
+// бессрочно.
+func NewTransfer(db *sql.DB, pointsTTL time.Duration) *Transfer {
+	return &Transfer{
+		db:        db,
+		pointsTTL: pointsTTL,
+	}
+}
+
+// Create переводит sum баллов пользователя senderID пользователю с логином recipient.
+// Если получатель не найден, возвращает ошибку errors.ErrRecipientNotFound, если получатель
+// совпадает с отправителем - errors.ErrTransferToSelf. Если limit больше нуля и сумма переводов
+// отправителя за последние сутки с учетом sum превысит limit, возвращает ошибку
+// errors.ErrTransferLimitExceeded, если на счету отправителя недостаточно баллов -
+// errors.ErrInsufficientFunds.
+func (r *Transfer) Create(ctx context.Context, senderID int, recipient string, sum, limit entity.Amount) (entity.Transfer, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.Transfer{}, err
+	}
+
+	t, err := r.create(ctx, tx, senderID, recipient, sum, limit)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return entity.Transfer{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		_ = tx.Rollback()
+
+		return entity.Transfer{}, err
+	}
+
+	return t, nil
+}
+
+func (r *Transfer) create(
+	ctx context.Context,
+	tx *sql.Tx,
+	senderID int,
+	recipient string,
+	sum entity.Amount,
+	limit entity.Amount,
+) (entity.Transfer, error) {
+	t := entity.Transfer{
+		Direction:    entity.TransferDirectionOut,
+		Counterparty: recipient,
+		Sum:          sum,
+	}
+
+	recipientID := 0
+	err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE login = $1", recipient).Scan(&recipientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, inerr.ErrRecipientNotFound
+	}
+	if err != nil {
+		return t, err
+	}
+
+	if recipientID == senderID {
+		return t, inerr.ErrTransferToSelf
+	}
+
+	from, to, err := userAccounts(ctx, tx, senderID, recipientID)
+	if err != nil {
+		return t, err
+	}
+
+	current, err := lockBalances(ctx, tx, senderID, recipientID)
+	if err != nil {
+		return t, err
+	}
+
+	if limit > 0 {
+		sent := entity.Amount(0)
+		err = tx.QueryRowContext(ctx, `
+SELECT coalesce(sum(amount), 0)
+FROM transfers
+WHERE sender_id = $1
+  AND created_at > now() - interval '1 day'
+		`, senderID).Scan(&sent)
+		if err != nil {
+			return t, err
+		}
+
+		if sent+sum > limit {
+			return t, inerr.ErrTransferLimitExceeded
+		}
+	}
+
+	if current < sum {
+		return t, inerr.ErrInsufficientFunds
+	}
+
+	entryID, err := postEntry(
+		ctx,
+		tx,
+		entity.TransactionTypeTransfer,
+		"",
+		nil,
+		posting{account: from, amount: -sum},
+		posting{account: to, amount: sum},
+	)
+	if err != nil {
+		return t, err
+	}
+
+	if err = consumeLots(ctx, tx, senderID, entryID, sum); err != nil {
+		return t, err
+	}
+
+	if err = carryLots(ctx, tx, recipientID, entryID, sum, r.pointsTTL); err != nil {
+		return t, err
+	}
+
+	if err = updateBalance(ctx, tx, senderID, -sum, 0, 0); err != nil {
+		return t, err
+	}
+
+	if err = updateBalance(ctx, tx, recipientID, sum, 0, 0); err != nil {
+		return t, err
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		"INSERT INTO transfers (sender_id, recipient_id, amount, entry_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		senderID,
+		recipientID,
+		sum,
+		entryID,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return t, err
+	}
+
+	// Для партнеров отправителя перевод - списание баллов, не связанное с заказом.
+	err = enqueueWebhooks(ctx, tx, senderID, entity.WebhookEventPointsWithdrawn, map[string]any{
+		"user_id":     senderID,
+		"transfer_id": t.ID,
+		"sum":         sum,
+	})
+	if err != nil {
+		return t, err
+	}
+
+	return t, writeOutbox(ctx, tx, entity.OutboxEventPointsTransferred, map[string]any{
+		"sender_id":    senderID,
+		"recipient_id": recipientID,
+		"transfer_id":  t.ID,
+		"sum":          sum,
+	})
+}
+
+// FindAllByUserID возвращает входящие и исходящие переводы пользователя. Данные отсортированы
+// по времени создания от самых старых к самым новым.
+func (r *Transfer) FindAllByUserID(ctx context.Context, userID int) (transfers []entity.Transfer, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT t.id,
+       CASE WHEN t.sender_id = $1 THEN 'OUT' ELSE 'IN' END,
+       u.login,
+       t.amount,
+       t.created_at
+FROM transfers t
+         JOIN users u ON u.id = CASE WHEN t.sender_id = $1 THEN t.recipient_id ELSE t.sender_id END
+WHERE t.sender_id = $1
+   OR t.recipient_id = $1
+ORDER BY t.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		t := entity.Transfer{}
+		err = rows.Scan(&t.ID, &t.Direction, &t.Counterparty, &t.Sum, &t.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		transfers = append(transfers, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transfers, err
+}
+
+// userAccounts возвращает идентификаторы счетов отправителя и получателя перевода. Получение
+// счёта блокирует его строку, поэтому счета запрашиваются в порядке возрастания идентификаторов
+// пользователей - так же, как затем блокируются балансы в lockBalances.
+func userAccounts(ctx context.Context, tx *sql.Tx, senderID, recipientID int) (from, to int, err error) {
+	if senderID < recipientID {
+		if from, err = userAccount(ctx, tx, senderID, entity.AccountTypeUser); err != nil {
+			return 0, 0, err
+		}
+
+		to, err = userAccount(ctx, tx, recipientID, entity.AccountTypeUser)
+
+		return from, to, err
+	}
+
+	if to, err = userAccount(ctx, tx, recipientID, entity.AccountTypeUser); err != nil {
+		return 0, 0, err
+	}
+
+	from, err = userAccount(ctx, tx, senderID, entity.AccountTypeUser)
+
+	return from, to, err
+}
+
+// lockBalances блокирует строки балансов отправителя и получателя перевода в порядке
+// возрастания идентификаторов пользователей, чтобы встречные переводы не приводили
+// к взаимной блокировке, и возвращает текущий баланс отправителя.
+func lockBalances(ctx context.Context, tx *sql.Tx, senderID, recipientID int) (entity.Amount, error) {
+	if senderID < recipientID {
+		current, err := lockBalance(ctx, tx, senderID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = lockBalance(ctx, tx, recipientID)
+
+		return current, err
+	}
+
+	if _, err := lockBalance(ctx, tx, recipientID); err != nil {
+		return 0, err
+	}
+
+	return lockBalance(ctx, tx, senderID)
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const (
+	findRecipientQuery = "SELECT id FROM users WHERE login = $1"
+	sentTodayQuery     = `
+SELECT coalesce(sum(amount), 0)
+FROM transfers
+WHERE sender_id = $1
+  AND created_at > now() - interval '1 day'
+`
+)
+
+// expectTransferAccounts добавляет ожидания поиска получателя, получения счетов отправителя
+// и получателя и блокировки их балансов. Счета и балансы запрашиваются в порядке возрастания
+// идентификаторов пользователей. Текущий баланс отправителя равен current.
+func expectTransferAccounts(mock sqlmock.Sqlmock, senderID, recipientID int, recipient string, current entity.Amount) {
+	mock.ExpectQuery(findRecipientQuery).
+		WithArgs(recipient).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(recipientID))
+
+	accounts := map[int]int{senderID: 10, recipientID: 20}
+	ids := []int{senderID, recipientID}
+	if recipientID < senderID {
+		ids = []int{recipientID, senderID}
+	}
+	for _, id := range ids {
+		mock.ExpectQuery(userAccountQuery).
+			WithArgs(entity.AccountTypeUser, id).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(accounts[id]))
+	}
+	for _, id := range ids {
+		mock.ExpectExec(createBalanceQuery).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(lockBalanceQuery).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow(current.String()))
+	}
+}
+
+func TestTransfer_Create(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		senderID    = 1
+		recipientID = 2
+		recipient   = "family"
+		sum         = entity.Amount(30_00)
+		limit       = entity.Amount(100_00)
+		createdAt   = time.Now()
+		insertQuery = "INSERT INTO transfers (sender_id, recipient_id, amount, entry_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransfer(db, testPointsTTL)
+
+	mock.ExpectBegin()
+	expectTransferAccounts(mock, senderID, recipientID, recipient, 50_00)
+	mock.ExpectQuery(sentTodayQuery).
+		WithArgs(senderID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("70.00"))
+	mock.ExpectQuery(insertEntryQuery).
+		WithArgs(entity.TransactionTypeTransfer, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(100))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(100, 10, -sum).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPostingQuery).
+		WithArgs(100, 20, sum).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(consumeLotsQuery).
+		WithArgs(senderID, sum, 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(carryLotsQuery).
+		WithArgs(recipientID, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("20.00"))
+	// Часть баллов отправителя не отражена в партиях и зачисляется новой партией.
+	mock.ExpectExec(addLotQuery).
+		WithArgs(recipientID, 100, sum-20_00, testPointsTTL.Seconds()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectUpdateBalance(mock, senderID, -sum, entity.Amount(0), entity.Amount(0))
+	expectUpdateBalance(mock, recipientID, sum, entity.Amount(0), entity.Amount(0))
+	mock.ExpectQuery(insertQuery).
+		WithArgs(senderID, recipientID, sum, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, createdAt))
+	mock.ExpectExec(webhookQuery).
+		WithArgs(
+			entity.WebhookEventPointsWithdrawn,
+			fmt.Sprintf(`{"sum":%s,"transfer_id":5,"user_id":%d}`, sum, senderID),
+			senderID,
+		).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).
+		WithArgs(
+			entity.OutboxEventPointsTransferred,
+			fmt.Sprintf(`{"recipient_id":%d,"sender_id":%d,"sum":%s,"transfer_id":5}`, recipientID, senderID, sum),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	expectTransferAccounts(mock, senderID, recipientID, recipient, 50_00)
+	mock.ExpectQuery(sentTodayQuery).
+		WithArgs(senderID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("70.01"))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	expectTransferAccounts(mock, senderID, recipientID, recipient, 29_99)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(findRecipientQuery).
+		WithArgs(recipient).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(senderID))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(findRecipientQuery).
+		WithArgs(recipient).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	res, err := r.Create(ctx, senderID, recipient, sum, limit)
+	assert.NoError(t, err, "успешный перевод")
+	assert.Equal(
+		t,
+		entity.Transfer{ID: 5, Direction: entity.TransferDirectionOut, Counterparty: recipient, Sum: sum, CreatedAt: createdAt},
+		res,
+		"успешный перевод",
+	)
+
+	_, err = r.Create(ctx, senderID, recipient, sum, limit)
+	assert.ErrorIs(t, err, inerr.ErrTransferLimitExceeded, "превышен суточный лимит переводов")
+
+	_, err = r.Create(ctx, senderID, recipient, sum, 0)
+	assert.ErrorIs(t, err, inerr.ErrInsufficientFunds, "на счету недостаточно баллов")
+
+	_, err = r.Create(ctx, senderID, recipient, sum, limit)
+	assert.ErrorIs(t, err, inerr.ErrTransferToSelf, "перевод самому себе")
+
+	_, err = r.Create(ctx, senderID, recipient, sum, limit)
+	assert.ErrorIs(t, err, inerr.ErrRecipientNotFound, "получатель не найден")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransfer_CreateLockOrder(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		senderID    = 2
+		recipientID = 1
+		recipient   = "family"
+		sum         = entity.Amount(30_00)
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransfer(db, testPointsTTL)
+
+	// Встречный перевод блокирует счета и балансы в том же порядке, что и прямой:
+	// сначала получателя с меньшим идентификатором, затем отправителя.
+	mock.ExpectBegin()
+	mock.ExpectQuery(findRecipientQuery).
+		WithArgs(recipient).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(recipientID))
+	mock.ExpectQuery(userAccountQuery).
+		WithArgs(entity.AccountTypeUser, recipientID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20))
+	mock.ExpectQuery(userAccountQuery).
+		WithArgs(entity.AccountTypeUser, senderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectExec(createBalanceQuery).
+		WithArgs(recipientID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lockBalanceQuery).
+		WithArgs(recipientID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("0.00"))
+	mock.ExpectExec(createBalanceQuery).
+		WithArgs(senderID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lockBalanceQuery).
+		WithArgs(senderID).
+		WillReturnRows(sqlmock.NewRows([]string{"current"}).AddRow("29.99"))
+	mock.ExpectRollback()
+
+	_, err = r.Create(ctx, senderID, recipient, sum, 0)
+	assert.ErrorIs(t, err, inerr.ErrInsufficientFunds)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransfer_FindAllByUserID(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		userID    = 1
+		createdAt = time.Now()
+		query     = `
+SELECT t.id,
+       CASE WHEN t.sender_id = $1 THEN 'OUT' ELSE 'IN' END,
+       u.login,
+       t.amount,
+       t.created_at
+FROM transfers t
+         JOIN users u ON u.id = CASE WHEN t.sender_id = $1 THEN t.recipient_id ELSE t.sender_id END
+WHERE t.sender_id = $1
+   OR t.recipient_id = $1
+ORDER BY t.created_at
+`
+		transfers = []entity.Transfer{
+			{ID: 1, Direction: entity.TransferDirectionOut, Counterparty: "family", Sum: 30_00, CreatedAt: createdAt},
+			{ID: 2, Direction: entity.TransferDirectionIn, Counterparty: "friend", Sum: 12_50, CreatedAt: createdAt},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewTransfer(db, testPointsTTL)
+
+	rows := sqlmock.NewRows([]string{"id", "direction", "login", "amount", "created_at"})
+	for _, tr := range transfers {
+		rows.AddRow(tr.ID, tr.Direction, tr.Counterparty, tr.Sum.String(), tr.CreatedAt)
+	}
+	mock.ExpectQuery(query).WithArgs(userID).WillReturnRows(rows)
+
+	res, err := r.FindAllByUserID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, transfers, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
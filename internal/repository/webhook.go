@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook предоставляет доступ к подпискам партнеров на уведомления о событиях и журналу
+// доставки уведомлений. Уведомления добавляются в журнал в транзакциях, которые вызвали
+// события, и доставляются отдельно.
+type Webhook struct {
+	db *sql.DB
+}
+
+func NewWebhook(db *sql.DB) *Webhook {
+	return &Webhook{db: db}
+}
+
+// Create добавляет подписку и возвращает ее с идентификатором и временем создания.
+func (r *Webhook) Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error) {
+	err := r.db.QueryRowContext(ctx, `
+INSERT INTO webhooks (url, secret, events, user_ids, all_users)
+VALUES ($1, $2, string_to_array($3, ','), string_to_array($4, ',')::integer[], $5)
+RETURNING id, created_at
+	`, w.URL, w.Secret, joinEvents(w.Events), joinIDs(w.UserIDs), w.AllUsers).Scan(&w.ID, &w.CreatedAt)
+
+	return w, err
+}
+
+// FindAll возвращает все подписки без ключей подписи. Данные отсортированы по времени создания.
+func (r *Webhook) FindAll(ctx context.Context) (webhooks []entity.Webhook, err error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, url, array_to_string(events, ','), array_to_string(user_ids, ','), all_users, created_at
+FROM webhooks
+ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var (
+			w       = entity.Webhook{}
+			events  = ""
+			userIDs = ""
+		)
+		if err = rows.Scan(&w.ID, &w.URL, &events, &userIDs, &w.AllUsers, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		for _, e := range strings.Split(events, ",") {
+			w.Events = append(w.Events, entity.WebhookEvent(e))
+		}
+		if w.UserIDs, err = splitIDs(userIDs); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, err
+}
+
+// Delete удаляет подписку вместе с журналом доставки ее уведомлений. Если подписка не найдена,
+// возвращает ошибку errors.ErrWebhookNotFound.
+func (r *Webhook) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = inerr.ErrWebhookNotFound
+	}
+
+	return err
+}
+
+// FindDeliveries возвращает не более limit последних уведомлений по подписке webhookID.
+// Данные отсортированы от самых новых к самым старым.
+func (r *Webhook) FindDeliveries(ctx context.Context, webhookID int, limit int) ([]entity.WebhookDelivery, error) {
+	return r.findDeliveries(ctx, `
+SELECT d.id,
+       d.webhook_id,
+       d.event,
+       d.payload,
+       d.status,
+       d.attempts,
+       coalesce(d.response_code, 0),
+       d.error,
+       d.next_attempt_at,
+       d.created_at,
+       d.delivered_at,
+       w.url,
+       ''
+FROM webhook_deliveries d
+         JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.webhook_id = $1
+ORDER BY d.id DESC
+LIMIT $2
+	`, webhookID, limit)
+}
+
+// FindDueDeliveries возвращает не более limit недоставленных уведомлений, время следующей
+// попытки доставки которых наступило к моменту now, с адресами и ключами подписи подписок.
+func (r *Webhook) FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	return r.findDeliveries(ctx, `
+SELECT d.id,
+       d.webhook_id,
+       d.event,
+       d.payload,
+       d.status,
+       d.attempts,
+       coalesce(d.response_code, 0),
+       d.error,
+       d.next_attempt_at,
+       d.created_at,
+       d.delivered_at,
+       w.url,
+       w.secret
+FROM webhook_deliveries d
+         JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.status = 'PENDING'
+  AND d.next_attempt_at <= $1
+ORDER BY d.next_attempt_at
+LIMIT $2
+	`, now, limit)
+}
+
+// SaveAttempt сохраняет результат попытки доставки уведомления: статус, количество попыток,
+// код ответа, текст ошибки, время следующей попытки и время доставки.
+func (r *Webhook) SaveAttempt(ctx context.Context, d entity.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE webhook_deliveries
+SET status          = $1,
+    attempts        = $2,
+    response_code   = nullif($3, 0),
+    error           = $4,
+    next_attempt_at = $5,
+    delivered_at    = $6
+WHERE id = $7
+	`, d.Status, d.Attempts, d.ResponseCode, d.Error, d.NextAttemptAt, d.DeliveredAt, d.ID)
+
+	return err
+}
+
+func (r *Webhook) findDeliveries(ctx context.Context, query string, args ...any) (deliveries []entity.WebhookDelivery, err error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var (
+			d       = entity.WebhookDelivery{}
+			payload = ""
+		)
+		err = rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseCode,
+			&d.Error,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+			&d.URL,
+			&d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, err
+}
+
+// enqueueWebhooks добавляет уведомление о событии event пользователя userID с данными data
+// для каждой подписки на это событие, в которую входит пользователь. Уведомление содержит
+// событие, время его возникновения и данные.
+func enqueueWebhooks(ctx context.Context, tx *sql.Tx, userID int, event entity.WebhookEvent, data map[string]any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT id, $1, json_build_object('event', $1::text, 'created_at', now(), 'data', $2::json)::jsonb
+FROM webhooks
+WHERE $1 = ANY (events)
+  AND (all_users OR $3 = ANY (user_ids))
+	`, event, string(b), userID)
+
+	return err
+}
+
+func joinEvents(events []entity.WebhookEvent) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = string(e)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func joinIDs(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func splitIDs(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	ids := make([]int, len(parts))
+	for i, p := range parts {
+		id, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+// enqueueOrderWebhooks добавляет уведомления о переходе заказа num в конечный статус status.
+// Для остальных статусов уведомления не создаются.
+func enqueueOrderWebhooks(
+	ctx context.Context,
+	tx *sql.Tx,
+	userID int,
+	num string,
+	status entity.OrderStatus,
+	accrual entity.Amount,
+) error {
+	switch status {
+	case entity.OrderStatusProcessed:
+		return enqueueWebhooks(ctx, tx, userID, entity.WebhookEventOrderProcessed, map[string]any{
+			"user_id": userID,
+			"order":   num,
+			"accrual": accrual,
+		})
+	case entity.OrderStatusInvalid:
+		return enqueueWebhooks(ctx, tx, userID, entity.WebhookEventOrderInvalid, map[string]any{
+			"user_id": userID,
+			"order":   num,
+		})
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWebhook_Create(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		w         = entity.Webhook{
+			URL:     "https://partner.example/hooks",
+			Events:  []entity.WebhookEvent{entity.WebhookEventOrderProcessed, entity.WebhookEventPointsWithdrawn},
+			UserIDs: []int{1, 2},
+			Secret:  "secret",
+		}
+		query = `
+INSERT INTO webhooks (url, secret, events, user_ids, all_users)
+VALUES ($1, $2, string_to_array($3, ','), string_to_array($4, ',')::integer[], $5)
+RETURNING id, created_at
+`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewWebhook(db)
+
+	mock.ExpectQuery(query).
+		WithArgs(w.URL, w.Secret, "order.processed,points.withdrawn", "1,2", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+
+	res, err := r.Create(ctx, w)
+	assert.NoError(t, err)
+	w.ID = 3
+	w.CreatedAt = createdAt
+	assert.Equal(t, w, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhook_FindAll(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		createdAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		query     = `
+SELECT id, url, array_to_string(events, ','), array_to_string(user_ids, ','), all_users, created_at
+FROM webhooks
+ORDER BY id
+`
+		want = []entity.Webhook{
+			{
+				ID:        1,
+				URL:       "https://partner.example/hooks",
+				Events:    []entity.WebhookEvent{entity.WebhookEventOrderInvalid},
+				UserIDs:   []int{1, 2},
+				CreatedAt: createdAt,
+			},
+			{
+				ID:        2,
+				URL:       "https://another.example/hooks",
+				Events:    []entity.WebhookEvent{entity.WebhookEventOrderProcessed, entity.WebhookEventOrderInvalid},
+				AllUsers:  true,
+				CreatedAt: createdAt,
+			},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewWebhook(db)
+
+	mock.ExpectQuery(query).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "url", "events", "user_ids", "all_users", "created_at"}).
+				AddRow(1, want[0].URL, "order.invalid", "1,2", false, createdAt).
+				AddRow(2, want[1].URL, "order.processed,order.invalid", "", true, createdAt),
+		)
+
+	res, err := r.FindAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhook_Delete(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		query = "DELETE FROM webhooks WHERE id = $1"
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewWebhook(db)
+
+	mock.ExpectExec(query).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, r.Delete(ctx, 1), "подписка удалена")
+	assert.ErrorIs(t, r.Delete(ctx, 2), inerr.ErrWebhookNotFound, "подписка не найдена")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhook_FindDueDeliveries(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		now     = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		payload = `{"event": "order.invalid", "data": {"order": "2377225624", "user_id": 1}}`
+		query   = `
+SELECT d.id,
+       d.webhook_id,
+       d.event,
+       d.payload,
+       d.status,
+       d.attempts,
+       coalesce(d.response_code, 0),
+       d.error,
+       d.next_attempt_at,
+       d.created_at,
+       d.delivered_at,
+       w.url,
+       w.secret
+FROM webhook_deliveries d
+         JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.status = 'PENDING'
+  AND d.next_attempt_at <= $1
+ORDER BY d.next_attempt_at
+LIMIT $2
+	`
+		want = []entity.WebhookDelivery{
+			{
+				ID:            7,
+				WebhookID:     1,
+				Event:         entity.WebhookEventOrderInvalid,
+				Payload:       json.RawMessage(payload),
+				Status:        entity.DeliveryStatusPending,
+				Attempts:      1,
+				ResponseCode:  502,
+				Error:         "unexpected response status 502",
+				NextAttemptAt: now.Add(-time.Minute),
+				CreatedAt:     now.Add(-time.Hour),
+				URL:           "https://partner.example/hooks",
+				Secret:        "secret",
+			},
+		}
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewWebhook(db)
+
+	mock.ExpectQuery(query).
+		WithArgs(now, 10).
+		WillReturnRows(
+			sqlmock.NewRows([]string{
+				"id",
+				"webhook_id",
+				"event",
+				"payload",
+				"status",
+				"attempts",
+				"response_code",
+				"error",
+				"next_attempt_at",
+				"created_at",
+				"delivered_at",
+				"url",
+				"secret",
+			}).AddRow(
+				7,
+				1,
+				"order.invalid",
+				payload,
+				"PENDING",
+				1,
+				502,
+				want[0].Error,
+				want[0].NextAttemptAt,
+				want[0].CreatedAt,
+				nil,
+				want[0].URL,
+				want[0].Secret,
+			),
+		)
+
+	res, err := r.FindDueDeliveries(ctx, now, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, want, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhook_SaveAttempt(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		deliveredAt = time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+		d           = entity.WebhookDelivery{
+			ID:            7,
+			Status:        entity.DeliveryStatusDelivered,
+			Attempts:      2,
+			ResponseCode:  200,
+			NextAttemptAt: deliveredAt.Add(-time.Minute),
+			DeliveredAt:   &deliveredAt,
+		}
+		query = `
+UPDATE webhook_deliveries
+SET status          = $1,
+    attempts        = $2,
+    response_code   = nullif($3, 0),
+    error           = $4,
+    next_attempt_at = $5,
+    delivered_at    = $6
+WHERE id = $7
+	`
+	)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	r := NewWebhook(db)
+
+	mock.ExpectExec(query).
+		WithArgs(d.Status, d.Attempts, d.ResponseCode, d.Error, d.NextAttemptAt, d.DeliveredAt, d.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, r.SaveAttempt(ctx, d))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,79 @@
+package security
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"net/http"
+)
+
+// PartnerAuthenticator аутентифицирует администратора по токену администратора и партнеров
+// по ключу API и устанавливает в контекст запроса пользователей, от имени которых выполняется
+// запрос.
+type PartnerAuthenticator struct {
+	adminToken string
+	storage    PartnerStorage
+}
+
+type PartnerStorage interface {
+	FindScope(ctx context.Context, keyHash string) (entity.PartnerScope, error)
+}
+
+type partnerScopeContextKey string
+
+const partnerScopeKey partnerScopeContextKey = "currentPartnerScope"
+
+func NewPartnerAuthenticator(adminToken string, store PartnerStorage) *PartnerAuthenticator {
+	return &PartnerAuthenticator{
+		adminToken: adminToken,
+		storage:    store,
+	}
+}
+
+// Authenticate проверяет ключ key и устанавливает в контекст запроса пользователей, от имени
+// которых выполняется запрос: для токена администратора - всех пользователей, для ключа API
+// партнера - его клиентов. Партнер ищется в PartnerStorage по хэшу ключа HashKey. Если токен
+// администратора не задан, принимаются только ключи партнеров. Если ключ не найден, возвращает
+// ошибку.
+func (a *PartnerAuthenticator) Authenticate(key string, r *http.Request) (*http.Request, error) {
+	if key == "" {
+		return r, errors.New("empty key")
+	}
+
+	if a.adminToken != "" && subtle.ConstantTimeCompare([]byte(key), []byte(a.adminToken)) == 1 {
+		return a.setScope(entity.PartnerScope{AllUsers: true}, r), nil
+	}
+
+	s, err := a.storage.FindScope(r.Context(), HashKey(key))
+	if err != nil {
+		return r, err
+	}
+
+	return a.setScope(s, r), nil
+}
+
+// PartnerScope возвращает пользователей, от имени которых выполняется аутентифицированный
+// запрос, из контекста запроса.
+func (a *PartnerAuthenticator) PartnerScope(r *http.Request) (entity.PartnerScope, error) {
+	val := r.Context().Value(partnerScopeKey)
+	if val == nil {
+		return entity.PartnerScope{}, errors.New("not found")
+	}
+
+	return val.(entity.PartnerScope), nil
+}
+
+func (a *PartnerAuthenticator) setScope(s entity.PartnerScope, r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), partnerScopeKey, s))
+}
+
+// HashKey возвращает хэш ключа API партнера для хранения. Ключ генерируется случайно
+// и имеет достаточную длину, поэтому хэшируется без соли.
+func HashKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(h[:])
+}
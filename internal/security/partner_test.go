@@ -0,0 +1,65 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http/httptest"
+	"testing"
+)
+
+type PartnerStorageMock struct {
+	mock.Mock
+}
+
+func (m *PartnerStorageMock) FindScope(_ context.Context, keyHash string) (entity.PartnerScope, error) {
+	args := m.Called(keyHash)
+
+	return args.Get(0).(entity.PartnerScope), args.Error(1)
+}
+
+func TestPartnerAuthenticator_Authenticate(t *testing.T) {
+	var (
+		adminToken = "admin"
+		key        = "key"
+		scope      = entity.PartnerScope{UserIDs: []int{1, 2}}
+		request    = httptest.NewRequest("", "/", nil)
+		storage    = &PartnerStorageMock{}
+	)
+	storage.On("FindScope", HashKey(key)).Return(scope, nil).Once()
+	storage.On("FindScope", HashKey("unknown")).Return(entity.PartnerScope{}, errors.New("")).Once()
+	storage.On("FindScope", HashKey(adminToken)).Return(entity.PartnerScope{}, errors.New("")).Once()
+	authenticator := NewPartnerAuthenticator(adminToken, storage)
+
+	_, err := authenticator.PartnerScope(request)
+	assert.Error(t, err, "неаутентифицированный запрос")
+
+	_, err = authenticator.Authenticate("", request)
+	assert.Error(t, err, "пустой ключ")
+
+	_, err = authenticator.Authenticate("unknown", request)
+	assert.Error(t, err, "несуществующий ключ")
+
+	r, err := authenticator.Authenticate(key, request)
+	assert.NoError(t, err, "аутентификация партнера")
+	s, _ := authenticator.PartnerScope(r)
+	assert.Equal(t, scope, s, "аутентификация партнера")
+
+	r, err = authenticator.Authenticate(adminToken, request)
+	assert.NoError(t, err, "аутентификация администратора")
+	s, _ = authenticator.PartnerScope(r)
+	assert.Equal(t, entity.PartnerScope{AllUsers: true}, s, "аутентификация администратора")
+
+	_, err = NewPartnerAuthenticator("", storage).Authenticate(adminToken, request)
+	assert.Error(t, err, "токен администратора не задан")
+
+	storage.AssertExpectations(t)
+}
+
+func TestHashKey(t *testing.T) {
+	assert.Equal(t, HashKey("key"), HashKey("key"), "хэш ключа не меняется")
+	assert.NotEqual(t, HashKey("key"), HashKey("another"), "разные ключи")
+	assert.NotContains(t, HashKey("key"), "key", "ключ не хранится в открытом виде")
+}
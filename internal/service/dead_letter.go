@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"time"
+)
+
+type DeadLetter struct {
+	repository DeadLetterRepository
+	updater    StatusUpdater
+}
+
+type DeadLetterRepository interface {
+	Create(ctx context.Context, res entity.StatusCheckResult, reason string, next time.Time) error
+	FindAll(ctx context.Context) ([]entity.DeadLetter, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]entity.DeadLetter, error)
+	FindByID(ctx context.Context, id int) (entity.DeadLetter, error)
+	Reschedule(ctx context.Context, id int, reason string, next time.Time) error
+	Delete(ctx context.Context, id int) error
+}
+
+type StatusUpdater interface {
+	UpdateStatus(ctx context.Context, num string, status entity.OrderStatus, accrual entity.Amount) error
+}
+
+const retryBatchSize = 100
+
+func NewDeadLetter(r DeadLetterRepository, u StatusUpdater) *DeadLetter {
+	return &DeadLetter{
+		repository: r,
+		updater:    u,
+	}
+}
+
+// Add сохраняет результат проверки статуса заказа, который не удалось применить,
+// и назначает первую повторную попытку.
+func (s *DeadLetter) Add(ctx context.Context, res entity.StatusCheckResult, cause error) error {
+	return s.repository.Create(ctx, res, cause.Error(), time.Now().Add(entity.RetryDelay(1)))
+}
+
+// GetAll возвращает все сохраненные результаты.
+func (s *DeadLetter) GetAll(ctx context.Context) ([]entity.DeadLetter, error) {
+	return s.repository.FindAll(ctx)
+}
+
+// Retry повторно применяет сохраненный результат. В случае успеха удаляет результат,
+// иначе назначает следующую попытку с экспоненциально растущей задержкой и возвращает ошибку.
+func (s *DeadLetter) Retry(ctx context.Context, id int) error {
+	d, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.retry(ctx, d)
+}
+
+// RetryDue повторно применяет результаты, время следующей попытки которых наступило.
+// Возвращает количество успешно примененных результатов.
+func (s *DeadLetter) RetryDue(ctx context.Context) (int, error) {
+	letters, err := s.repository.FindDue(ctx, time.Now(), retryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, d := range letters {
+		if err := s.retry(ctx, d); err == nil {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// Discard удаляет сохраненный результат без применения.
+func (s *DeadLetter) Discard(ctx context.Context, id int) error {
+	return s.repository.Delete(ctx, id)
+}
+
+func (s *DeadLetter) retry(ctx context.Context, d entity.DeadLetter) error {
+	res := d.Result()
+	err := s.updater.UpdateStatus(ctx, res.Num, res.Status, res.Accrual)
+	if errors.Is(err, inerr.ErrInvalidTransition) || errors.Is(err, inerr.ErrOrderNotFound) {
+		// Статус заказа уже изменился или заказ удален, повторять обновление бессмысленно.
+		return s.repository.Delete(ctx, d.ID)
+	}
+
+	if err != nil {
+		if rerr := s.repository.Reschedule(ctx, d.ID, err.Error(), time.Now().Add(entity.RetryDelay(d.Attempts+1))); rerr != nil {
+			return rerr
+		}
+
+		return err
+	}
+
+	return s.repository.Delete(ctx, d.ID)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type DeadLetterRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *DeadLetterRepositoryMock) Create(_ context.Context, res entity.StatusCheckResult, reason string, next time.Time) error {
+	args := m.Called(res, reason, next)
+
+	return args.Error(0)
+}
+
+func (m *DeadLetterRepositoryMock) FindAll(_ context.Context) ([]entity.DeadLetter, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.DeadLetter), args.Error(1)
+}
+
+func (m *DeadLetterRepositoryMock) FindDue(_ context.Context, now time.Time, limit int) ([]entity.DeadLetter, error) {
+	args := m.Called(now, limit)
+
+	return args.Get(0).([]entity.DeadLetter), args.Error(1)
+}
+
+func (m *DeadLetterRepositoryMock) FindByID(_ context.Context, id int) (entity.DeadLetter, error) {
+	args := m.Called(id)
+
+	return args.Get(0).(entity.DeadLetter), args.Error(1)
+}
+
+func (m *DeadLetterRepositoryMock) Reschedule(_ context.Context, id int, reason string, next time.Time) error {
+	args := m.Called(id, reason, next)
+
+	return args.Error(0)
+}
+
+func (m *DeadLetterRepositoryMock) Delete(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+type StatusUpdaterMock struct {
+	mock.Mock
+}
+
+func (m *StatusUpdaterMock) UpdateStatus(_ context.Context, num string, status entity.OrderStatus, accrual entity.Amount) error {
+	args := m.Called(num, status, accrual)
+
+	return args.Error(0)
+}
+
+func TestDeadLetter_Add(t *testing.T) {
+	var (
+		ctx = context.Background()
+		res = entity.StatusCheckResult{
+			Num:     "148561163482734",
+			Status:  entity.OrderStatusProcessed,
+			Accrual: 100,
+		}
+		repository = &DeadLetterRepositoryMock{}
+	)
+
+	repository.
+		On("Create", res, "connection refused", mock.MatchedBy(func(next time.Time) bool {
+			return next.After(time.Now())
+		})).
+		Return(nil).
+		Once()
+	service := DeadLetter{repository: repository}
+
+	assert.NoError(t, service.Add(ctx, res, errors.New("connection refused")), "успешное сохранение результата")
+	repository.AssertExpectations(t)
+}
+
+func TestDeadLetter_Retry(t *testing.T) {
+	var (
+		ctx         = context.Background()
+		id          = 1
+		failedID    = 2
+		notFoundID  = 3
+		staleID     = 4
+		dl          = entity.DeadLetter{ID: id, Order: "148561163482734", Status: entity.OrderStatusProcessed, Accrual: 100, Attempts: 1}
+		failedDL    = entity.DeadLetter{ID: failedID, Order: "267624438264306", Status: entity.OrderStatusInvalid, Attempts: 3}
+		staleDL     = entity.DeadLetter{ID: staleID, Order: "166221614883769", Status: entity.OrderStatusProcessing, Attempts: 2}
+		repository  = &DeadLetterRepositoryMock{}
+		updater     = &StatusUpdaterMock{}
+		anyTime     = mock.AnythingOfType("time.Time")
+		updateError = errors.New("connection refused")
+	)
+
+	repository.On("FindByID", id).Return(dl, nil).Once()
+	repository.On("FindByID", failedID).Return(failedDL, nil).Once()
+	repository.On("FindByID", notFoundID).Return(entity.DeadLetter{}, inerr.ErrDeadLetterNotFound).Once()
+	repository.On("FindByID", staleID).Return(staleDL, nil).Once()
+	updater.On("UpdateStatus", staleDL.Order, staleDL.Status, staleDL.Accrual).Return(inerr.ErrInvalidTransition).Once()
+	repository.On("Delete", staleID).Return(nil).Once()
+	updater.On("UpdateStatus", dl.Order, dl.Status, dl.Accrual).Return(nil).Once()
+	updater.On("UpdateStatus", failedDL.Order, failedDL.Status, failedDL.Accrual).Return(updateError).Once()
+	repository.On("Delete", id).Return(nil).Once()
+	repository.On("Reschedule", failedID, updateError.Error(), anyTime).Return(nil).Once()
+	service := DeadLetter{
+		repository: repository,
+		updater:    updater,
+	}
+
+	assert.NoError(t, service.Retry(ctx, id), "успешное применение результата")
+	assert.ErrorIs(t, service.Retry(ctx, failedID), updateError, "ошибка при применении результата")
+	assert.ErrorIs(t, service.Retry(ctx, notFoundID), inerr.ErrDeadLetterNotFound, "результат не найден")
+	assert.NoError(t, service.Retry(ctx, staleID), "удаление результата с недопустимым переходом статуса")
+	repository.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
+
+func TestDeadLetter_RetryDue(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		letters = []entity.DeadLetter{
+			{ID: 1, Order: "148561163482734", Status: entity.OrderStatusProcessed, Accrual: 100, Attempts: 1},
+			{ID: 2, Order: "267624438264306", Status: entity.OrderStatusInvalid, Attempts: 1},
+		}
+		repository = &DeadLetterRepositoryMock{}
+		updater    = &StatusUpdaterMock{}
+		anyTime    = mock.AnythingOfType("time.Time")
+	)
+
+	repository.On("FindDue", anyTime, retryBatchSize).Return(letters, nil).Once()
+	updater.On("UpdateStatus", letters[0].Order, letters[0].Status, letters[0].Accrual).Return(nil).Once()
+	updater.On("UpdateStatus", letters[1].Order, letters[1].Status, letters[1].Accrual).Return(errors.New("")).Once()
+	repository.On("Delete", letters[0].ID).Return(nil).Once()
+	repository.On("Reschedule", letters[1].ID, "", anyTime).Return(nil).Once()
+	service := DeadLetter{
+		repository: repository,
+		updater:    updater,
+	}
+
+	applied, err := service.RetryDue(ctx)
+	assert.NoError(t, err, "успешное повторное применение результатов")
+	assert.Equal(t, 1, applied, "успешное повторное применение результатов")
+	repository.AssertExpectations(t)
+	updater.AssertExpectations(t)
+}
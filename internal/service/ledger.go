@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"log"
+)
+
+type Ledger struct {
+	repository LedgerRepository
+}
+
+type LedgerRepository interface {
+	Reconcile(ctx context.Context) (entity.Reconciliation, error)
+}
+
+func NewLedger(r LedgerRepository) *Ledger {
+	return &Ledger{repository: r}
+}
+
+// Reconcile выполняет сверку журнала операций с баллами. Найденные расхождения
+// записываются в лог.
+func (s *Ledger) Reconcile(ctx context.Context) (entity.Reconciliation, error) {
+	res, err := s.repository.Reconcile(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	if !res.Balanced {
+		log.Printf(
+			"журнал операций не сбалансирован: сумма проводок %s, несбалансированные записи %v, отрицательные остатки у пользователей %v",
+			res.Total,
+			res.UnbalancedEntries,
+			res.NegativeAccounts,
+		)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type LedgerRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LedgerRepositoryMock) Reconcile(_ context.Context) (entity.Reconciliation, error) {
+	args := m.Called()
+
+	return args.Get(0).(entity.Reconciliation), args.Error(1)
+}
+
+func TestLedger_Reconcile(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		repository = &LedgerRepositoryMock{}
+		balanced   = entity.Reconciliation{Balanced: true, UnbalancedEntries: []int{}, NegativeAccounts: []int{}}
+		unbalanced = entity.Reconciliation{UnbalancedEntries: []int{3}, NegativeAccounts: []int{}, Total: 100}
+	)
+
+	repository.On("Reconcile").Return(balanced, nil).Once()
+	repository.On("Reconcile").Return(unbalanced, nil).Once()
+	repository.On("Reconcile").Return(entity.Reconciliation{}, errors.New("")).Once()
+	service := NewLedger(repository)
+
+	res, err := service.Reconcile(ctx)
+	assert.NoError(t, err, "сверка сбалансированного журнала")
+	assert.Equal(t, balanced, res, "сверка сбалансированного журнала")
+
+	res, err = service.Reconcile(ctx)
+	assert.NoError(t, err, "сверка несбалансированного журнала")
+	assert.Equal(t, unbalanced, res, "сверка несбалансированного журнала")
+
+	_, err = service.Reconcile(ctx)
+	assert.Error(t, err, "ошибка при сверке журнала")
+	repository.AssertExpectations(t)
+}
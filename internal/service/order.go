@@ -7,15 +7,22 @@ import (
 
 type Order struct {
 	repository OrderRepository
-	queue      chan<- entity.StatusCheckJob
+	queue      StatusCheckQueue
 }
 
 type OrderRepository interface {
 	Create(ctx context.Context, userID int, num string) error
-	FindAllByUserID(ctx context.Context, userID int) ([]entity.Order, error)
+	CreateBatch(ctx context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error)
+	FindAllByUserID(ctx context.Context, userID int, f entity.ListFilter) ([]entity.Order, error)
+	FindByNumber(ctx context.Context, userID int, num string) (entity.OrderDetail, error)
+	Delete(ctx context.Context, userID int, num string) error
 }
 
-func NewOrder(r OrderRepository, q chan<- entity.StatusCheckJob) *Order {
+type StatusCheckQueue interface {
+	Enqueue(j entity.StatusCheckJob)
+}
+
+func NewOrder(r OrderRepository, q StatusCheckQueue) *Order {
 	return &Order{
 		repository: r,
 		queue:      q,
@@ -28,14 +35,55 @@ func (s *Order) Create(ctx context.Context, userID int, num string) error {
 		return err
 	}
 
-	go func() {
-		s.queue <- entity.NewStatusCheckJob(num)
-	}()
+	s.queue.Enqueue(entity.NewStatusCheckJob(num))
 
 	return nil
 }
 
-// GetAll возвращает список добавленных заказов пользователя.
-func (s *Order) GetAll(ctx context.Context, userID int) ([]entity.Order, error) {
-	return s.repository.FindAllByUserID(ctx, userID)
+// CreateBatch добавляет заказы с номерами nums и создает задачи на проверку статуса начисления
+// по принятым заказам. Возвращает результат загрузки каждого номера в порядке nums.
+func (s *Order) CreateBatch(ctx context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error) {
+	results, err := s.repository.CreateBatch(ctx, userID, nums)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, res := range results {
+		if res.Status == entity.OrderUploadAccepted {
+			s.queue.Enqueue(entity.NewStatusCheckJob(res.Number))
+		}
+	}
+
+	return results, nil
+}
+
+// GetAll возвращает страницу списка добавленных заказов пользователя и курсор следующей
+// страницы или пустую строку, если страница последняя. Если f.Limit не задан, возвращаются
+// все заказы, подходящие под условия f.
+func (s *Order) GetAll(ctx context.Context, userID int, f entity.ListFilter) ([]entity.Order, string, error) {
+	f, limit := pageFilter(f)
+	orders, err := s.repository.FindAllByUserID(ctx, userID, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	orders, next := page(orders, limit, func(o entity.Order) entity.Cursor {
+		return entity.Cursor{Time: o.UploadedAt, ID: o.ID}
+	})
+
+	return orders, next, nil
+}
+
+// Get возвращает заказ пользователя с историей изменения статуса и записями журнала операций.
+// Если заказ не найден или загружен другим пользователем, возвращает ошибку errors.ErrOrderNotFound.
+func (s *Order) Get(ctx context.Context, userID int, num string) (entity.OrderDetail, error) {
+	return s.repository.FindByNumber(ctx, userID, num)
+}
+
+// Delete отменяет необработанный заказ пользователя, освобождая его номер. Задача на проверку
+// статуса начисления по заказу отбрасывается воркером при следующей проверке. Если заказ
+// не найден или загружен другим пользователем, возвращает ошибку errors.ErrOrderNotFound, если
+// заказ уже обработан - errors.ErrOrderNotCancellable.
+func (s *Order) Delete(ctx context.Context, userID int, num string) error {
+	return s.repository.Delete(ctx, userID, num)
 }
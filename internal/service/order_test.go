@@ -21,12 +21,38 @@ func (m *OrderRepositoryMock) Create(_ context.Context, userID int, num string)
 	return args.Error(0)
 }
 
-func (m *OrderRepositoryMock) FindAllByUserID(_ context.Context, userID int) ([]entity.Order, error) {
-	args := m.Called(userID)
+func (m *OrderRepositoryMock) CreateBatch(_ context.Context, userID int, nums []string) ([]entity.OrderUploadResult, error) {
+	args := m.Called(userID, nums)
+
+	return args.Get(0).([]entity.OrderUploadResult), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) FindAllByUserID(_ context.Context, userID int, f entity.ListFilter) ([]entity.Order, error) {
+	args := m.Called(userID, f)
 
 	return args.Get(0).([]entity.Order), args.Error(1)
 }
 
+func (m *OrderRepositoryMock) FindByNumber(_ context.Context, userID int, num string) (entity.OrderDetail, error) {
+	args := m.Called(userID, num)
+
+	return args.Get(0).(entity.OrderDetail), args.Error(1)
+}
+
+func (m *OrderRepositoryMock) Delete(_ context.Context, userID int, num string) error {
+	args := m.Called(userID, num)
+
+	return args.Error(0)
+}
+
+type StatusCheckQueueMock struct {
+	mock.Mock
+}
+
+func (m *StatusCheckQueueMock) Enqueue(j entity.StatusCheckJob) {
+	m.Called(j)
+}
+
 func TestOrder_Create(t *testing.T) {
 	var (
 		ctx           = context.Background()
@@ -34,11 +60,9 @@ func TestOrder_Create(t *testing.T) {
 		num           = "166221614883769"
 		duplicatedNum = "267624438264306"
 		repository    = &OrderRepositoryMock{}
-		queue         = make(chan entity.StatusCheckJob, 1)
+		queue         = &StatusCheckQueueMock{}
 	)
 
-	defer close(queue)
-
 	repository.
 		On("Create", userID, num).
 		Return(nil).
@@ -47,6 +71,7 @@ func TestOrder_Create(t *testing.T) {
 		On("Create", userID, duplicatedNum).
 		Return(inerr.ErrOrderExists).
 		Once()
+	queue.On("Enqueue", entity.NewStatusCheckJob(num)).Once()
 	service := Order{
 		repository: repository,
 		queue:      queue,
@@ -57,12 +82,6 @@ func TestOrder_Create(t *testing.T) {
 		service.Create(ctx, userID, num),
 		"успешное добавление заказа",
 	)
-	assert.Equal(
-		t,
-		entity.NewStatusCheckJob(num),
-		<-queue,
-		"успешное добавление задачи для проверки статуса начисления",
-	)
 
 	assert.ErrorIs(
 		t,
@@ -70,15 +89,9 @@ func TestOrder_Create(t *testing.T) {
 		inerr.ErrOrderExists,
 		"ошибка при добавлении заказа",
 	)
-	assert.Never(
-		t,
-		func() bool { return len(queue) > 0 },
-		100*time.Millisecond,
-		20*time.Millisecond,
-		"задача для проверки статуса начисления не создается при ошибке",
-	)
 
 	repository.AssertExpectations(t)
+	queue.AssertExpectations(t)
 }
 
 func TestOrder_GetAll(t *testing.T) {
@@ -88,12 +101,14 @@ func TestOrder_GetAll(t *testing.T) {
 		errorUserID = 2
 		orders      = []entity.Order{
 			{
+				ID:         1,
 				Number:     "148561163482734",
 				Status:     entity.OrderStatusProcessing,
 				Accrual:    0,
 				UploadedAt: time.Now(),
 			},
 			{
+				ID:         2,
 				Number:     "267624438264306",
 				Status:     entity.OrderStatusProcessed,
 				Accrual:    100,
@@ -103,20 +118,104 @@ func TestOrder_GetAll(t *testing.T) {
 		repository = &OrderRepositoryMock{}
 	)
 	repository.
-		On("FindAllByUserID", userID).
+		On("FindAllByUserID", userID, entity.ListFilter{}).
 		Return(orders, nil).
 		Once()
 	repository.
-		On("FindAllByUserID", errorUserID).
+		On("FindAllByUserID", userID, entity.ListFilter{Limit: 2}).
+		Return(orders, nil).
+		Once()
+	repository.
+		On("FindAllByUserID", errorUserID, entity.ListFilter{}).
 		Return([]entity.Order{}, errors.New("")).
 		Once()
 	service := Order{repository: repository}
 
-	resOrders, _ := service.GetAll(ctx, userID)
+	resOrders, next, _ := service.GetAll(ctx, userID, entity.ListFilter{})
 	assert.Equal(t, orders, resOrders, "успешное получение списка заказов")
+	assert.Empty(t, next, "успешное получение списка заказов")
+
+	resOrders, next, _ = service.GetAll(ctx, userID, entity.ListFilter{Limit: 1})
+	assert.Equal(t, orders[:1], resOrders, "получение первой страницы заказов")
+	assert.Equal(t, entity.Cursor{Time: orders[0].UploadedAt, ID: orders[0].ID}.String(), next, "получение первой страницы заказов")
 
-	_, err := service.GetAll(ctx, errorUserID)
+	_, _, err := service.GetAll(ctx, errorUserID, entity.ListFilter{})
 	assert.Error(t, err, "ошибка при получении списка заказов")
 
 	repository.AssertExpectations(t)
 }
+
+func TestOrder_Get(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		userID     = 1
+		num        = "2377225624"
+		repository = &OrderRepositoryMock{}
+		order      = entity.OrderDetail{
+			Order:   entity.Order{Number: num, Status: entity.OrderStatusNew},
+			History: []entity.OrderStatusChange{{Status: entity.OrderStatusNew, ChangedAt: time.Now()}},
+		}
+	)
+	repository.On("FindByNumber", userID, num).Return(order, nil).Once()
+	repository.On("FindByNumber", userID, "").Return(entity.OrderDetail{}, inerr.ErrOrderNotFound).Once()
+	service := Order{repository: repository}
+
+	res, err := service.Get(ctx, userID, num)
+	assert.NoError(t, err)
+	assert.Equal(t, order, res)
+
+	_, err = service.Get(ctx, userID, "")
+	assert.ErrorIs(t, err, inerr.ErrOrderNotFound)
+	repository.AssertExpectations(t)
+}
+
+func TestOrder_CreateBatch(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		userID     = 1
+		nums       = []string{"148561163482734", "267624438264306", "2377225624"}
+		repository = &OrderRepositoryMock{}
+		queue      = &StatusCheckQueueMock{}
+		results    = []entity.OrderUploadResult{
+			{Number: nums[0], Status: entity.OrderUploadAccepted},
+			{Number: nums[1], Status: entity.OrderUploadAlreadyUploaded},
+			{Number: nums[2], Status: entity.OrderUploadConflict},
+		}
+	)
+	repository.On("CreateBatch", userID, nums).Return(results, nil).Once()
+	repository.On("CreateBatch", userID, nums[:1]).Return([]entity.OrderUploadResult{}, errors.New("")).Once()
+	queue.On("Enqueue", entity.NewStatusCheckJob(nums[0])).Once()
+	service := Order{
+		repository: repository,
+		queue:      queue,
+	}
+
+	res, err := service.CreateBatch(ctx, userID, nums)
+	assert.NoError(t, err, "успешное добавление пакета заказов")
+	assert.Equal(t, results, res, "успешное добавление пакета заказов")
+
+	_, err = service.CreateBatch(ctx, userID, nums[:1])
+	assert.Error(t, err, "ошибка при добавлении пакета заказов")
+
+	repository.AssertExpectations(t)
+	queue.AssertExpectations(t)
+}
+
+func TestOrder_Delete(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		userID     = 1
+		num        = "2377225624"
+		processed  = "12345678903"
+		repository = &OrderRepositoryMock{}
+		queue      = &StatusCheckQueueMock{}
+	)
+	repository.On("Delete", userID, num).Return(nil).Once()
+	repository.On("Delete", userID, processed).Return(inerr.ErrOrderNotCancellable).Once()
+	service := NewOrder(repository, queue)
+
+	assert.NoError(t, service.Delete(ctx, userID, num), "заказ отменен")
+	assert.ErrorIs(t, service.Delete(ctx, userID, processed), inerr.ErrOrderNotCancellable, "заказ уже обработан")
+	repository.AssertExpectations(t)
+	queue.AssertExpectations(t)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+)
+
+type Outbox struct {
+	repository OutboxRepository
+	sink       OutboxSink
+}
+
+type OutboxRepository interface {
+	FindUnpublished(ctx context.Context, limit int) ([]entity.OutboxEvent, error)
+	MarkPublished(ctx context.Context, ids []int) error
+}
+
+type OutboxSink interface {
+	Publish(ctx context.Context, e entity.OutboxEvent) error
+}
+
+const outboxBatchSize = 100
+
+func NewOutbox(r OutboxRepository, s OutboxSink) *Outbox {
+	return &Outbox{
+		repository: r,
+		sink:       s,
+	}
+}
+
+// PublishPending публикует неопубликованные события в порядке их записи пакетами по
+// outboxBatchSize, пока не будут опубликованы все, и отмечает опубликованные. При ошибке
+// публикации останавливается, чтобы не нарушать порядок событий: оставшиеся события будут
+// опубликованы при следующем вызове. Событие может быть опубликовано повторно, если отметить
+// его не удалось. Возвращает количество опубликованных событий.
+func (s *Outbox) PublishPending(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, more, err := s.publishBatch(ctx)
+		total += n
+		if err != nil || !more || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
+
+// publishBatch публикует пакет неопубликованных событий. Возвращает количество опубликованных
+// событий и true, если пакет заполнен и могут остаться неопубликованные события.
+func (s *Outbox) publishBatch(ctx context.Context) (int, bool, error) {
+	events, err := s.repository.FindUnpublished(ctx, outboxBatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+
+	published := make([]int, 0, len(events))
+	for _, e := range events {
+		if err = s.sink.Publish(ctx, e); err != nil {
+			break
+		}
+
+		published = append(published, e.ID)
+	}
+
+	if len(published) > 0 {
+		if merr := s.repository.MarkPublished(ctx, published); merr != nil {
+			return 0, false, merr
+		}
+	}
+
+	return len(published), len(events) == outboxBatchSize, err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *OutboxRepositoryMock) FindUnpublished(_ context.Context, limit int) ([]entity.OutboxEvent, error) {
+	args := m.Called(limit)
+
+	return args.Get(0).([]entity.OutboxEvent), args.Error(1)
+}
+
+func (m *OutboxRepositoryMock) MarkPublished(_ context.Context, ids []int) error {
+	args := m.Called(ids)
+
+	return args.Error(0)
+}
+
+type OutboxSinkMock struct {
+	mock.Mock
+}
+
+func (m *OutboxSinkMock) Publish(_ context.Context, e entity.OutboxEvent) error {
+	args := m.Called(e)
+
+	return args.Error(0)
+}
+
+func TestOutbox_PublishPending(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		events = []entity.OutboxEvent{
+			{ID: 1, Type: entity.OutboxEventOrderCreated},
+			{ID: 2, Type: entity.OutboxEventOrderStatusUpdated},
+			{ID: 3, Type: entity.OutboxEventPointsWithdrawn},
+		}
+		publishErr = errors.New("connection refused")
+		repository = &OutboxRepositoryMock{}
+		sink       = &OutboxSinkMock{}
+		empty      = &OutboxRepositoryMock{}
+		backlog    = &OutboxRepositoryMock{}
+		full       = make([]entity.OutboxEvent, outboxBatchSize)
+		fullIDs    = make([]int, outboxBatchSize)
+	)
+	for i := range full {
+		full[i] = entity.OutboxEvent{ID: 10 + i, Type: entity.OutboxEventOrderCreated}
+		fullIDs[i] = full[i].ID
+	}
+
+	repository.On("FindUnpublished", outboxBatchSize).Return(events, nil).Once()
+	sink.On("Publish", events[0]).Return(nil).Once()
+	sink.On("Publish", events[1]).Return(publishErr).Once()
+	repository.On("MarkPublished", []int{1}).Return(nil).Once()
+	empty.On("FindUnpublished", outboxBatchSize).Return([]entity.OutboxEvent(nil), nil).Once()
+	backlog.On("FindUnpublished", outboxBatchSize).Return(full, nil).Once()
+	backlog.On("MarkPublished", fullIDs).Return(nil).Once()
+	backlog.On("FindUnpublished", outboxBatchSize).Return(events[:1], nil).Once()
+	backlog.On("MarkPublished", []int{1}).Return(nil).Once()
+	sink.On("Publish", mock.MatchedBy(func(e entity.OutboxEvent) bool { return e.ID >= 10 })).Return(nil).Times(outboxBatchSize)
+	sink.On("Publish", events[0]).Return(nil).Once()
+
+	n, err := NewOutbox(repository, sink).PublishPending(ctx)
+	assert.ErrorIs(t, err, publishErr, "публикация остановлена на первой ошибке")
+	assert.Equal(t, 1, n, "опубликованное событие отмечено")
+
+	n, err = NewOutbox(empty, sink).PublishPending(ctx)
+	assert.NoError(t, err, "нет событий")
+	assert.Equal(t, 0, n, "нет событий")
+
+	n, err = NewOutbox(backlog, sink).PublishPending(ctx)
+	assert.NoError(t, err, "публикация до последнего неполного пакета")
+	assert.Equal(t, outboxBatchSize+1, n, "публикация до последнего неполного пакета")
+
+	repository.AssertExpectations(t)
+	sink.AssertExpectations(t)
+	empty.AssertExpectations(t)
+	backlog.AssertExpectations(t)
+}
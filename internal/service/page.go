@@ -0,0 +1,34 @@
+package service
+
+import "github.com/ivanpodgorny/gophermart/internal/entity"
+
+// maxPageLimit - максимальное количество элементов на странице списка.
+const maxPageLimit = 500
+
+// pageFilter ограничивает количество элементов на странице значением maxPageLimit и возвращает
+// фильтр, по которому запрашивается на один элемент больше, чтобы определить, есть ли следующая
+// страница, и ограниченное количество элементов.
+func pageFilter(f entity.ListFilter) (entity.ListFilter, int) {
+	if f.Limit > maxPageLimit {
+		f.Limit = maxPageLimit
+	}
+
+	limit := f.Limit
+	if limit > 0 {
+		f.Limit++
+	}
+
+	return f, limit
+}
+
+// page обрезает элементы, полученные по фильтру pageFilter, до limit и возвращает курсор
+// следующей страницы или пустую строку, если страница последняя.
+func page[T any](items []T, limit int, cursor func(T) entity.Cursor) ([]T, string) {
+	if limit == 0 || len(items) <= limit {
+		return items, ""
+	}
+
+	items = items[:limit]
+
+	return items, cursor(items[limit-1]).String()
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/ivanpodgorny/gophermart/internal/security"
+)
+
+type Partner struct {
+	repository PartnerRepository
+}
+
+type PartnerRepository interface {
+	Create(ctx context.Context, p entity.Partner) (entity.Partner, error)
+	FindAll(ctx context.Context) ([]entity.Partner, error)
+	Delete(ctx context.Context, id int) error
+}
+
+const partnerKeySize = 32
+
+func NewPartner(r PartnerRepository) *Partner {
+	return &Partner{repository: r}
+}
+
+// Create создает партнера p со случайным ключом API. Сохраняется только хэш ключа, сам ключ
+// возвращается только в ответе на этот вызов.
+func (s *Partner) Create(ctx context.Context, p entity.Partner) (entity.Partner, error) {
+	key, err := security.RandomString(partnerKeySize)
+	if err != nil {
+		return entity.Partner{}, err
+	}
+
+	p.KeyHash = security.HashKey(key)
+	if p, err = s.repository.Create(ctx, p); err != nil {
+		return entity.Partner{}, err
+	}
+
+	p.Key = key
+
+	return p, nil
+}
+
+// GetAll возвращает всех партнеров.
+func (s *Partner) GetAll(ctx context.Context) ([]entity.Partner, error) {
+	return s.repository.FindAll(ctx)
+}
+
+// Delete удаляет партнера, после чего его ключ API перестает действовать.
+func (s *Partner) Delete(ctx context.Context, id int) error {
+	return s.repository.Delete(ctx, id)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/ivanpodgorny/gophermart/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type PartnerRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *PartnerRepositoryMock) Create(_ context.Context, p entity.Partner) (entity.Partner, error) {
+	args := m.Called(p)
+
+	return args.Get(0).(entity.Partner), args.Error(1)
+}
+
+func (m *PartnerRepositoryMock) FindAll(_ context.Context) ([]entity.Partner, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.Partner), args.Error(1)
+}
+
+func (m *PartnerRepositoryMock) Delete(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func TestPartner_Create(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		repository = &PartnerRepositoryMock{}
+		keyHash    = ""
+	)
+
+	repository.
+		On("Create", mock.MatchedBy(func(p entity.Partner) bool {
+			keyHash = p.KeyHash
+
+			return p.Name == "shop" && len(p.UserIDs) == 2 && p.Key == "" && p.KeyHash != ""
+		})).
+		Return(entity.Partner{ID: 1, Name: "shop", UserIDs: []int{1, 2}}, nil).
+		Once()
+
+	p, err := NewPartner(repository).Create(ctx, entity.Partner{Name: "shop", UserIDs: []int{1, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.ID)
+	assert.Len(t, p.Key, partnerKeySize, "ключ возвращается при создании")
+	assert.Equal(t, security.HashKey(p.Key), keyHash, "сохраняется только хэш ключа")
+	repository.AssertExpectations(t)
+}
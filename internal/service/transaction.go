@@ -3,33 +3,220 @@ package service
 import (
 	"context"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"strconv"
+	"time"
 )
 
 type Transaction struct {
 	repository TransactionRepository
+	holds      HoldRepository
+	lots       LotRepository
+	holdTTL    time.Duration
 }
 
 type TransactionRepository interface {
-	GetBalance(ctx context.Context, userID int) (current float64, withdrawn float64, err error)
-	Create(ctx context.Context, userID int, order string, sum float64, t entity.TransactionType) error
-	FindAllByUserID(ctx context.Context, userID int, t entity.TransactionType) ([]entity.Transaction, error)
+	GetBalance(ctx context.Context, userID int) (entity.Balance, error)
+	Create(ctx context.Context, userID int, order string, sum entity.Amount, t entity.TransactionType) error
+	FindAllByUserID(ctx context.Context, userID int, t entity.TransactionType, f entity.ListFilter) ([]entity.Transaction, error)
+	FindStatement(ctx context.Context, userID int, f entity.StatementFilter) ([]entity.StatementEntry, error)
+	GetBalanceAt(ctx context.Context, userID int, t time.Time) (entity.Amount, error)
+	Refund(ctx context.Context, scope entity.PartnerScope, order string, sum *entity.Amount) (entity.Transaction, error)
 }
 
-func NewTransaction(r TransactionRepository) *Transaction {
-	return &Transaction{repository: r}
+type HoldRepository interface {
+	Create(ctx context.Context, userID int, order string, sum entity.Amount, expiresAt time.Time) (entity.Hold, error)
+	Capture(ctx context.Context, userID, id int, now time.Time) (entity.Hold, error)
+	Void(ctx context.Context, userID, id int) (entity.Hold, error)
+	ExpireDue(ctx context.Context, now time.Time, limit int) (int, error)
+	FindAllByUserID(ctx context.Context, userID int) ([]entity.Hold, error)
 }
 
-// GetBalance возвращает данные о доступных и списанных баллах пользователя.
-func (s *Transaction) GetBalance(ctx context.Context, userID int) (float64, float64, error) {
-	return s.repository.GetBalance(ctx, userID)
+type LotRepository interface {
+	ExpireDue(ctx context.Context, now time.Time, limit int) (int, error)
+	FindUpcoming(ctx context.Context, userID int, until time.Time) ([]entity.Expiration, error)
+}
+
+const (
+	expireBatchSize = 100
+	// expirationHorizon - период, за который в балансе отображаются истекающие баллы.
+	expirationHorizon = 30 * 24 * time.Hour
+	// defaultStatementLimit и maxStatementLimit - количество операций на странице выписки
+	// по умолчанию и максимальное.
+	defaultStatementLimit = 50
+	maxStatementLimit     = 500
+)
+
+// NewTransaction возвращает сервис операций с баллами. Резервы баллов действуют в течение holdTTL.
+func NewTransaction(r TransactionRepository, h HoldRepository, l LotRepository, holdTTL time.Duration) *Transaction {
+	return &Transaction{
+		repository: r,
+		holds:      h,
+		lots:       l,
+		holdTTL:    holdTTL,
+	}
+}
+
+// GetBalance возвращает данные о доступных, зарезервированных и списанных баллах пользователя,
+// а также о баллах, срок действия которых истекает в ближайшие 30 дней.
+func (s *Transaction) GetBalance(ctx context.Context, userID int) (entity.Balance, error) {
+	b, err := s.repository.GetBalance(ctx, userID)
+	if err != nil {
+		return b, err
+	}
+
+	b.Expirations, err = s.lots.FindUpcoming(ctx, userID, time.Now().Add(expirationHorizon))
+
+	return b, err
 }
 
 // Withdraw создает списание баллов в счёт оплаты заказа.
-func (s *Transaction) Withdraw(ctx context.Context, userID int, order string, sum float64) error {
+func (s *Transaction) Withdraw(ctx context.Context, userID int, order string, sum entity.Amount) error {
 	return s.repository.Create(ctx, userID, order, sum, entity.TransactionTypeOut)
 }
 
-// GetWithdrawals возвращает список всех списаний пользователя.
-func (s *Transaction) GetWithdrawals(ctx context.Context, userID int) ([]entity.Transaction, error) {
-	return s.repository.FindAllByUserID(ctx, userID, entity.TransactionTypeOut)
+// GetWithdrawals возвращает страницу списка списаний пользователя и курсор следующей страницы
+// или пустую строку, если страница последняя. Если f.Limit не задан, возвращаются все списания,
+// подходящие под условия f.
+func (s *Transaction) GetWithdrawals(ctx context.Context, userID int, f entity.ListFilter) ([]entity.Transaction, string, error) {
+	f, limit := pageFilter(f)
+	txs, err := s.repository.FindAllByUserID(ctx, userID, entity.TransactionTypeOut, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	txs, next := page(txs, limit, func(tx entity.Transaction) entity.Cursor {
+		return entity.Cursor{Time: tx.ProcessedAt, ID: tx.ID}
+	})
+
+	return txs, next, nil
+}
+
+// GetStatement возвращает страницу выписки по счёту пользователя: начисления, списания, возвраты
+// и другие операции, изменившие доступный баланс, с балансом после каждой операции. По умолчанию
+// на странице 50 операций, f.Limit больше 500 уменьшается до 500.
+func (s *Transaction) GetStatement(ctx context.Context, userID int, f entity.StatementFilter) (entity.Statement, error) {
+	switch {
+	case f.Limit <= 0:
+		f.Limit = defaultStatementLimit
+	case f.Limit > maxStatementLimit:
+		f.Limit = maxStatementLimit
+	}
+
+	limit := f.Limit
+	// Запрашивается на одну операцию больше, чтобы определить, есть ли следующая страница.
+	f.Limit++
+	entries, err := s.repository.FindStatement(ctx, userID, f)
+	if err != nil {
+		return entity.Statement{}, err
+	}
+
+	st := entity.Statement{Entries: entries}
+	if len(entries) > limit {
+		st.Entries = entries[:limit]
+		st.NextCursor = strconv.Itoa(st.Entries[limit-1].ID)
+	}
+
+	return st, nil
+}
+
+// ExportStatement возвращает все операции выписки по счёту пользователя за период, заданный f.From
+// и f.To. Операции запрашиваются страницами, f.After и f.Limit не учитываются.
+func (s *Transaction) ExportStatement(ctx context.Context, userID int, f entity.StatementFilter) ([]entity.StatementEntry, error) {
+	var entries []entity.StatementEntry
+	f.After, f.Limit = 0, maxStatementLimit
+	for {
+		page, err := s.repository.FindStatement(ctx, userID, f)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page...)
+		if len(page) < f.Limit {
+			return entries, nil
+		}
+
+		f.After = page[len(page)-1].ID
+	}
+}
+
+// GetMonthlyStatement возвращает выписку по счёту пользователя за месяц, в который входит момент
+// month, с балансами на начало и конец месяца.
+func (s *Transaction) GetMonthlyStatement(ctx context.Context, userID int, month time.Time) (entity.MonthlyStatement, error) {
+	st := entity.MonthlyStatement{
+		Month: time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location()),
+	}
+
+	var err error
+	if st.Opening, err = s.repository.GetBalanceAt(ctx, userID, st.Month); err != nil {
+		return st, err
+	}
+
+	st.Entries, err = s.ExportStatement(ctx, userID, entity.StatementFilter{From: st.Month, To: st.Month.AddDate(0, 1, 0)})
+	if err != nil {
+		return st, err
+	}
+
+	st.Closing = st.Opening
+	for _, e := range st.Entries {
+		st.Closing += e.Sum
+	}
+
+	return st, nil
+}
+
+// Refund возвращает пользователю из scope баллы, списанные в счёт оплаты заказа order. Если sum
+// равна nil, возвращается вся оставшаяся сумма списания.
+func (s *Transaction) Refund(
+	ctx context.Context,
+	scope entity.PartnerScope,
+	order string,
+	sum *entity.Amount,
+) (entity.Transaction, error) {
+	return s.repository.Refund(ctx, scope, order, sum)
+}
+
+// Hold резервирует баллы в счёт оплаты заказа. Зарезервированные баллы недоступны для других
+// операций, но не считаются списанными, пока резерв не будет списан методом Capture.
+// Не списанный резерв автоматически отменяется по истечении срока действия.
+func (s *Transaction) Hold(ctx context.Context, userID int, order string, sum entity.Amount) (entity.Hold, error) {
+	return s.holds.Create(ctx, userID, order, sum, time.Now().Add(s.holdTTL))
+}
+
+// Capture списывает зарезервированные баллы.
+func (s *Transaction) Capture(ctx context.Context, userID, id int) (entity.Hold, error) {
+	return s.holds.Capture(ctx, userID, id, time.Now())
+}
+
+// Void отменяет резерв и возвращает баллы пользователю.
+func (s *Transaction) Void(ctx context.Context, userID, id int) (entity.Hold, error) {
+	return s.holds.Void(ctx, userID, id)
+}
+
+// GetHolds возвращает список всех резервов пользователя.
+func (s *Transaction) GetHolds(ctx context.Context, userID int) ([]entity.Hold, error) {
+	return s.holds.FindAllByUserID(ctx, userID)
+}
+
+// ExpireHolds отменяет все резервы с истекшим сроком действия и возвращает их количество.
+func (s *Transaction) ExpireHolds(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := s.holds.ExpireDue(ctx, time.Now(), expireBatchSize)
+		total += n
+		if err != nil || n < expireBatchSize {
+			return total, err
+		}
+	}
+}
+
+// ExpirePoints списывает баллы с истекшим сроком действия и возвращает количество истекших партий.
+func (s *Transaction) ExpirePoints(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := s.lots.ExpireDue(ctx, time.Now(), expireBatchSize)
+		total += n
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
 }
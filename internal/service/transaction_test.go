@@ -15,45 +15,132 @@ type TransactionRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *TransactionRepositoryMock) GetBalance(_ context.Context, userID int) (float64, float64, error) {
+func (m *TransactionRepositoryMock) GetBalance(_ context.Context, userID int) (entity.Balance, error) {
 	args := m.Called(userID)
 
-	return args.Get(0).(float64), args.Get(1).(float64), args.Error(2)
+	return args.Get(0).(entity.Balance), args.Error(1)
 }
 
-func (m *TransactionRepositoryMock) Create(_ context.Context, userID int, order string, sum float64, t entity.TransactionType) error {
+func (m *TransactionRepositoryMock) Create(_ context.Context, userID int, order string, sum entity.Amount, t entity.TransactionType) error {
 	args := m.Called(userID, order, sum, t)
 
 	return args.Error(0)
 }
 
-func (m *TransactionRepositoryMock) FindAllByUserID(_ context.Context, userID int, t entity.TransactionType) ([]entity.Transaction, error) {
-	args := m.Called(userID, t)
+func (m *TransactionRepositoryMock) FindAllByUserID(
+	_ context.Context,
+	userID int,
+	t entity.TransactionType,
+	f entity.ListFilter,
+) ([]entity.Transaction, error) {
+	args := m.Called(userID, t, f)
 
 	return args.Get(0).([]entity.Transaction), args.Error(1)
 }
 
+func (m *TransactionRepositoryMock) FindStatement(_ context.Context, userID int, f entity.StatementFilter) ([]entity.StatementEntry, error) {
+	args := m.Called(userID, f)
+
+	return args.Get(0).([]entity.StatementEntry), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) GetBalanceAt(_ context.Context, userID int, t time.Time) (entity.Amount, error) {
+	args := m.Called(userID, t)
+
+	return args.Get(0).(entity.Amount), args.Error(1)
+}
+
+func (m *TransactionRepositoryMock) Refund(
+	_ context.Context,
+	scope entity.PartnerScope,
+	order string,
+	sum *entity.Amount,
+) (entity.Transaction, error) {
+	args := m.Called(scope, order, sum)
+
+	return args.Get(0).(entity.Transaction), args.Error(1)
+}
+
+type HoldRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *HoldRepositoryMock) Create(_ context.Context, userID int, order string, sum entity.Amount, expiresAt time.Time) (entity.Hold, error) {
+	args := m.Called(userID, order, sum, expiresAt)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *HoldRepositoryMock) Capture(_ context.Context, userID, id int, now time.Time) (entity.Hold, error) {
+	args := m.Called(userID, id, now)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *HoldRepositoryMock) Void(_ context.Context, userID, id int) (entity.Hold, error) {
+	args := m.Called(userID, id)
+
+	return args.Get(0).(entity.Hold), args.Error(1)
+}
+
+func (m *HoldRepositoryMock) ExpireDue(_ context.Context, now time.Time, limit int) (int, error) {
+	args := m.Called(now, limit)
+
+	return args.Int(0), args.Error(1)
+}
+
+func (m *HoldRepositoryMock) FindAllByUserID(_ context.Context, userID int) ([]entity.Hold, error) {
+	args := m.Called(userID)
+
+	return args.Get(0).([]entity.Hold), args.Error(1)
+}
+
+type LotRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *LotRepositoryMock) ExpireDue(_ context.Context, now time.Time, limit int) (int, error) {
+	args := m.Called(now, limit)
+
+	return args.Int(0), args.Error(1)
+}
+
+func (m *LotRepositoryMock) FindUpcoming(_ context.Context, userID int, until time.Time) ([]entity.Expiration, error) {
+	args := m.Called(userID, until)
+
+	return args.Get(0).([]entity.Expiration), args.Error(1)
+}
+
 func TestTransaction_GetBalance(t *testing.T) {
 	var (
 		ctx         = context.Background()
 		userID      = 1
 		wrongUserID = 2
-		current     = 500.5
-		withdrawn   = 42.0
+		balance     = entity.Balance{Current: 500_50, Held: 10_00, Withdrawn: 42_00}
+		expirations = []entity.Expiration{{Sum: 100_00, ExpiresAt: time.Now().Add(time.Hour)}}
 		repository  = &TransactionRepositoryMock{}
+		lots        = &LotRepositoryMock{}
+		before      = time.Now()
 	)
-	repository.On("GetBalance", userID).Return(current, withdrawn, nil).Once()
-	repository.On("GetBalance", wrongUserID).Return(0.0, 0.0, errors.New("")).Once()
-	service := Transaction{repository: repository}
+	repository.On("GetBalance", userID).Return(balance, nil).Once()
+	repository.On("GetBalance", wrongUserID).Return(entity.Balance{}, errors.New("")).Once()
+	lots.
+		On("FindUpcoming", userID, mock.MatchedBy(func(until time.Time) bool {
+			return !until.Before(before.Add(expirationHorizon)) && !until.After(time.Now().Add(expirationHorizon))
+		})).
+		Return(expirations, nil).
+		Once()
+	service := Transaction{repository: repository, lots: lots}
 
-	resCurrent, resWithdrawn, _ := service.GetBalance(ctx, userID)
-	assert.Equal(t, current, resCurrent, "успешное получение текущей суммы баллов")
-	assert.Equal(t, withdrawn, resWithdrawn, "успешное получение суммы использованных баллов")
+	res, _ := service.GetBalance(ctx, userID)
+	balance.Expirations = expirations
+	assert.Equal(t, balance, res, "успешное получение баланса")
 
-	_, _, err := service.GetBalance(ctx, wrongUserID)
+	_, err := service.GetBalance(ctx, wrongUserID)
 	assert.Error(t, err, "ошибка при получении баланса пользователя")
 
 	repository.AssertExpectations(t)
+	lots.AssertExpectations(t)
 }
 
 func TestTransaction_Withdraw(t *testing.T) {
@@ -61,8 +148,8 @@ func TestTransaction_Withdraw(t *testing.T) {
 		ctx            = context.Background()
 		userID         = 1
 		order          = "166221614883769"
-		sum            = 100.0
-		unavailableSum = 1000.0
+		sum            = entity.Amount(100_00)
+		unavailableSum = entity.Amount(1000_00)
 		repository     = &TransactionRepositoryMock{}
 	)
 	repository.
@@ -110,20 +197,213 @@ func TestTransaction_GetWithdrawals(t *testing.T) {
 		repository = &TransactionRepositoryMock{}
 	)
 	repository.
-		On("FindAllByUserID", userID, entity.TransactionTypeOut).
+		On("FindAllByUserID", userID, entity.TransactionTypeOut, entity.ListFilter{}).
 		Return(transactions, nil).
 		Once()
 	repository.
-		On("FindAllByUserID", errorUserID, entity.TransactionTypeOut).
+		On("FindAllByUserID", userID, entity.TransactionTypeOut, entity.ListFilter{Descending: true, Limit: maxPageLimit + 1}).
+		Return(transactions, nil).
+		Once()
+	repository.
+		On("FindAllByUserID", errorUserID, entity.TransactionTypeOut, entity.ListFilter{}).
 		Return([]entity.Transaction{}, errors.New("")).
 		Once()
 	service := Transaction{repository: repository}
 
-	resTransactions, _ := service.GetWithdrawals(ctx, userID)
+	resTransactions, next, _ := service.GetWithdrawals(ctx, userID, entity.ListFilter{})
 	assert.Equal(t, transactions, resTransactions, "успешное получение списаний")
+	assert.Empty(t, next, "успешное получение списаний")
 
-	_, err := service.GetWithdrawals(ctx, errorUserID)
+	resTransactions, next, _ = service.GetWithdrawals(ctx, userID, entity.ListFilter{Descending: true, Limit: maxPageLimit + 100})
+	assert.Equal(t, transactions, resTransactions, "количество списаний на странице ограничено")
+	assert.Empty(t, next, "последняя страница списаний")
+
+	_, _, err := service.GetWithdrawals(ctx, errorUserID, entity.ListFilter{})
 	assert.Error(t, err, "ошибка при получении списаний")
 
 	repository.AssertExpectations(t)
 }
+
+func TestTransaction_GetStatement(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		userID     = 1
+		from       = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		repository = &TransactionRepositoryMock{}
+		entries    = []entity.StatementEntry{
+			{ID: 11, Type: entity.TransactionTypeIn, Sum: 100_00, Balance: 100_00},
+			{ID: 12, Type: entity.TransactionTypeOut, Sum: -30_00, Balance: 70_00},
+			{ID: 13, Type: entity.TransactionTypeRefund, Sum: 10_00, Balance: 80_00},
+		}
+	)
+	repository.
+		On("FindStatement", userID, entity.StatementFilter{From: from, Limit: 3}).
+		Return(entries, nil).
+		Once()
+	repository.
+		On("FindStatement", userID, entity.StatementFilter{After: 12, Limit: defaultStatementLimit + 1}).
+		Return(entries[2:], nil).
+		Once()
+	repository.
+		On("FindStatement", userID, entity.StatementFilter{Limit: maxStatementLimit + 1}).
+		Return([]entity.StatementEntry{}, errors.New("")).
+		Once()
+	service := Transaction{repository: repository}
+
+	res, err := service.GetStatement(ctx, userID, entity.StatementFilter{From: from, Limit: 2})
+	assert.NoError(t, err, "первая страница выписки")
+	assert.Equal(t, entity.Statement{Entries: entries[:2], NextCursor: "12"}, res, "первая страница выписки")
+
+	res, err = service.GetStatement(ctx, userID, entity.StatementFilter{After: 12})
+	assert.NoError(t, err, "последняя страница выписки")
+	assert.Equal(t, entity.Statement{Entries: entries[2:]}, res, "последняя страница выписки")
+
+	_, err = service.GetStatement(ctx, userID, entity.StatementFilter{Limit: maxStatementLimit + 10})
+	assert.Error(t, err, "ошибка репозитория")
+
+	repository.AssertExpectations(t)
+}
+
+func TestTransaction_GetMonthlyStatement(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		userID     = 1
+		month      = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		next       = time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+		repository = &TransactionRepositoryMock{}
+		page       = make([]entity.StatementEntry, maxStatementLimit)
+		last       = []entity.StatementEntry{{ID: maxStatementLimit + 1, Type: entity.TransactionTypeOut, Sum: -30_00}}
+	)
+	for i := range page {
+		page[i] = entity.StatementEntry{ID: i + 1, Type: entity.TransactionTypeIn, Sum: 1_00}
+	}
+	repository.On("GetBalanceAt", userID, month).Return(entity.Amount(100_00), nil).Once()
+	repository.
+		On("FindStatement", userID, entity.StatementFilter{From: month, To: next, Limit: maxStatementLimit}).
+		Return(page, nil).
+		Once()
+	repository.
+		On("FindStatement", userID, entity.StatementFilter{From: month, To: next, After: maxStatementLimit, Limit: maxStatementLimit}).
+		Return(last, nil).
+		Once()
+	service := Transaction{repository: repository}
+
+	res, err := service.GetMonthlyStatement(ctx, userID, time.Date(2023, 1, 17, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, month, res.Month)
+	assert.Equal(t, entity.Amount(100_00), res.Opening)
+	assert.Equal(t, entity.Amount(100_00+maxStatementLimit*1_00-30_00), res.Closing)
+	assert.Len(t, res.Entries, maxStatementLimit+1, "операции со всех страниц")
+
+	repository.AssertExpectations(t)
+}
+
+func TestTransaction_Refund(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		order      = "2377225624"
+		sum        = entity.Amount(10_00)
+		refunded   = entity.Transaction{Order: order, Sum: 50_00, Refunded: 10_00, RefundStatus: entity.RefundStatusPartial}
+		scope      = entity.PartnerScope{UserIDs: []int{1}}
+		repository = &TransactionRepositoryMock{}
+	)
+	repository.On("Refund", scope, order, &sum).Return(refunded, nil).Once()
+	repository.On("Refund", scope, order, (*entity.Amount)(nil)).Return(entity.Transaction{}, inerr.ErrRefundExceedsSum).Once()
+	service := Transaction{repository: repository}
+
+	res, err := service.Refund(ctx, scope, order, &sum)
+	assert.NoError(t, err, "успешный возврат баллов")
+	assert.Equal(t, refunded, res, "успешный возврат баллов")
+
+	_, err = service.Refund(ctx, scope, order, nil)
+	assert.ErrorIs(t, err, inerr.ErrRefundExceedsSum, "ошибка при возврате баллов")
+	repository.AssertExpectations(t)
+}
+
+func TestTransaction_Hold(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		userID  = 1
+		order   = "2377225624"
+		sum     = entity.Amount(30_00)
+		ttl     = 30 * time.Minute
+		hold    = entity.Hold{ID: 7, Order: order, Sum: sum, Status: entity.HoldStatusActive}
+		holds   = &HoldRepositoryMock{}
+		service = NewTransaction(&TransactionRepositoryMock{}, holds, &LotRepositoryMock{}, ttl)
+		before  = time.Now()
+	)
+	holds.
+		On("Create", userID, order, sum, mock.MatchedBy(func(expiresAt time.Time) bool {
+			return !expiresAt.Before(before.Add(ttl)) && !expiresAt.After(time.Now().Add(ttl))
+		})).
+		Return(hold, nil).
+		Once()
+
+	res, err := service.Hold(ctx, userID, order, sum)
+	assert.NoError(t, err, "успешное резервирование баллов")
+	assert.Equal(t, hold, res, "успешное резервирование баллов")
+	holds.AssertExpectations(t)
+}
+
+func TestTransaction_CaptureAndVoid(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		userID  = 1
+		id      = 7
+		holds   = &HoldRepositoryMock{}
+		service = NewTransaction(&TransactionRepositoryMock{}, holds, &LotRepositoryMock{}, time.Minute)
+	)
+	holds.
+		On("Capture", userID, id, mock.AnythingOfType("time.Time")).
+		Return(entity.Hold{ID: id, Status: entity.HoldStatusCaptured}, nil).
+		Once()
+	holds.On("Void", userID, id).Return(entity.Hold{}, inerr.ErrHoldNotActive).Once()
+
+	res, err := service.Capture(ctx, userID, id)
+	assert.NoError(t, err, "успешное списание резерва")
+	assert.Equal(t, entity.HoldStatusCaptured, res.Status, "успешное списание резерва")
+
+	_, err = service.Void(ctx, userID, id)
+	assert.ErrorIs(t, err, inerr.ErrHoldNotActive, "отмена списанного резерва")
+	holds.AssertExpectations(t)
+}
+
+func TestTransaction_ExpireHolds(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		holds   = &HoldRepositoryMock{}
+		service = NewTransaction(&TransactionRepositoryMock{}, holds, &LotRepositoryMock{}, time.Minute)
+	)
+	holds.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(expireBatchSize, nil).Once()
+	holds.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(3, nil).Once()
+	holds.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(0, errors.New("")).Once()
+
+	n, err := service.ExpireHolds(ctx)
+	assert.NoError(t, err, "истечение резервов несколькими пакетами")
+	assert.Equal(t, expireBatchSize+3, n, "истечение резервов несколькими пакетами")
+
+	_, err = service.ExpireHolds(ctx)
+	assert.Error(t, err, "ошибка при истечении резервов")
+	holds.AssertExpectations(t)
+}
+
+func TestTransaction_ExpirePoints(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		lots    = &LotRepositoryMock{}
+		service = NewTransaction(&TransactionRepositoryMock{}, &HoldRepositoryMock{}, lots, time.Minute)
+	)
+	lots.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(150, nil).Once()
+	lots.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(2, nil).Once()
+	lots.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(0, nil).Once()
+	lots.On("ExpireDue", mock.AnythingOfType("time.Time"), expireBatchSize).Return(1, errors.New("")).Once()
+
+	n, err := service.ExpirePoints(ctx)
+	assert.NoError(t, err, "истечение баллов несколькими пакетами")
+	assert.Equal(t, 152, n, "истечение баллов несколькими пакетами")
+
+	n, err = service.ExpirePoints(ctx)
+	assert.Error(t, err, "ошибка при истечении баллов")
+	assert.Equal(t, 1, n, "ошибка при истечении баллов")
+	lots.AssertExpectations(t)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+)
+
+type Transfer struct {
+	repository TransferRepository
+	dailyLimit entity.Amount
+}
+
+type TransferRepository interface {
+	Create(ctx context.Context, senderID int, recipient string, sum, limit entity.Amount) (entity.Transfer, error)
+	FindAllByUserID(ctx context.Context, userID int) ([]entity.Transfer, error)
+}
+
+// NewTransfer возвращает сервис переводов баллов между пользователями. Сумма переводов
+// одного пользователя за сутки ограничена dailyLimit, при нулевом dailyLimit - не ограничена.
+func NewTransfer(r TransferRepository, dailyLimit entity.Amount) *Transfer {
+	return &Transfer{
+		repository: r,
+		dailyLimit: dailyLimit,
+	}
+}
+
+// Transfer переводит sum баллов пользователя senderID пользователю с логином recipient.
+func (s *Transfer) Transfer(ctx context.Context, senderID int, recipient string, sum entity.Amount) (entity.Transfer, error) {
+	return s.repository.Create(ctx, senderID, recipient, sum, s.dailyLimit)
+}
+
+// GetTransfers возвращает список входящих и исходящих переводов пользователя.
+func (s *Transfer) GetTransfers(ctx context.Context, userID int) ([]entity.Transfer, error) {
+	return s.repository.FindAllByUserID(ctx, userID)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type TransferRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *TransferRepositoryMock) Create(_ context.Context, senderID int, recipient string, sum, limit entity.Amount) (entity.Transfer, error) {
+	args := m.Called(senderID, recipient, sum, limit)
+
+	return args.Get(0).(entity.Transfer), args.Error(1)
+}
+
+func (m *TransferRepositoryMock) FindAllByUserID(_ context.Context, userID int) ([]entity.Transfer, error) {
+	args := m.Called(userID)
+
+	return args.Get(0).([]entity.Transfer), args.Error(1)
+}
+
+func TestTransfer_Transfer(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		senderID   = 1
+		recipient  = "family"
+		sum        = entity.Amount(30_00)
+		limit      = entity.Amount(100_00)
+		transfer   = entity.Transfer{ID: 5, Direction: entity.TransferDirectionOut, Counterparty: recipient, Sum: sum}
+		repository = &TransferRepositoryMock{}
+	)
+	repository.
+		On("Create", senderID, recipient, sum, limit).
+		Return(transfer, nil).
+		Once()
+	repository.
+		On("Create", senderID, recipient, sum, limit).
+		Return(entity.Transfer{}, inerr.ErrTransferLimitExceeded).
+		Once()
+	service := NewTransfer(repository, limit)
+
+	res, err := service.Transfer(ctx, senderID, recipient, sum)
+	assert.NoError(t, err, "успешный перевод")
+	assert.Equal(t, transfer, res, "успешный перевод")
+
+	_, err = service.Transfer(ctx, senderID, recipient, sum)
+	assert.ErrorIs(t, err, inerr.ErrTransferLimitExceeded, "превышен суточный лимит переводов")
+
+	repository.AssertExpectations(t)
+}
+
+func TestTransfer_GetTransfers(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		userID    = 1
+		transfers = []entity.Transfer{
+			{ID: 1, Direction: entity.TransferDirectionIn, Counterparty: "friend", Sum: 12_50, CreatedAt: time.Now()},
+		}
+		repository = &TransferRepositoryMock{}
+	)
+	repository.On("FindAllByUserID", userID).Return(transfers, nil).Once()
+	service := NewTransfer(repository, 0)
+
+	res, err := service.GetTransfers(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, transfers, res)
+
+	repository.AssertExpectations(t)
+}
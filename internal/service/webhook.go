@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/ivanpodgorny/gophermart/internal/security"
+	"time"
+)
+
+type Webhook struct {
+	repository WebhookRepository
+	sender     WebhookSender
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error)
+	FindAll(ctx context.Context) ([]entity.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	FindDeliveries(ctx context.Context, webhookID int, limit int) ([]entity.WebhookDelivery, error)
+	FindDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error)
+	SaveAttempt(ctx context.Context, d entity.WebhookDelivery) error
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, d entity.WebhookDelivery) (int, error)
+}
+
+const (
+	webhookSecretSize    = 32
+	webhookMaxAttempts   = 10
+	webhookBatchSize     = 100
+	webhookDeliveryLimit = 100
+)
+
+func NewWebhook(r WebhookRepository, s WebhookSender) *Webhook {
+	return &Webhook{
+		repository: r,
+		sender:     s,
+	}
+}
+
+// Create создает подписку w со случайным ключом подписи. Ключ возвращается только в ответе
+// на этот вызов.
+func (s *Webhook) Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error) {
+	secret, err := security.RandomString(webhookSecretSize)
+	if err != nil {
+		return entity.Webhook{}, err
+	}
+
+	w.Secret = secret
+
+	return s.repository.Create(ctx, w)
+}
+
+// GetAll возвращает все подписки.
+func (s *Webhook) GetAll(ctx context.Context) ([]entity.Webhook, error) {
+	return s.repository.FindAll(ctx)
+}
+
+// Delete удаляет подписку и журнал доставки ее уведомлений.
+func (s *Webhook) Delete(ctx context.Context, id int) error {
+	return s.repository.Delete(ctx, id)
+}
+
+// GetDeliveries возвращает последние уведомления по подписке.
+func (s *Webhook) GetDeliveries(ctx context.Context, webhookID int) ([]entity.WebhookDelivery, error) {
+	return s.repository.FindDeliveries(ctx, webhookID, webhookDeliveryLimit)
+}
+
+// DeliverDue отправляет уведомления, время следующей попытки доставки которых наступило,
+// и сохраняет результат каждой попытки. Недоставленному уведомлению назначается следующая
+// попытка с экспоненциально растущей задержкой, после webhookMaxAttempts попыток доставка
+// прекращается. Возвращает количество доставленных уведомлений.
+func (s *Webhook) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.repository.FindDueDeliveries(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range deliveries {
+		code, err := s.sender.Send(ctx, d)
+		now := time.Now()
+		d.Attempts++
+		d.ResponseCode = code
+		switch {
+		case err == nil:
+			d.Status = entity.DeliveryStatusDelivered
+			d.Error = ""
+			d.DeliveredAt = &now
+			delivered++
+		case d.Attempts >= webhookMaxAttempts:
+			d.Status = entity.DeliveryStatusFailed
+			d.Error = err.Error()
+		default:
+			d.Error = err.Error()
+			d.NextAttemptAt = now.Add(entity.RetryDelay(d.Attempts))
+		}
+
+		if err := s.repository.SaveAttempt(ctx, d); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ivanpodgorny/gophermart/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type WebhookRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *WebhookRepositoryMock) Create(_ context.Context, w entity.Webhook) (entity.Webhook, error) {
+	args := m.Called(w)
+
+	return args.Get(0).(entity.Webhook), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) FindAll(_ context.Context) ([]entity.Webhook, error) {
+	args := m.Called()
+
+	return args.Get(0).([]entity.Webhook), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) Delete(_ context.Context, id int) error {
+	args := m.Called(id)
+
+	return args.Error(0)
+}
+
+func (m *WebhookRepositoryMock) FindDeliveries(_ context.Context, webhookID int, limit int) ([]entity.WebhookDelivery, error) {
+	args := m.Called(webhookID, limit)
+
+	return args.Get(0).([]entity.WebhookDelivery), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) FindDueDeliveries(_ context.Context, now time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	args := m.Called(now, limit)
+
+	return args.Get(0).([]entity.WebhookDelivery), args.Error(1)
+}
+
+func (m *WebhookRepositoryMock) SaveAttempt(_ context.Context, d entity.WebhookDelivery) error {
+	args := m.Called(d)
+
+	return args.Error(0)
+}
+
+type WebhookSenderMock struct {
+	mock.Mock
+}
+
+func (m *WebhookSenderMock) Send(_ context.Context, d entity.WebhookDelivery) (int, error) {
+	args := m.Called(d)
+
+	return args.Int(0), args.Error(1)
+}
+
+func TestWebhook_Create(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		url        = "https://partner.example/hooks"
+		events     = []entity.WebhookEvent{entity.WebhookEventOrderProcessed}
+		repository = &WebhookRepositoryMock{}
+	)
+
+	repository.
+		On("Create", mock.MatchedBy(func(w entity.Webhook) bool {
+			return w.URL == url && len(w.Events) == 1 && len(w.UserIDs) == 1 && len(w.Secret) == webhookSecretSize
+		})).
+		Return(entity.Webhook{ID: 1, URL: url, Events: events, Secret: "secret"}, nil).
+		Once()
+
+	w, err := NewWebhook(repository, &WebhookSenderMock{}).Create(ctx, entity.Webhook{URL: url, Events: events, UserIDs: []int{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, w.ID)
+	assert.Equal(t, "secret", w.Secret)
+	repository.AssertExpectations(t)
+}
+
+func TestWebhook_DeliverDue(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		delivered = entity.WebhookDelivery{ID: 1, Status: entity.DeliveryStatusPending}
+		retried   = entity.WebhookDelivery{ID: 2, Status: entity.DeliveryStatusPending, Attempts: 2}
+		failed    = entity.WebhookDelivery{ID: 3, Status: entity.DeliveryStatusPending, Attempts: webhookMaxAttempts - 1}
+		sendErr   = errors.New("unexpected response status 502")
+		before    = time.Now()
+
+		repository = &WebhookRepositoryMock{}
+		sender     = &WebhookSenderMock{}
+	)
+
+	repository.
+		On("FindDueDeliveries", mock.AnythingOfType("time.Time"), webhookBatchSize).
+		Return([]entity.WebhookDelivery{delivered, retried, failed}, nil).
+		Once()
+	sender.On("Send", delivered).Return(200, nil).Once()
+	sender.On("Send", retried).Return(502, sendErr).Once()
+	sender.On("Send", failed).Return(0, sendErr).Once()
+	repository.
+		On("SaveAttempt", mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+			return d.ID == 1 &&
+				d.Status == entity.DeliveryStatusDelivered &&
+				d.Attempts == 1 &&
+				d.ResponseCode == 200 &&
+				d.DeliveredAt != nil
+		})).
+		Return(nil).
+		Once()
+	repository.
+		On("SaveAttempt", mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+			return d.ID == 2 &&
+				d.Status == entity.DeliveryStatusPending &&
+				d.Attempts == 3 &&
+				d.ResponseCode == 502 &&
+				d.Error == sendErr.Error() &&
+				!d.NextAttemptAt.Before(before.Add(entity.RetryDelay(3)))
+		})).
+		Return(nil).
+		Once()
+	repository.
+		On("SaveAttempt", mock.MatchedBy(func(d entity.WebhookDelivery) bool {
+			return d.ID == 3 && d.Status == entity.DeliveryStatusFailed && d.Attempts == webhookMaxAttempts
+		})).
+		Return(nil).
+		Once()
+
+	n, err := NewWebhook(repository, sender).DeliverDue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "доставлено одно уведомление")
+	repository.AssertExpectations(t)
+	sender.AssertExpectations(t)
+}
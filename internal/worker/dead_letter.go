@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type DeadLetterProcessor interface {
+	RetryDue(ctx context.Context) (applied int, err error)
+}
+
+// NewDeadLetterRetrier возвращает задачу, которая каждые i повторно применяет результаты
+// проверки статусов заказов, которые не удалось сохранить ранее.
+func NewDeadLetterRetrier(p DeadLetterProcessor, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.RetryDue, Every(i), wg, Labels{
+		Error: "ошибка повторного применения статусов заказов",
+		Done:  "повторно применены статусы заказов",
+	})
+}
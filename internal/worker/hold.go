@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type HoldProcessor interface {
+	ExpireHolds(ctx context.Context) (expired int, err error)
+}
+
+// NewHoldExpirer возвращает задачу, которая каждые i отменяет резервы баллов с истекшим
+// сроком действия.
+func NewHoldExpirer(p HoldProcessor, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.ExpireHolds, Every(i), wg, Labels{
+		Error: "ошибка отмены истекших резервов",
+		Done:  "отменены истекшие резервы",
+	})
+}
@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type IdempotencyPurger interface {
+	DeleteExpired(ctx context.Context) (deleted int, err error)
+}
+
+// NewIdempotencyPurger возвращает задачу, которая каждые i удаляет истекшие ключи
+// идемпотентности.
+func NewIdempotencyPurger(p IdempotencyPurger, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.DeleteExpired, Every(i), wg, Labels{
+		Error: "ошибка удаления истекших ключей идемпотентности",
+		Done:  "удалены истекшие ключи идемпотентности",
+	})
+}
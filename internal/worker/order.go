@@ -2,44 +2,70 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"log"
 	"sync"
+	"time"
 )
 
 // OrderUpdater получает задачи на обновление статусов заказов и выполняет обновление.
-// Для выполнения обновлений создается OrderUpdater.workersCount воркеров.
+// Для выполнения обновлений создается OrderUpdater.workersCount воркеров, количество
+// воркеров можно изменить во время работы с помощью OrderUpdater.Resize. После остановки
+// воркеры сохраняют задачи, оставшиеся в очереди, в течение OrderUpdater.drainTimeout.
+// Задачи, которые не удалось выполнить, передаются в DeadLetterQueue для повторных попыток.
 type OrderUpdater struct {
 	repository   UpdaterRepository
+	deadLetters  DeadLetterQueue
 	queue        <-chan entity.StatusCheckResult
 	wg           *sync.WaitGroup
 	workersCount int
+	workers      pool
+	drainTimeout time.Duration
 }
 
 type UpdaterRepository interface {
-	UpdateStatus(ctx context.Context, num string, status entity.OrderStatus, accrual float64) error
+	UpdateStatus(ctx context.Context, num string, status entity.OrderStatus, accrual entity.Amount) error
 }
 
-func NewOrderUpdater(r UpdaterRepository, q <-chan entity.StatusCheckResult, wg *sync.WaitGroup, w int) *OrderUpdater {
+type DeadLetterQueue interface {
+	Add(ctx context.Context, res entity.StatusCheckResult, cause error) error
+}
+
+func NewOrderUpdater(
+	r UpdaterRepository,
+	dl DeadLetterQueue,
+	q <-chan entity.StatusCheckResult,
+	wg *sync.WaitGroup,
+	w int,
+	d time.Duration,
+) *OrderUpdater {
 	return &OrderUpdater{
 		repository:   r,
+		deadLetters:  dl,
 		queue:        q,
 		wg:           wg,
 		workersCount: w,
+		drainTimeout: d,
 	}
 }
 
 func (u *OrderUpdater) Do(ctx context.Context) {
-	for i := 0; i < u.workersCount; i++ {
-		u.wg.Add(1)
+	u.workers.start(ctx, u.wg, u.workersCount, u.worker)
+}
 
-		go u.worker(ctx)
-	}
+// Workers возвращает количество воркеров.
+func (u *OrderUpdater) Workers() int {
+	return u.workers.len()
 }
 
-func (u *OrderUpdater) worker(ctx context.Context) {
-	defer u.wg.Done()
+// Resize изменяет количество воркеров.
+func (u *OrderUpdater) Resize(n int) {
+	u.workers.resize(n)
+}
 
+func (u *OrderUpdater) worker(ctx context.Context) {
 	for {
 		select {
 		case res, ok := <-u.queue:
@@ -47,11 +73,49 @@ func (u *OrderUpdater) worker(ctx context.Context) {
 				return
 			}
 
-			if err := u.repository.UpdateStatus(ctx, res.Num, res.Status, res.Accrual); err != nil {
-				log.Printf("ошибка обновления статуса заказа %s: %v", res.Num, err)
-			}
+			u.update(ctx, res)
 		case <-ctx.Done():
+			u.drain()
+
 			return
 		}
 	}
 }
+
+// drain сохраняет задачи, оставшиеся в очереди. Задачи, которые не удалось сохранить
+// за OrderUpdater.drainTimeout, теряются, но заказы остаются необработанными и будут
+// проверены повторно при следующем запуске.
+func (u *OrderUpdater) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), u.drainTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case res, ok := <-u.queue:
+			if !ok {
+				return
+			}
+
+			u.update(ctx, res)
+		default:
+			return
+		}
+	}
+}
+
+func (u *OrderUpdater) update(ctx context.Context, res entity.StatusCheckResult) {
+	err := u.repository.UpdateStatus(ctx, res.Num, res.Status, res.Accrual)
+	if err == nil {
+		return
+	}
+
+	log.Printf("ошибка обновления статуса заказа %s: %v", res.Num, err)
+	// Недопустимый переход и отсутствующий заказ не исправятся при повторной попытке.
+	if errors.Is(err, inerr.ErrInvalidTransition) || errors.Is(err, inerr.ErrOrderNotFound) {
+		return
+	}
+
+	if err := u.deadLetters.Add(ctx, res, err); err != nil {
+		log.Printf("ошибка сохранения необработанного статуса заказа %s: %v", res.Num, err)
+	}
+}
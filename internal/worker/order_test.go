@@ -2,7 +2,9 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"sync"
@@ -14,17 +16,28 @@ type UpdaterRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *UpdaterRepositoryMock) UpdateStatus(_ context.Context, n string, s entity.OrderStatus, a float64) error {
+func (m *UpdaterRepositoryMock) UpdateStatus(_ context.Context, n string, s entity.OrderStatus, a entity.Amount) error {
 	args := m.Called(n, s, a)
 
 	return args.Error(0)
 }
 
+type DeadLetterQueueMock struct {
+	mock.Mock
+}
+
+func (m *DeadLetterQueueMock) Add(_ context.Context, res entity.StatusCheckResult, cause error) error {
+	args := m.Called(res, cause)
+
+	return args.Error(0)
+}
+
 func TestOrderUpdater_Do(t *testing.T) {
 	var (
 		ctx, cancel = context.WithCancel(context.Background())
 		repository  = &UpdaterRepositoryMock{}
 		queue       = make(chan entity.StatusCheckResult, 4)
+		wg          = &sync.WaitGroup{}
 		jobs        = []entity.StatusCheckResult{
 			{
 				Num:     "711388585544181",
@@ -59,7 +72,7 @@ func TestOrderUpdater_Do(t *testing.T) {
 	updater := OrderUpdater{
 		repository:   repository,
 		queue:        queue,
-		wg:           &sync.WaitGroup{},
+		wg:           wg,
 		workersCount: 4,
 	}
 
@@ -74,6 +87,7 @@ func TestOrderUpdater_Do(t *testing.T) {
 	)
 
 	cancel()
+	wg.Wait()
 	for _, j := range jobs {
 		queue <- j
 	}
@@ -87,3 +101,107 @@ func TestOrderUpdater_Do(t *testing.T) {
 
 	repository.AssertExpectations(t)
 }
+
+func TestOrderUpdater_DoDrainsQueue(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		repository  = &UpdaterRepositoryMock{}
+		queue       = make(chan entity.StatusCheckResult, 4)
+		wg          = &sync.WaitGroup{}
+		jobs        = []entity.StatusCheckResult{
+			{
+				Num:     "711388585544181",
+				Status:  entity.OrderStatusProcessed,
+				Accrual: 50,
+			},
+			{
+				Num:     "655770442208670",
+				Status:  entity.OrderStatusInvalid,
+				Accrual: 0,
+			},
+		}
+	)
+
+	for i := range jobs {
+		j := jobs[i]
+		queue <- j
+		repository.On("UpdateStatus", j.Num, j.Status, j.Accrual).Return(nil).Once()
+	}
+
+	cancel()
+	updater := NewOrderUpdater(repository, &DeadLetterQueueMock{}, queue, wg, 2, time.Second)
+	updater.Do(ctx)
+	wg.Wait()
+
+	assert.Empty(t, queue, "задачи, оставшиеся в очереди, сохраняются после остановки")
+	repository.AssertExpectations(t)
+}
+
+func TestOrderUpdater_DoSavesFailedResults(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		repository  = &UpdaterRepositoryMock{}
+		deadLetters = &DeadLetterQueueMock{}
+		queue       = make(chan entity.StatusCheckResult, 1)
+		wg          = &sync.WaitGroup{}
+		res         = entity.StatusCheckResult{
+			Num:     "711388585544181",
+			Status:  entity.OrderStatusProcessed,
+			Accrual: 50,
+		}
+		updateErr = errors.New("connection refused")
+		saved     = make(chan struct{})
+	)
+
+	repository.On("UpdateStatus", res.Num, res.Status, res.Accrual).Return(updateErr).Once()
+	deadLetters.On("Add", res, updateErr).Return(nil).Once().Run(func(_ mock.Arguments) {
+		close(saved)
+	})
+	updater := NewOrderUpdater(repository, deadLetters, queue, wg, 1, time.Second)
+	updater.Do(ctx)
+	queue <- res
+
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		assert.Fail(t, "результат не сохранен для повторной попытки")
+	}
+
+	cancel()
+	wg.Wait()
+	repository.AssertExpectations(t)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestOrderUpdater_DoSkipsInvalidTransitions(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		repository  = &UpdaterRepositoryMock{}
+		deadLetters = &DeadLetterQueueMock{}
+		queue       = make(chan entity.StatusCheckResult, 1)
+		wg          = &sync.WaitGroup{}
+		res         = entity.StatusCheckResult{
+			Num:    "711388585544181",
+			Status: entity.OrderStatusProcessing,
+		}
+		updated = make(chan struct{})
+	)
+
+	repository.On("UpdateStatus", res.Num, res.Status, res.Accrual).Return(inerr.ErrInvalidTransition).Once().Run(func(_ mock.Arguments) {
+		close(updated)
+	})
+	updater := NewOrderUpdater(repository, deadLetters, queue, wg, 1, time.Second)
+	updater.Do(ctx)
+	queue <- res
+
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		assert.Fail(t, "результат не обработан")
+	}
+
+	cancel()
+	wg.Wait()
+	repository.AssertExpectations(t)
+	deadLetters.AssertNotCalled(t, "Add", mock.Anything, mock.Anything)
+}
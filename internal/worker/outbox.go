@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type OutboxProcessor interface {
+	PublishPending(ctx context.Context) (published int, err error)
+}
+
+type OutboxPurger interface {
+	DeletePublished(ctx context.Context) (deleted int, err error)
+}
+
+// NewOutboxRelay возвращает задачу, которая каждые i публикует доменные события, записанные в outbox.
+func NewOutboxRelay(p OutboxProcessor, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.PublishPending, Every(i), wg, Labels{
+		Error: "ошибка публикации событий",
+		Done:  "опубликованы события",
+	})
+}
+
+// NewOutboxPurger возвращает задачу, которая каждые i удаляет опубликованные события с истекшим
+// сроком хранения.
+func NewOutboxPurger(p OutboxPurger, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.DeletePublished, Every(i), wg, Labels{
+		Error: "ошибка удаления опубликованных событий",
+		Done:  "удалены опубликованные события",
+	})
+}
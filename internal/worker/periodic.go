@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Periodic выполняет задачу по расписанию: после каждого запуска следующий назначается
+// на момент, который возвращает Schedule. Результат запуска - количество обработанных
+// объектов или ошибка - записывается в журнал с подписями Labels.
+type Periodic struct {
+	run      func(ctx context.Context) (int, error)
+	schedule Schedule
+	wg       *sync.WaitGroup
+	labels   Labels
+}
+
+// Schedule возвращает момент следующего запуска после now.
+type Schedule func(now time.Time) time.Time
+
+// Labels - подписи записей журнала: Error - для ошибки запуска, Done - для количества
+// обработанных объектов. Запуски, которые ничего не обработали, в журнал не записываются.
+type Labels struct {
+	Error string
+	Done  string
+}
+
+func NewPeriodic(run func(ctx context.Context) (int, error), s Schedule, wg *sync.WaitGroup, l Labels) *Periodic {
+	return &Periodic{
+		run:      run,
+		schedule: s,
+		wg:       wg,
+		labels:   l,
+	}
+}
+
+// Every возвращает расписание запусков с интервалом d.
+func Every(d time.Duration) Schedule {
+	return func(now time.Time) time.Time {
+		return now.Add(d)
+	}
+}
+
+// DailyAt возвращает расписание ежедневных запусков в момент, отстоящий от полуночи
+// по местному времени на at.
+func DailyAt(at time.Duration) Schedule {
+	return func(now time.Time) time.Time {
+		return nextDailyRun(now, at)
+	}
+}
+
+// Do запускает выполнение задачи. Работа останавливается при отмене ctx.
+func (p *Periodic) Do(ctx context.Context) {
+	p.wg.Add(1)
+	go p.worker(ctx)
+}
+
+func (p *Periodic) worker(ctx context.Context) {
+	defer p.wg.Done()
+
+	timer := time.NewTimer(time.Until(p.schedule(time.Now())))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			n, err := p.run(ctx)
+			if err != nil {
+				log.Printf("%s: %v", p.labels.Error, err)
+			} else if n > 0 {
+				log.Printf("%s: %d", p.labels.Done, n)
+			}
+			timer.Reset(time.Until(p.schedule(time.Now())))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextDailyRun возвращает ближайший после now момент, отстоящий от полуночи на at.
+func nextDailyRun(now time.Time, at time.Duration) time.Time {
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(at)
+	if !next.After(now) {
+		next = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Add(at)
+	}
+
+	return next
+}
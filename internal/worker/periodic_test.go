@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPeriodic_Do(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          = &sync.WaitGroup{}
+		calls       atomic.Int32
+		results     = []error{nil, errors.New("connection refused"), nil}
+	)
+
+	p := NewPeriodic(
+		func(_ context.Context) (int, error) {
+			n := calls.Add(1)
+			if int(n) <= len(results) {
+				return int(n), results[n-1]
+			}
+
+			return 0, nil
+		},
+		Every(10*time.Millisecond),
+		wg,
+		Labels{Error: "ошибка", Done: "обработано"},
+	)
+	p.Do(ctx)
+
+	assert.Eventually(
+		t,
+		func() bool { return calls.Load() >= 3 },
+		time.Second,
+		5*time.Millisecond,
+		"задача запускается повторно, в том числе после ошибки",
+	)
+
+	cancel()
+	wg.Wait()
+	n := calls.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, n, calls.Load(), "задача не запускается после отмены контекста")
+}
+
+func TestNextDailyRun(t *testing.T) {
+	at := 3 * time.Hour
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			name: "до времени запуска",
+			now:  time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "в момент запуска",
+			now:  time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 11, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "после времени запуска в конце месяца",
+			now:  time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 4, 1, 3, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextDailyRun(tt.now, at))
+			assert.Equal(t, tt.want, DailyAt(at)(tt.now))
+		})
+	}
+}
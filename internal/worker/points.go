@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type PointsProcessor interface {
+	ExpirePoints(ctx context.Context) (expired int, err error)
+}
+
+// NewPointsExpirer возвращает задачу, которая ежедневно в at (смещение от полуночи
+// по местному времени) списывает баллы с истекшим сроком действия.
+func NewPointsExpirer(p PointsProcessor, wg *sync.WaitGroup, at time.Duration) *Periodic {
+	return NewPeriodic(p.ExpirePoints, DailyAt(at), wg, Labels{
+		Error: "ошибка списания истекших баллов",
+		Done:  "списаны истекшие партии баллов",
+	})
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// pool управляет набором однотипных воркеров, количество которых можно изменить во время
+// работы. Нулевое значение готово к использованию.
+type pool struct {
+	mu      sync.Mutex
+	size    int
+	sized   bool
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	run     func(ctx context.Context)
+	cancels []context.CancelFunc
+}
+
+// start запускает воркеры run, работающие до отмены ctx. Количество воркеров равно
+// size, если оно не было изменено ранее вызовом resize.
+func (p *pool) start(ctx context.Context, wg *sync.WaitGroup, size int, run func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.sized {
+		p.size = size
+		p.sized = true
+	}
+
+	p.ctx = ctx
+	p.wg = wg
+	p.run = run
+	p.cancels = nil
+	p.scale()
+}
+
+// resize изменяет количество воркеров. Если воркеры запущены, запускает недостающие
+// или останавливает лишние, иначе новое количество применяется при следующем запуске.
+func (p *pool) resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.size = size
+	p.sized = true
+	if p.ctx != nil && p.ctx.Err() == nil {
+		p.scale()
+	}
+}
+
+func (p *pool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.size
+}
+
+func (p *pool) scale() {
+	for len(p.cancels) < p.size {
+		ctx, cancel := context.WithCancel(p.ctx)
+		p.cancels = append(p.cancels, cancel)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx)
+		}()
+	}
+
+	for len(p.cancels) > p.size {
+		last := len(p.cancels) - 1
+		p.cancels[last]()
+		p.cancels = p.cancels[:last]
+	}
+}
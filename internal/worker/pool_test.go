@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          = &sync.WaitGroup{}
+		running     atomic.Int64
+		p           = &pool{}
+		run         = func(ctx context.Context) {
+			running.Add(1)
+			<-ctx.Done()
+			running.Add(-1)
+		}
+		runningEq = func(n int64) func() bool {
+			return func() bool { return running.Load() == n }
+		}
+	)
+
+	p.resize(3)
+	p.start(ctx, wg, 2, run)
+	assert.Eventually(t, runningEq(3), 100*time.Millisecond, 10*time.Millisecond, "размер, измененный до запуска, сохраняется")
+
+	p.resize(5)
+	assert.Eventually(t, runningEq(5), 100*time.Millisecond, 10*time.Millisecond, "запуск дополнительных воркеров")
+	assert.Equal(t, 5, p.len())
+
+	p.resize(1)
+	assert.Eventually(t, runningEq(1), 100*time.Millisecond, 10*time.Millisecond, "остановка лишних воркеров")
+	assert.Equal(t, 1, p.len())
+
+	cancel()
+	wg.Wait()
+	assert.Equal(t, int64(0), running.Load(), "остановка всех воркеров при отмене контекста")
+
+	p.resize(2)
+	assert.Never(t, func() bool { return running.Load() > 0 }, 50*time.Millisecond, 10*time.Millisecond, "воркеры не запускаются после остановки")
+}
@@ -2,15 +2,27 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"log"
 	"sync"
+	"time"
 )
 
 // StatusChecker проверяет статус начисления в системе расчёта начислений баллов лояльности
 // и создает задачу на обновление заказа, если статус обновился. Для выполнения запросов
-// на проверку создается StatusChecker.workersCount воркеров. При вызове NewStatusChecker
-// добавляет в очередь на проверку сохраненные необработанные заказы.
+// на проверку создается StatusChecker.workersCount воркеров, количество воркеров можно
+// изменить во время работы с помощью StatusChecker.Resize. При вызове StatusChecker.Do
+// и далее каждые rescanInterval добавляет в очередь на проверку сохраненные необработанные
+// заказы, которых еще нет в очереди, в том числе добавленные другими экземплярами сервиса.
+// Перед каждым запросом к системе расчёта начислений проверяет, что заказ не отменен
+// пользователем: отмена может быть выполнена на любом экземпляре сервиса, а очередь есть
+// только у ведущего. Заказ, по которому система расчёта начислений ответила не по протоколу,
+// проверяется повторно с задержкой, которая растет с каждым таким ответом подряд.
+//
+// Очередь задач не закрывается: после остановки задачи, которые не удалось поставить
+// в очередь, отбрасываются, так как заказы уже сохранены и будут загружены при следующем запуске.
 type StatusChecker struct {
 	repository   CheckerRepository
 	client       AccrualClient
@@ -18,18 +30,27 @@ type StatusChecker struct {
 	results      chan<- entity.StatusCheckResult
 	wg           *sync.WaitGroup
 	workersCount int
+	workers      pool
+	pending      sync.Map
+	mu           sync.Mutex
+	ctx          context.Context
 }
 
 type CheckerRepository interface {
 	FindUnprocessed(ctx context.Context) []entity.Order
+	FindStatus(ctx context.Context, num string) (entity.OrderStatus, error)
 }
 
 type AccrualClient interface {
-	GetAccrual(ctx context.Context, order string) (status entity.OrderStatus, accrual float64, err error)
+	GetAccrual(ctx context.Context, order string) (status entity.OrderStatus, accrual entity.Amount, err error)
 }
 
+var (
+	rescanInterval     = 30 * time.Second
+	protocolRetryDelay = entity.RetryDelay
+)
+
 func NewStatusChecker(
-	ctx context.Context,
 	r CheckerRepository,
 	c AccrualClient,
 	j chan entity.StatusCheckJob,
@@ -37,7 +58,7 @@ func NewStatusChecker(
 	wg *sync.WaitGroup,
 	w int,
 ) *StatusChecker {
-	checker := &StatusChecker{
+	return &StatusChecker{
 		repository:   r,
 		client:       c,
 		jobs:         j,
@@ -45,30 +66,103 @@ func NewStatusChecker(
 		wg:           wg,
 		workersCount: w,
 	}
+}
 
-	for _, o := range checker.repository.FindUnprocessed(ctx) {
-		go func(order entity.Order) {
-			checker.jobs <- entity.StatusCheckJob{
-				Num:    order.Number,
-				Status: order.Status,
-			}
-		}(o)
+// Do запускает воркеры и загрузку необработанных заказов. Работа останавливается при отмене ctx.
+func (c *StatusChecker) Do(ctx context.Context) {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+
+	c.workers.start(ctx, c.wg, c.workersCount, c.worker)
+
+	c.wg.Add(1)
+	go c.loader(ctx)
+}
+
+// Workers возвращает количество воркеров.
+func (c *StatusChecker) Workers() int {
+	return c.workers.len()
+}
+
+// Resize изменяет количество воркеров.
+func (c *StatusChecker) Resize(n int) {
+	c.workers.resize(n)
+}
+
+// Enqueue добавляет задачу в очередь на проверку, если по заказу еще нет задачи в очереди.
+// Если StatusChecker не запущен, задача отбрасывается: заказ будет загружен из хранилища
+// при следующем запуске.
+func (c *StatusChecker) Enqueue(j entity.StatusCheckJob) {
+	ctx := c.context()
+	if ctx == nil || ctx.Err() != nil {
+		return
 	}
 
-	return checker
+	if _, loaded := c.pending.LoadOrStore(j.Num, struct{}{}); loaded {
+		return
+	}
+
+	go c.push(ctx, j)
 }
 
-func (c *StatusChecker) Do(ctx context.Context) {
-	for i := 0; i < c.workersCount; i++ {
-		c.wg.Add(1)
+// context возвращает контекст текущего запуска StatusChecker. Повторные задачи ставятся
+// в очередь с этим контекстом, а не с контекстом воркера, так как воркер может быть
+// остановлен при изменении их количества.
+func (c *StatusChecker) context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ctx
+}
+
+// pushAfter ставит задачу в очередь через delay. Если StatusChecker остановлен раньше,
+// задача отбрасывается.
+func (c *StatusChecker) pushAfter(ctx context.Context, j entity.StatusCheckJob, delay time.Duration) {
+	t := time.NewTimer(delay)
+	defer t.Stop()
 
-		go c.worker(ctx)
+	select {
+	case <-t.C:
+		c.push(ctx, j)
+	case <-ctx.Done():
+		c.pending.Delete(j.Num)
 	}
 }
 
-func (c *StatusChecker) worker(ctx context.Context) {
+// push ставит задачу в очередь. Если StatusChecker остановлен раньше, чем в очереди
+// освободилось место, задача отбрасывается.
+func (c *StatusChecker) push(ctx context.Context, j entity.StatusCheckJob) {
+	select {
+	case c.jobs <- j:
+	case <-ctx.Done():
+		c.pending.Delete(j.Num)
+	}
+}
+
+func (c *StatusChecker) loader(ctx context.Context) {
 	defer c.wg.Done()
 
+	ticker := time.NewTicker(rescanInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, o := range c.repository.FindUnprocessed(ctx) {
+			c.Enqueue(entity.StatusCheckJob{
+				Num:    o.Number,
+				Status: o.Status,
+			})
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *StatusChecker) worker(ctx context.Context) {
 	for {
 		select {
 		case j, ok := <-c.jobs:
@@ -76,25 +170,60 @@ func (c *StatusChecker) worker(ctx context.Context) {
 				return
 			}
 
+			// Задача отмененного или уже обработанного заказа отбрасывается. Номер отмененного заказа
+			// мог быть загружен повторно: задача, которая еще в очереди, продолжает проверку нового заказа.
+			current, err := c.repository.FindStatus(ctx, j.Num)
+			if err != nil && !errors.Is(err, inerr.ErrOrderNotFound) {
+				go c.push(c.context(), j)
+				log.Printf("ошибка получения заказа %s: %v", j.Num, err)
+
+				continue
+			}
+			if err != nil || current.IsFinal() {
+				c.pending.Delete(j.Num)
+
+				continue
+			}
+
 			status, accrual, err := c.client.GetAccrual(ctx, j.Num)
+			if errors.Is(err, inerr.ErrProtocolViolation) {
+				j.Violations++
+				go c.pushAfter(c.context(), j, protocolRetryDelay(j.Violations))
+				log.Printf("ошибка получения статуса заказа %s: %v", j.Num, err)
+
+				continue
+			}
 			if err != nil {
-				c.jobs <- j
+				go c.push(c.context(), j)
 				log.Printf("ошибка получения статуса заказа %s: %v", j.Num, err)
 
 				continue
 			}
+			j.Violations = 0
 
-			if status != j.Status {
+			// Статус, в который заказ не может перейти из текущего (например, устаревший ответ
+			// системы расчёта начислений), игнорируется, проверка продолжается. Если результат
+			// не принят до остановки, заказ будет проверен повторно после перезапуска.
+			if j.Status.CanTransitionTo(status) {
 				j.Status = status
-				c.results <- entity.StatusCheckResult{
+				select {
+				case c.results <- entity.StatusCheckResult{
 					Num:     j.Num,
 					Status:  status,
 					Accrual: accrual,
+				}:
+				case <-ctx.Done():
+					return
 				}
 			}
-			if status != entity.OrderStatusInvalid && status != entity.OrderStatusProcessed {
-				c.jobs <- j
+
+			if !j.Status.IsFinal() {
+				go c.push(c.context(), j)
+
+				continue
 			}
+
+			c.pending.Delete(j.Num)
 		case <-ctx.Done():
 			return
 		}
@@ -2,7 +2,9 @@ package worker
 
 import (
 	"context"
+	"fmt"
 	"github.com/ivanpodgorny/gophermart/internal/entity"
+	inerr "github.com/ivanpodgorny/gophermart/internal/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"sync"
@@ -20,26 +22,33 @@ func (m *CheckerRepositoryMock) FindUnprocessed(_ context.Context) []entity.Orde
 	return args.Get(0).([]entity.Order)
 }
 
+func (m *CheckerRepositoryMock) FindStatus(_ context.Context, num string) (entity.OrderStatus, error) {
+	args := m.Called(num)
+
+	return args.Get(0).(entity.OrderStatus), args.Error(1)
+}
+
 type AccrualClientMock struct {
 	mock.Mock
 }
 
-func (m *AccrualClientMock) GetAccrual(_ context.Context, order string) (entity.OrderStatus, float64, error) {
+func (m *AccrualClientMock) GetAccrual(_ context.Context, order string) (entity.OrderStatus, entity.Amount, error) {
 	args := m.Called(order)
 
-	return args.Get(0).(entity.OrderStatus), args.Get(1).(float64), args.Error(2)
+	return args.Get(0).(entity.OrderStatus), args.Get(1).(entity.Amount), args.Error(2)
 }
 
-func TestNewStatusChecker(t *testing.T) {
+func TestStatusChecker_DoLoadsUnprocessed(t *testing.T) {
 	var (
-		orders = []entity.Order{
+		ctx, cancel = context.WithCancel(context.Background())
+		orders      = []entity.Order{
 			{
 				Number: "148561163482734",
 				Status: entity.OrderStatusProcessing,
 			},
 			{
 				Number: "267624438264306",
-				Status: entity.OrderStatusProcessed,
+				Status: entity.OrderStatusNew,
 			},
 		}
 		jobs = []entity.StatusCheckJob{
@@ -49,27 +58,48 @@ func TestNewStatusChecker(t *testing.T) {
 			},
 			{
 				Num:    "267624438264306",
-				Status: entity.OrderStatusProcessed,
+				Status: entity.OrderStatusNew,
 			},
 		}
 		jobsCh     = make(chan entity.StatusCheckJob, 4)
 		repository = &CheckerRepositoryMock{}
+		wg         = &sync.WaitGroup{}
 	)
+
 	repository.On("FindUnprocessed").Return(orders).Once()
-	NewStatusChecker(
-		context.Background(),
+	checker := NewStatusChecker(
 		repository,
 		&AccrualClientMock{},
 		jobsCh,
 		make(chan entity.StatusCheckResult, 4),
-		&sync.WaitGroup{},
-		4,
+		wg,
+		0,
 	)
+	checker.Do(ctx)
 
 	for i := 0; i < len(orders); i++ {
 		assert.Contains(t, jobs, <-jobsCh, "успешная загрузка необработанных заказов")
 	}
 
+	checker.Enqueue(jobs[0])
+	assert.Never(
+		t,
+		func() bool { return len(jobsCh) > 0 },
+		50*time.Millisecond,
+		10*time.Millisecond,
+		"задача по заказу, который уже в очереди, не добавляется повторно",
+	)
+
+	cancel()
+	wg.Wait()
+	checker.Enqueue(entity.NewStatusCheckJob("166221614883769"))
+	assert.Never(
+		t,
+		func() bool { return len(jobsCh) > 0 },
+		50*time.Millisecond,
+		10*time.Millisecond,
+		"задача не добавляется в остановленный StatusChecker",
+	)
 	repository.AssertExpectations(t)
 }
 
@@ -77,6 +107,7 @@ func TestStatusChecker_Do(t *testing.T) {
 	var (
 		ctx, cancel = context.WithCancel(context.Background())
 		client      = &AccrualClientMock{}
+		repository  = &CheckerRepositoryMock{}
 		jobsCh      = make(chan entity.StatusCheckJob, 4)
 		resultsCh   = make(chan entity.StatusCheckResult, 4)
 		jobs        = []entity.StatusCheckJob{
@@ -128,9 +159,12 @@ func TestStatusChecker_Do(t *testing.T) {
 		j := jobs[i]
 		r := results[i]
 		jobsCh <- j
+		repository.On("FindStatus", j.Num).Return(j.Status, nil).Once()
 		client.On("GetAccrual", j.Num).Return(r.Status, r.Accrual, nil).Once()
 	}
+	repository.On("FindUnprocessed").Return([]entity.Order{}).Once()
 	checker := StatusChecker{
+		repository:   repository,
 		client:       client,
 		jobs:         jobsCh,
 		results:      resultsCh,
@@ -164,4 +198,161 @@ func TestStatusChecker_Do(t *testing.T) {
 	)
 
 	client.AssertExpectations(t)
+	repository.AssertExpectations(t)
+}
+
+func TestStatusChecker_DoDropsCancelled(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		client      = &AccrualClientMock{}
+		repository  = &CheckerRepositoryMock{}
+		jobsCh      = make(chan entity.StatusCheckJob, 4)
+		resultsCh   = make(chan entity.StatusCheckResult, 4)
+		cancelled   = "711388585544181"
+		processed   = "116322550058324"
+		revived     = "655770442208670"
+		checker     = StatusChecker{
+			repository: repository,
+			client:     client,
+			jobs:       jobsCh,
+			results:    resultsCh,
+			wg:         &sync.WaitGroup{},
+		}
+	)
+
+	checker.ctx = ctx
+	for _, num := range []string{cancelled, processed, revived} {
+		checker.Enqueue(entity.NewStatusCheckJob(num))
+	}
+	assert.Eventually(
+		t,
+		func() bool { return len(jobsCh) == 3 },
+		100*time.Millisecond,
+		10*time.Millisecond,
+		"задачи добавлены в очередь",
+	)
+
+	// Заказы могут быть отменены или обработаны на другом экземпляре сервиса.
+	repository.On("FindStatus", cancelled).Return(entity.OrderStatus(""), inerr.ErrOrderNotFound).Once()
+	repository.On("FindStatus", processed).Return(entity.OrderStatusProcessed, nil).Once()
+	repository.On("FindStatus", revived).Return(entity.OrderStatusNew, nil).Once()
+	client.On("GetAccrual", revived).Return(entity.OrderStatusInvalid, entity.Amount(0), nil).Once()
+
+	checker.workers.start(ctx, checker.wg, 1, checker.worker)
+	assert.Equal(
+		t,
+		entity.StatusCheckResult{Num: revived, Status: entity.OrderStatusInvalid},
+		<-resultsCh,
+		"проверка существующего заказа продолжается",
+	)
+	assert.Eventually(
+		t,
+		func() bool {
+			_, pendingCancelled := checker.pending.Load(cancelled)
+			_, pendingProcessed := checker.pending.Load(processed)
+
+			return len(jobsCh) == 0 && !pendingCancelled && !pendingProcessed
+		},
+		100*time.Millisecond,
+		10*time.Millisecond,
+		"задачи отмененного и обработанного заказов отброшены без запроса к системе расчёта начислений",
+	)
+
+	cancel()
+	checker.wg.Wait()
+	client.AssertExpectations(t)
+	repository.AssertExpectations(t)
+}
+
+func TestStatusChecker_DoDelaysProtocolViolations(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		client      = &AccrualClientMock{}
+		repository  = &CheckerRepositoryMock{}
+		jobsCh      = make(chan entity.StatusCheckJob, 4)
+		resultsCh   = make(chan entity.StatusCheckResult, 4)
+		num         = "711388585544181"
+		attempts    = make(chan int, 4)
+		checker     = StatusChecker{
+			repository: repository,
+			client:     client,
+			jobs:       jobsCh,
+			results:    resultsCh,
+			wg:         &sync.WaitGroup{},
+		}
+		delay = protocolRetryDelay
+	)
+	defer func() {
+		protocolRetryDelay = delay
+	}()
+	protocolRetryDelay = func(attempt int) time.Duration {
+		attempts <- attempt
+
+		return 10 * time.Millisecond
+	}
+
+	violation := fmt.Errorf("%w: unknown status", inerr.ErrProtocolViolation)
+	repository.On("FindStatus", num).Return(entity.OrderStatusNew, nil)
+	client.On("GetAccrual", num).Return(entity.OrderStatus(""), entity.Amount(0), violation).Twice()
+	client.On("GetAccrual", num).Return(entity.OrderStatusProcessed, entity.Amount(100), nil).Once()
+
+	checker.ctx = ctx
+	checker.Enqueue(entity.NewStatusCheckJob(num))
+	checker.workers.start(ctx, checker.wg, 1, checker.worker)
+
+	assert.Equal(
+		t,
+		entity.StatusCheckResult{Num: num, Status: entity.OrderStatusProcessed, Accrual: 100},
+		<-resultsCh,
+		"проверка продолжается после ответов не по протоколу",
+	)
+	assert.Equal(t, 1, <-attempts, "задержка после первого нарушения протокола")
+	assert.Equal(t, 2, <-attempts, "задержка растет с каждым нарушением протокола подряд")
+
+	cancel()
+	checker.wg.Wait()
+	client.AssertExpectations(t)
+}
+
+func TestStatusChecker_DoStopsWhenResultsBlocked(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		client      = &AccrualClientMock{}
+		repository  = &CheckerRepositoryMock{}
+		num         = "711388585544181"
+		checker     = StatusChecker{
+			repository: repository,
+			client:     client,
+			jobs:       make(chan entity.StatusCheckJob, 1),
+			results:    make(chan entity.StatusCheckResult),
+			wg:         &sync.WaitGroup{},
+		}
+		fetched = make(chan struct{})
+		done    = make(chan struct{})
+	)
+
+	repository.On("FindStatus", num).Return(entity.OrderStatusNew, nil)
+	client.
+		On("GetAccrual", num).
+		Run(func(mock.Arguments) { close(fetched) }).
+		Return(entity.OrderStatusProcessed, entity.Amount(100), nil).
+		Once()
+
+	checker.ctx = ctx
+	checker.Enqueue(entity.NewStatusCheckJob(num))
+	checker.workers.start(ctx, checker.wg, 1, checker.worker)
+	<-fetched
+
+	go func() {
+		checker.wg.Wait()
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("воркер не остановился, пока результат не принят")
+	}
+	client.AssertExpectations(t)
 }
@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type WebhookProcessor interface {
+	DeliverDue(ctx context.Context) (delivered int, err error)
+}
+
+// NewWebhookDeliverer возвращает задачу, которая каждые i отправляет партнерам уведомления
+// о событиях, время доставки которых наступило.
+func NewWebhookDeliverer(p WebhookProcessor, wg *sync.WaitGroup, i time.Duration) *Periodic {
+	return NewPeriodic(p.DeliverDue, Every(i), wg, Labels{
+		Error: "ошибка доставки уведомлений партнерам",
+		Done:  "доставлены уведомления партнерам",
+	})
+}